@@ -10,16 +10,13 @@ var decryptCmd = &cobra.Command{
 	Use:         "decrypt <encrypted|->",
 	Annotations: map[string]string{"since": "0.2.0"},
 	Short:       "Decrypt an encrypted value",
-	Long:        "Decrypt a value produced by encrypt, under the master key. Reads from the argument or stdin (-); writes the plaintext to stdout, or a file with --output.",
+	Long:        "Decrypt a value produced by encrypt, under the master key — or, for a value encrypted for recipients, with your identity (--identity, WS_SECRETS_IDENTITY or ~/.ws/identity). Reads from the argument or stdin (-); writes the plaintext to stdout, or a file with --output. A value encrypted with --context needs the same --context to decrypt.",
 	Args:        cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := getOutputConfig(cmd)
 		masterKeyFlag, _ := cmd.Flags().GetString("master")
-
-		masterKey, err := internalSecrets.ResolveMasterKey(masterKeyFlag)
-		if err != nil {
-			return err
-		}
+		identityFlag, _ := cmd.Flags().GetString("identity")
+		context, _ := cmd.Flags().GetString("context")
 
 		input, err := internalIO.ReadInput(args[0], cmd.InOrStdin())
 		if err != nil {
@@ -28,7 +25,7 @@ var decryptCmd = &cobra.Command{
 
 		input = internalSecrets.NormalizeEncrypted(input)
 
-		decrypted, err := internalSecrets.Decrypt(input, masterKey)
+		decrypted, err := decryptValue(input, masterKeyFlag, identityFlag, context)
 		if err != nil {
 			return err
 		}
@@ -36,3 +33,25 @@ var decryptCmd = &cobra.Command{
 		return handleOutput(cmd, cfg, string(decrypted), "Decrypted Value", "Secret decrypted successfully", false)
 	},
 }
+
+func decryptValue(input, masterKeyFlag, identityFlag, context string) ([]byte, error) {
+	if internalSecrets.IsRecipientEncrypted(input) {
+		identity, err := internalSecrets.ResolveIdentity(identityFlag)
+		if err != nil {
+			return nil, err
+		}
+
+		return internalSecrets.DecryptWithContext(input, identity, context)
+	}
+
+	masterKey, err := internalSecrets.ResolveMasterKey(masterKeyFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	return internalSecrets.DecryptContext(input, masterKey, context)
+}
+
+func init() {
+	decryptCmd.Flags().String("context", "", "Context the value was bound to when encrypted")
+}
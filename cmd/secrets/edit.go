@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	editoripc "github.com/kloudkit/ws-cli/internals/editor"
+	"github.com/kloudkit/ws-cli/internals/env"
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/spf13/cobra"
+)
+
+var editCmd = &cobra.Command{
+	Use:         "edit <file|manifest-key>",
+	Annotations: map[string]string{"since": "next"},
+	Short:       "Edit an encrypted value in place",
+	Long:        "Decrypt an encrypted file or a secrets.<name> / seeds.<destination> manifest key into a private, memory-backed file, open it in $EDITOR (or the browser editor), and re-encrypt it only if the content changed. Recipient values get a fresh data key for their manifest's current recipients: list.",
+	Example: `# Edit a secret from the manifest's secrets: map
+ws secrets edit secrets.api_token
+
+# Edit an encrypted seed source file in place
+ws secrets edit ~/dotfiles/.ssh/id_ed25519
+
+# Edit a bare file that was encrypted with --context
+ws secrets edit --context seeds.~/.netrc netrc.enc`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, _ := cmd.Flags().GetString("source")
+		masterKeyFlag, _ := cmd.Flags().GetString("master")
+		identityFlag, _ := cmd.Flags().GetString("identity")
+		context, _ := cmd.Flags().GetString("context")
+
+		resolved, err := seed.ResolveLocalSource(source)
+		if err != nil && !internalIO.FileExists(args[0]) {
+			return err
+		}
+
+		return seed.EditSecret(seed.EditOptions{
+			Source:    resolved,
+			Target:    args[0],
+			MasterKey: masterKeyFlag,
+			Identity:  identityFlag,
+			Context:   context,
+			Edit:      openEditor(cmd),
+			Out:       cmd.OutOrStdout(),
+			Styled:    isTerminal(cmd),
+		})
+	},
+}
+
+func openEditor(cmd *cobra.Command) func(string) error {
+	return func(path string) error {
+		if editor := os.Getenv("EDITOR"); editor != "" {
+			run := exec.Command("sh", "-c", editor+` "$1"`, "ws-secrets-edit", path)
+			run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+			if err := run.Run(); err != nil {
+				return fmt.Errorf("editor exited with an error; nothing was written: %w", err)
+			}
+
+			return nil
+		}
+
+		if env.IsSSHSession() {
+			return fmt.Errorf("set $EDITOR to edit secrets over SSH, where there is no browser editor")
+		}
+
+		if err := editoripc.Open(editoripc.OpenRequest{Path: path, Window: "reuse"}); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(cmd.ErrOrStderr(), "Waiting for the editor tab to close…")
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		return editoripc.WaitClosed(ctx, path, 500*time.Millisecond, 30*time.Second)
+	}
+}
+
+func init() {
+	editCmd.Flags().String("source", "", "Seed source directories, separated by ':', for manifest keys")
+	editCmd.Flags().String("context", "", "Context a bare encrypted file was bound to")
+}
@@ -14,12 +14,30 @@ var encryptCmd = &cobra.Command{
 	Annotations: map[string]string{"since": "0.2.0"},
 	Short:       "Encrypt a plaintext value",
 	Long:        "Encrypt a value under the master key. Reads the plaintext from the argument or stdin (-); writes the ciphertext to stdout, or a file with --output.",
-	Args:        cobra.ExactArgs(1),
+	Example: `# Encrypt for two developers instead of the master key
+ws secrets encrypt "s3cr3t" --recipient wspub1:… --recipient wspub1:…
+
+# Bind the value to its manifest key so it cannot be swapped into another entry
+ws secrets encrypt "s3cr3t" --master ~/.ws/master.key --context secrets.API_TOKEN
+ws secrets encrypt - --master ~/.ws/master.key --context seeds.~/.netrc < netrc`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := getOutputConfig(cmd)
 		masterKeyFlag, _ := cmd.Flags().GetString("master")
+		recipientFlags, _ := cmd.Flags().GetStringSlice("recipient")
+		context, _ := cmd.Flags().GetString("context")
+
+		var (
+			masterKey  []byte
+			recipients []internalSecrets.Recipient
+			err        error
+		)
 
-		masterKey, err := internalSecrets.ResolveMasterKey(masterKeyFlag)
+		if len(recipientFlags) > 0 {
+			recipients, err = internalSecrets.ParseRecipients(recipientFlags)
+		} else {
+			masterKey, err = internalSecrets.ResolveMasterKey(masterKeyFlag)
+		}
 		if err != nil {
 			return err
 		}
@@ -31,7 +49,12 @@ var encryptCmd = &cobra.Command{
 
 		plaintext = strings.TrimSpace(plaintext)
 
-		encrypted, err := internalSecrets.Encrypt([]byte(plaintext), masterKey)
+		var encrypted string
+		if len(recipients) > 0 {
+			encrypted, err = internalSecrets.EncryptForContext([]byte(plaintext), recipients, context)
+		} else {
+			encrypted, err = internalSecrets.EncryptContext([]byte(plaintext), masterKey, context)
+		}
 		if err != nil {
 			return fmt.Errorf("encryption failed: %w", err)
 		}
@@ -39,3 +62,8 @@ var encryptCmd = &cobra.Command{
 		return handleOutput(cmd, cfg, encrypted, "Encrypted Value", "Secret encrypted successfully", true)
 	},
 }
+
+func init() {
+	encryptCmd.Flags().StringSlice("recipient", nil, "Encrypt for this recipient public key instead of the master key (repeatable); only their identities can decrypt it")
+	encryptCmd.Flags().String("context", "", "Bind the ciphertext to this name (e.g. secrets.API_TOKEN or seeds.<destination>); it only decrypts under the same context")
+}
@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/kloudkit/ws-cli/internals/config"
+	internalSecrets "github.com/kloudkit/ws-cli/internals/secrets"
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/spf13/cobra"
+)
+
+var osExit = os.Exit
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type envSecret struct {
+	name   string
+	secret string
+	file   bool
+}
+
+var execCmd = &cobra.Command{
+	Use:         "exec --env NAME=secrets.<name>... -- <command> [args...]",
+	Annotations: map[string]string{"since": "next"},
+	Short:       "Run a command with decrypted secrets in its environment",
+	Long:        "Decrypt secrets from the seed manifest's secrets: map and run a command with them injected, so a CLI can read a token without it being rendered into a dotfile. The master key and identity are removed from the command's environment, and its exit code is passed through.",
+	Example: `# Give a CLI its API token for one invocation
+ws secrets exec --env GITHUB_TOKEN=secrets.github_token -- gh repo list
+
+# Hand a tool a credentials file instead of an environment value; the file lives
+# in a private memory-backed directory and is zeroed and removed on exit
+ws secrets exec --env GOOGLE_APPLICATION_CREDENTIALS=@secrets.gcp_key -- terraform plan
+
+# Read a layered seed source and decrypt recipient secrets with your identity
+ws secrets exec --source /mnt/org:~/seed --identity ~/.ws/identity --env NPM_TOKEN=secrets.npm -- npm publish`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() < 0 || len(args) == 0 {
+			return fmt.Errorf("expected a command after --")
+		}
+
+		if cmd.ArgsLenAtDash() > 0 {
+			return fmt.Errorf("unexpected arguments before --: %s", strings.Join(args[:cmd.ArgsLenAtDash()], " "))
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, _ := cmd.Flags().GetString("source")
+		masterKeyFlag, _ := cmd.Flags().GetString("master")
+		identityFlag, _ := cmd.Flags().GetString("identity")
+		envFlags, _ := cmd.Flags().GetStringArray("env")
+
+		specs, err := parseEnvSecrets(envFlags)
+		if err != nil {
+			return err
+		}
+
+		resolved, err := seed.ResolveSource(source)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(specs))
+		for _, spec := range specs {
+			names = append(names, spec.secret)
+		}
+
+		decrypted, err := seed.DecryptSecrets(seed.SecretsOptions{
+			Source:    resolved,
+			MasterKey: masterKeyFlag,
+			Identity:  identityFlag,
+			Names:     names,
+		})
+		if err != nil {
+			return err
+		}
+
+		code, err := runWithSecrets(cmd, args, specs, decrypted)
+		if err != nil {
+			return err
+		}
+
+		if code != 0 {
+			osExit(code)
+		}
+
+		return nil
+	},
+}
+
+func parseEnvSecrets(values []string) ([]envSecret, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("at least one --env NAME=secrets.<name> is required")
+	}
+
+	specs := make([]envSecret, 0, len(values))
+	for _, value := range values {
+		name, ref, ok := strings.Cut(value, "=")
+		if !ok || !envName.MatchString(name) {
+			return nil, fmt.Errorf("invalid --env %q (expected NAME=secrets.<name> or NAME=@secrets.<name>)", value)
+		}
+
+		spec := envSecret{name: name}
+		ref, spec.file = strings.CutPrefix(ref, "@")
+
+		if spec.secret, ok = strings.CutPrefix(ref, "secrets."); !ok || spec.secret == "" {
+			return nil, fmt.Errorf("invalid --env %q (expected NAME=secrets.<name> or NAME=@secrets.<name>)", value)
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+func runWithSecrets(cmd *cobra.Command, args []string, specs []envSecret, decrypted map[string][]byte) (int, error) {
+	defer func() {
+		for _, plain := range decrypted {
+			clear(plain)
+		}
+	}()
+
+	environ := slices.DeleteFunc(os.Environ(), func(entry string) bool {
+		name, _, _ := strings.Cut(entry, "=")
+		return name == config.RuntimeKey("secrets", "master_key") || name == config.RuntimeKey("secrets", "identity")
+	})
+
+	var dir string
+	for _, spec := range specs {
+		if !spec.file {
+			environ = append(environ, spec.name+"="+string(decrypted[spec.secret]))
+			continue
+		}
+
+		if dir == "" {
+			var cleanup func()
+			var err error
+			if dir, cleanup, err = internalSecrets.PrivateDir(); err != nil {
+				return 0, err
+			}
+			defer cleanup()
+		}
+
+		path := filepath.Join(dir, spec.name)
+		if err := os.WriteFile(path, decrypted[spec.secret], 0o600); err != nil {
+			return 0, fmt.Errorf("failed to write %s: %w", spec.name, err)
+		}
+
+		environ = append(environ, spec.name+"="+path)
+	}
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = environ
+	child.Stdin, child.Stdout, child.Stderr = cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr()
+
+	if err := child.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for sig := range signals {
+			_ = child.Process.Signal(sig)
+		}
+	}()
+
+	err := child.Wait()
+	signal.Stop(signals)
+	close(signals)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+
+		return exitErr.ExitCode(), nil
+	}
+
+	return 0, err
+}
+
+func init() {
+	execCmd.Flags().StringArray("env", nil, "NAME=secrets.<name> to set NAME to the plaintext, NAME=@secrets.<name> to set it to the path of a 0600 file in $XDG_RUNTIME_DIR or /dev/shm")
+	execCmd.Flags().String("source", "", "Seed source directories, separated by ':'")
+}
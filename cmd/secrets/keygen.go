@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	internalSecrets "github.com/kloudkit/ws-cli/internals/secrets"
+	"github.com/kloudkit/ws-cli/internals/styles"
+	"github.com/spf13/cobra"
+)
+
+var keygenCmd = &cobra.Command{
+	Use:         "keygen",
+	Annotations: map[string]string{"since": "next"},
+	Short:       "Generate a personal X25519 keypair for recipient secrets",
+	Long:        "Generate a personal identity for multi-recipient secrets. The identity (wssec1:…) stays private — keep it at ~/.ws/identity or point --identity / WS_SECRETS_IDENTITY at it; share the printed recipient (wspub1:…) so teammates can add it with ws secrets recipients add.",
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := getOutputConfig(cmd)
+
+		identity, err := internalSecrets.GenerateIdentity()
+		if err != nil {
+			return err
+		}
+
+		recipient := identity.Recipient().String()
+		content := fmt.Sprintf("# created: %s\n# public key: %s\n%s",
+			time.Now().UTC().Format(time.RFC3339), recipient, identity)
+
+		if cfg.file != "" {
+			if err := internalIO.WriteSecureFile(cfg.file, []byte(content+"\n"), cfg.mode, cfg.force); err != nil {
+				return err
+			}
+
+			if cfg.raw {
+				fmt.Fprintln(cmd.OutOrStdout(), recipient)
+				return nil
+			}
+
+			styles.PrintSuccessWithDetailsCode(cmd.OutOrStdout(), "Identity written successfully", [][]string{
+				{"Output", cfg.file},
+				{"Recipient", recipient},
+			})
+
+			return nil
+		}
+
+		return handleCustomOutput(cmd, cfg, content, "", func(out io.Writer) {
+			fmt.Fprintf(out, "%s\n", styles.Header().Render("Identity"))
+			fmt.Fprintf(out, "  %s\n", styles.Code().Render(identity.String()))
+			fmt.Fprintf(out, "%s\n", styles.Header().Render("Recipient"))
+			fmt.Fprintf(out, "  %s\n", styles.Code().Render(recipient))
+			fmt.Fprintf(out, "%s\n", styles.Muted().Render("💡 Keep the identity private; share the recipient with your team"))
+		})
+	},
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"os"
+
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var recipientsCmd = &cobra.Command{
+	Use:         "recipients",
+	Annotations: map[string]string{"since": "next"},
+	Short:       "Manage who can decrypt a seed manifest's recipient secrets",
+	Long:        "Edit the recipients: list of a seed manifest. Adding re-wraps every recipient secret's data key for the new list; removing re-encrypts each secret under a fresh data key. Either needs only one current recipient's identity (--identity). Operates on the last layer of --source.",
+}
+
+var recipientsAddCmd = &cobra.Command{
+	Use:         "add <recipient>...",
+	Annotations: map[string]string{"since": "next"},
+	Short:       "Grant recipients access to the manifest's secrets",
+	Long:        "Add public keys (wspub1:…) to the manifest's recipients: list and re-wrap every recipient secret's data key so they can decrypt it.",
+	Args:        cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecipients(cmd, args, nil)
+	},
+}
+
+var recipientsRemoveCmd = &cobra.Command{
+	Use:         "remove <recipient|id>...",
+	Annotations: map[string]string{"since": "next"},
+	Short:       "Revoke recipients' access to the manifest's secrets",
+	Long:        "Remove public keys, or their short ids, from the manifest's recipients: list and re-encrypt every recipient secret under a fresh data key for the rest, so an old data key cannot read later values. A removed recipient who kept an old copy of a secret can still read that copy; rotate the underlying credential when that matters.",
+	Args:        cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecipients(cmd, nil, args)
+	},
+}
+
+func runRecipients(cmd *cobra.Command, add, remove []string) error {
+	source, _ := cmd.Flags().GetString("source")
+	identity, _ := cmd.Flags().GetString("identity")
+
+	resolved, err := seed.ResolveLocalSource(source)
+	if err != nil {
+		return err
+	}
+
+	return seed.UpdateRecipients(seed.RecipientsOptions{
+		Source:   resolved,
+		Identity: identity,
+		Add:      add,
+		Remove:   remove,
+		Out:      cmd.OutOrStdout(),
+		Styled:   isTerminal(cmd),
+	})
+}
+
+func isTerminal(cmd *cobra.Command) bool {
+	file, ok := cmd.OutOrStdout().(*os.File)
+
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+func init() {
+	recipientsCmd.PersistentFlags().String("source", "", "Seed source directories, separated by ':'; the last layer is edited")
+
+	recipientsCmd.AddCommand(recipientsAddCmd, recipientsRemoveCmd)
+}
@@ -8,20 +8,31 @@ var SecretsCmd = &cobra.Command{
 	Use:         "secrets",
 	Annotations: map[string]string{"since": "0.2.0"},
 	Short:       "Manage encryption and decryption of secrets",
-	Long:        "Encrypt and decrypt values under a master key, and generate the keys themselves. Encrypted values are what the seed engine's secrets: map stores and decrypts at boot.",
+	Long:        "Encrypt and decrypt values under a master key, and generate the keys themselves. Encrypted values are what the seed engine's secrets: map stores and decrypts at boot. Values can instead be encrypted for recipients — personal X25519 keypairs from keygen — so each developer decrypts with their own identity and access is granted or revoked per person.",
 	Example: `# Generate a master key
 ws secrets generate master
 
 # Encrypt a value under it
-ws secrets encrypt "s3cr3t" --master ~/.ws/master.key`,
+ws secrets encrypt "s3cr3t" --master ~/.ws/master.key
+
+# Create a personal identity and grant it access to a seed manifest
+ws secrets keygen --output ~/.ws/identity
+ws secrets recipients add wspub1:… --source ~/seed
+
+# Edit a manifest secret in $EDITOR and re-encrypt it
+ws secrets edit secrets.API_TOKEN --source ~/seed
+
+# Run a command with a manifest secret in its environment
+ws secrets exec --env GITHUB_TOKEN=secrets.github_token -- gh repo list`,
 }
 
 func init() {
 	SecretsCmd.PersistentFlags().String("master", "", "Master key or path to key file")
+	SecretsCmd.PersistentFlags().String("identity", "", "Recipient identity or path to identity file")
 	SecretsCmd.PersistentFlags().String("output", "", "Write output to file instead of stdout")
 	SecretsCmd.PersistentFlags().String("mode", "", "File permissions (e.g., 0o600, 384), only when --output is used")
 	SecretsCmd.PersistentFlags().Bool("force", false, "Overwrite existing files")
 	SecretsCmd.PersistentFlags().Bool("raw", false, "Output without styling")
 
-	SecretsCmd.AddCommand(encryptCmd, decryptCmd, generateCmd, materializeCmd)
+	SecretsCmd.AddCommand(encryptCmd, decryptCmd, generateCmd, materializeCmd, keygenCmd, recipientsCmd, editCmd, execCmd)
 }
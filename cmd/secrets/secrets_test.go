@@ -3,11 +3,13 @@ package secrets
 import (
 	"bytes"
 	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	internalSecrets "github.com/kloudkit/ws-cli/internals/secrets"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"gotest.tools/v3/assert"
@@ -15,7 +17,11 @@ import (
 
 func resetCommandFlags(cmd *cobra.Command) {
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
-		flag.Value.Set(flag.DefValue)
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
 		flag.Changed = false
 	})
 
@@ -57,7 +63,7 @@ func TestSecretsCommand(t *testing.T) {
 		assert.NilError(t, err)
 
 		output := strings.TrimSpace(buffer.String())
-		assert.Assert(t, strings.Count(output, "$") == 1)
+		assert.Assert(t, strings.HasPrefix(output, "$ws1$argon2id$"))
 		assert.Assert(t, !strings.Contains(output, "Encrypted"))
 	})
 
@@ -112,9 +118,9 @@ func TestSecretsCommand(t *testing.T) {
 
 		encrypted := strings.TrimSpace(encryptBuffer.String())
 		parts := strings.Split(encrypted, "$")
-		assert.Equal(t, 2, len(parts))
+		assert.Equal(t, 6, len(parts))
 
-		multilineEncrypted := parts[0] + "\n  \t$" + parts[1] + "\n"
+		multilineEncrypted := strings.Join(parts[:5], "$") + "\n  \t$" + parts[5] + "\n"
 
 		resetCommandFlags(SecretsCmd)
 
@@ -132,6 +138,51 @@ func TestSecretsCommand(t *testing.T) {
 		assert.Equal(t, "test-secret", output)
 	})
 
+	t.Run("ContextBinding", func(t *testing.T) {
+		resetCommandFlags(SecretsCmd)
+
+		keyFile := filepath.Join(t.TempDir(), "master.key")
+		masterKey := base64.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012"))
+		err := os.WriteFile(keyFile, []byte(masterKey), 0600)
+		assert.NilError(t, err)
+
+		encryptBuffer := new(bytes.Buffer)
+		SecretsCmd.SetOut(encryptBuffer)
+		SecretsCmd.SetErr(encryptBuffer)
+		SecretsCmd.SetArgs([]string{"encrypt", "test-secret", "--master", keyFile, "--context", "secrets.API_TOKEN", "--raw"})
+
+		err = SecretsCmd.Execute()
+		assert.NilError(t, err)
+
+		encrypted := strings.TrimSpace(encryptBuffer.String())
+		assert.Assert(t, strings.Contains(encrypted, "$ad$"))
+
+		for _, tt := range []struct {
+			context string
+			err     string
+		}{
+			{"", "bound to a context"},
+			{"secrets.DB_PASSWORD", "message authentication failed"},
+			{"secrets.API_TOKEN", ""},
+		} {
+			resetCommandFlags(SecretsCmd)
+
+			decryptBuffer := new(bytes.Buffer)
+			SecretsCmd.SetOut(decryptBuffer)
+			SecretsCmd.SetErr(decryptBuffer)
+			SecretsCmd.SetArgs([]string{"decrypt", encrypted, "--master", keyFile, "--context", tt.context, "--raw"})
+
+			err = SecretsCmd.Execute()
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				continue
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, decryptBuffer.String(), "test-secret")
+		}
+	})
+
 	t.Run("GenerateLogin", func(t *testing.T) {
 		resetCommandFlags(SecretsCmd)
 
@@ -150,4 +201,162 @@ func TestSecretsCommand(t *testing.T) {
 		assert.Assert(t, strings.HasPrefix(output, "$argon2id$v=19$m=65536,t=3,p=4$"))
 	})
 
+	t.Run("KeygenRecipientRoundTrip", func(t *testing.T) {
+		execute := func(args ...string) string {
+			t.Helper()
+			resetCommandFlags(SecretsCmd)
+
+			buffer := new(bytes.Buffer)
+			SecretsCmd.SetOut(buffer)
+			SecretsCmd.SetErr(buffer)
+			SecretsCmd.SetArgs(args)
+
+			assert.NilError(t, SecretsCmd.Execute())
+
+			return strings.TrimSpace(buffer.String())
+		}
+
+		identityFile := filepath.Join(t.TempDir(), "identity")
+		recipient := execute("keygen", "--output", identityFile, "--raw")
+		assert.Assert(t, strings.HasPrefix(recipient, "wspub1:"))
+
+		info, err := os.Stat(identityFile)
+		assert.NilError(t, err)
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+
+		encrypted := execute("encrypt", "team-secret", "--recipient", recipient, "--raw")
+		assert.Assert(t, strings.HasPrefix(encrypted, "$ws1r$x25519$"))
+
+		assert.Equal(t, execute("decrypt", encrypted, "--identity", identityFile, "--raw"), "team-secret")
+	})
+
+	t.Run("RecipientsAdd", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		resetCommandFlags(SecretsCmd)
+
+		source := t.TempDir()
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte("version: v1\n"), 0o644))
+
+		identity, err := internalSecrets.GenerateIdentity()
+		assert.NilError(t, err)
+
+		buffer := new(bytes.Buffer)
+		SecretsCmd.SetOut(buffer)
+		SecretsCmd.SetErr(buffer)
+		SecretsCmd.SetArgs([]string{"recipients", "add", identity.Recipient().String(), "--source", source})
+
+		assert.NilError(t, SecretsCmd.Execute())
+		assert.Assert(t, strings.Contains(buffer.String(), "Added recipient "+identity.Recipient().String()))
+
+		manifest, err := os.ReadFile(filepath.Join(source, ".seed.yaml"))
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(string(manifest), "recipients:\n  - "+identity.Recipient().String()))
+	})
+	t.Run("EditWithEditor", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+		t.Setenv("EDITOR", "sed -i s/OLD/NEW/")
+
+		resetCommandFlags(SecretsCmd)
+
+		masterKey := []byte("12345678901234567890123456789012")
+		keyFile := filepath.Join(t.TempDir(), "master.key")
+		assert.NilError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(masterKey)), 0o600))
+
+		encrypted, err := internalSecrets.Encrypt([]byte("OLD-VALUE\n"), masterKey)
+		assert.NilError(t, err)
+
+		secretFile := filepath.Join(t.TempDir(), "token.enc")
+		assert.NilError(t, os.WriteFile(secretFile, []byte(encrypted), 0o600))
+
+		buffer := new(bytes.Buffer)
+		SecretsCmd.SetOut(buffer)
+		SecretsCmd.SetErr(buffer)
+		SecretsCmd.SetArgs([]string{"edit", secretFile, "--master", keyFile})
+
+		assert.NilError(t, SecretsCmd.Execute())
+		assert.Assert(t, strings.Contains(buffer.String(), "Updated file"))
+
+		updated, err := os.ReadFile(secretFile)
+		assert.NilError(t, err)
+
+		plain, err := internalSecrets.Decrypt(string(updated), masterKey)
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "NEW-VALUE\n")
+	})
+	t.Run("ExecInjectsSecrets", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		masterKey := []byte("12345678901234567890123456789012")
+		keyFile := filepath.Join(t.TempDir(), "master.key")
+		assert.NilError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(masterKey)), 0o600))
+
+		token, err := internalSecrets.Encrypt([]byte("tok-123"), masterKey)
+		assert.NilError(t, err)
+		credentials, err := internalSecrets.EncryptContext([]byte("{\"key\":1}"), masterKey, "secrets.creds")
+		assert.NilError(t, err)
+
+		source := t.TempDir()
+		manifest := fmt.Sprintf("version: v1\nsecrets:\n  api_token: %s\n  creds: %s\n", token, credentials)
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		execute := func(script string) (string, int) {
+			t.Helper()
+			resetCommandFlags(SecretsCmd)
+
+			exit := 0
+			original := osExit
+			osExit = func(code int) { exit = code }
+			t.Cleanup(func() { osExit = original })
+
+			buffer := new(bytes.Buffer)
+			SecretsCmd.SetOut(buffer)
+			SecretsCmd.SetErr(buffer)
+			SecretsCmd.SetArgs([]string{
+				"exec", "--source", source, "--master", keyFile,
+				"--env", "TOKEN=secrets.api_token", "--env", "CREDS=@secrets.creds",
+				"--", "sh", "-c", script,
+			})
+
+			assert.NilError(t, SecretsCmd.Execute())
+
+			return buffer.String(), exit
+		}
+
+		t.Setenv("WS_SECRETS_MASTER_KEY", "file:"+keyFile)
+		t.Setenv("WS_SECRETS_IDENTITY", "wssec1:leaked")
+
+		output, code := execute(`printf '%s|%s|' "$TOKEN" "$CREDS"; cat "$CREDS"`)
+		assert.Equal(t, code, 0)
+
+		parts := strings.SplitN(output, "|", 3)
+		assert.Equal(t, parts[0], "tok-123")
+		assert.Equal(t, parts[2], `{"key":1}`)
+
+		_, err = os.Stat(parts[1])
+		assert.Assert(t, os.IsNotExist(err))
+
+		output, _ = execute(`env | grep '^WS_SECRETS_' || true`)
+		assert.Equal(t, output, "")
+
+		_, code = execute("exit 3")
+		assert.Equal(t, code, 3)
+	})
+
+	t.Run("ExecRejectsBadEnv", func(t *testing.T) {
+		resetCommandFlags(SecretsCmd)
+
+		buffer := new(bytes.Buffer)
+		SecretsCmd.SetOut(buffer)
+		SecretsCmd.SetErr(buffer)
+		SecretsCmd.SetArgs([]string{"exec", "--env", "TOKEN=api_token", "--", "true"})
+
+		assert.ErrorContains(t, SecretsCmd.Execute(), `invalid --env "TOKEN=api_token"`)
+	})
 }
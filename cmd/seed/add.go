@@ -0,0 +1,64 @@
+package seed
+
+import (
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/spf13/cobra"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <path>",
+	Short: "Capture an existing file into the seed source",
+	Long:  "Copy a file into the seed source at its rhyming path — the inverse of what apply projects — and add a .seed.yaml entry when it needs one, keeping the manifest's comments and layout. With a layered source the file goes into the last, highest-precedence layer.",
+	Example: `# Capture a dotfile; a mode other than 0o644 is kept in its entry
+ws seed add ~/.config/starship.toml
+
+# Record how apply should treat it
+ws seed add ~/.gitconfig --op merge
+ws seed add ~/.npmrc --template
+
+# Encrypt the captured copy, bound to its seeds.<destination> key
+ws seed add ~/.netrc --secret --master ~/.ws/master.key
+
+# Replace a file or entry already in the seed source
+ws seed add ~/.tmux.conf --force`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	Annotations:  map[string]string{"since": "next"},
+	RunE:         runAdd,
+}
+
+func runAdd(cmd *cobra.Command, args []string) error {
+	source, _ := cmd.Flags().GetString("source")
+	op, _ := cmd.Flags().GetString("op")
+	secret, _ := cmd.Flags().GetBool("secret")
+	template, _ := cmd.Flags().GetBool("template")
+	master, _ := cmd.Flags().GetString("master")
+	force, _ := cmd.Flags().GetBool("force")
+
+	resolved, err := seed.ResolveLocalSource(source)
+	if err != nil {
+		return err
+	}
+
+	return seed.Add(seed.AddOptions{
+		Source:    resolved,
+		Path:      args[0],
+		Op:        seed.Op(op),
+		Secret:    secret,
+		Template:  template,
+		MasterKey: master,
+		Force:     force,
+		Out:       cmd.OutOrStdout(),
+		Styled:    isTerminal(cmd.OutOrStdout()),
+	})
+}
+
+func init() {
+	addCmd.Flags().String("op", "", "Operation apply performs (copy, merge, append, prepend, block or lineinfile)")
+	addCmd.Flags().Bool("secret", false, "Encrypt the captured file under the master key, or for the manifest's recipients")
+	addCmd.Flags().Bool("template", false, "Render the captured file as a template on apply")
+	addCmd.Flags().String("master", "", "Master key or path to key file")
+	addCmd.Flags().Bool("force", false, "Replace an existing source file or manifest entry")
+
+	SeedCmd.AddCommand(addCmd)
+}
@@ -3,6 +3,8 @@ package seed
 import (
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/kloudkit/ws-cli/internals/seed"
 	"github.com/spf13/cobra"
@@ -10,9 +12,37 @@ import (
 )
 
 var applyCmd = &cobra.Command{
-	Use:          "apply [dest...]",
-	Short:        "Project seed content onto the filesystem",
-	Long:         "Apply the seed source to the filesystem — mirror bare files and run the .seed.yaml operations. Writes only where the destination is missing unless --force; pass destinations to limit the run to those paths.",
+	Use:   "apply [dest...]",
+	Short: "Project seed content onto the filesystem",
+	Long:  "Apply the seed source to the filesystem — mirror bare files and run the .seed.yaml operations. Writes only where the destination is missing unless --force; pass destinations to limit the run to those paths.",
+	Example: `# Preview the changes as a unified diff, secrets redacted
+ws seed apply --dry-run
+
+# List the before/onChange hooks an entry would run; hooks run only when it changes
+ws seed apply --dry-run ~/.tmux.conf
+
+# Apply only two destinations, overwriting what is there
+ws seed apply --force ~/.gitconfig ~/.config/starship.toml
+
+# Include entries scoped to a profile; when: selectors are checked against the workspace
+ws seed apply --profile backend
+
+# Machine-readable report: one JSON document, or one line per entry as it completes
+ws seed apply --output json
+ws seed apply --output ndjson
+
+# Apply up to 8 independent destinations at once (parent directories still go first)
+ws seed apply --jobs 8
+
+# Re-apply destinations whose entry, source or secrets change until Ctrl-C;
+# destinations still matching the last apply are updated without --force
+ws seed apply --watch
+
+# Decrypt recipient secrets with an identity instead of the master key
+ws seed apply --identity ~/.ws/identity
+
+# Undo the last apply; every destination it changed was snapshotted first
+ws seed rollback`,
 	SilenceUsage: true,
 	Annotations:  map[string]string{"since": "next"},
 	RunE:         runApply,
@@ -22,20 +52,52 @@ func runApply(cmd *cobra.Command, args []string) error {
 	source, _ := cmd.Flags().GetString("source")
 	force, _ := cmd.Flags().GetBool("force")
 	master, _ := cmd.Flags().GetString("master")
+	identity, _ := cmd.Flags().GetString("identity")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	profiles, _ := cmd.Flags().GetStringSlice("profile")
+	outputFlag, _ := cmd.Flags().GetString("output")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	watch, _ := cmd.Flags().GetBool("watch")
+	hookTimeout, _ := cmd.Flags().GetDuration("hook-timeout")
 
-	resolved, err := seed.ResolveSource(source)
+	output, err := seed.ParseOutput(outputFlag)
 	if err != nil {
 		return err
 	}
 
-	return seed.Apply(seed.Options{
-		Source:    resolved,
-		Force:     force,
-		Dests:     args,
-		MasterKey: master,
-		Out:       cmd.OutOrStdout(),
-		Styled:    isTerminal(cmd.OutOrStdout()),
-	})
+	resolve := seed.ResolveSource
+	if watch {
+		resolve = seed.ResolveLocalSource
+	}
+
+	resolved, err := resolve(source)
+	if err != nil {
+		return err
+	}
+
+	opts := seed.Options{
+		Source:      resolved,
+		Force:       force,
+		Dests:       args,
+		MasterKey:   master,
+		Identity:    identity,
+		Profiles:    profiles,
+		DryRun:      dryRun,
+		Out:         cmd.OutOrStdout(),
+		Styled:      isTerminal(cmd.OutOrStdout()),
+		Output:      output,
+		Jobs:        jobs,
+		HookTimeout: hookTimeout,
+	}
+
+	if watch {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return seed.Watch(ctx, opts)
+	}
+
+	return seed.Apply(opts)
 }
 
 func isTerminal(out io.Writer) bool {
@@ -47,6 +109,13 @@ func isTerminal(out io.Writer) bool {
 func init() {
 	applyCmd.Flags().Bool("force", false, "Overwrite existing destinations")
 	applyCmd.Flags().String("master", "", "Master key or path to key file")
+	applyCmd.Flags().String("identity", "", "Recipient identity or path to identity file")
+	applyCmd.Flags().Bool("dry-run", false, "Show what would change without writing")
+	applyCmd.Flags().StringSlice("profile", nil, "Include entries scoped to this profile")
+	applyCmd.Flags().String("output", "text", "Output format (text, json or ndjson)")
+	applyCmd.Flags().Int("jobs", 4, "Number of destinations to apply in parallel")
+	applyCmd.Flags().Duration("hook-timeout", seed.DefaultHookTimeout, "Kill a before or onChange hook that runs longer than this (an entry's hookTimeout overrides it)")
+	applyCmd.Flags().Bool("watch", false, "Re-apply changed destinations when the seed source changes")
 
 	SeedCmd.AddCommand(applyCmd)
 }
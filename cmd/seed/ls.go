@@ -1,6 +1,9 @@
 package seed
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"strings"
 
 	"github.com/kloudkit/ws-cli/internals/seed"
@@ -11,13 +14,32 @@ import (
 var lsCmd = &cobra.Command{
 	Use:         "ls",
 	Short:       "List seed destinations and their behaviors",
-	Long:        "List what apply would write — each destination with its operation and whether it carries a secret or a template — without touching the filesystem.",
+	Long:        "List what apply would write — each destination with its operation and whether it carries a secret or a template — without touching the filesystem. With a layered source, each destination names the layers it is built from and the lower layers it overrides. --output json prints the same as an array of objects.",
 	Annotations: map[string]string{"since": "next"},
 	RunE:        runLs,
 }
 
+type lsEntry struct {
+	Dest      string   `json:"dest"`
+	Op        seed.Op  `json:"op"`
+	Format    string   `json:"format,omitempty"`
+	State     string   `json:"state"`
+	Mode      string   `json:"mode,omitempty"`
+	Secret    bool     `json:"secret"`
+	Template  bool     `json:"template"`
+	Target    string   `json:"target,omitempty"`
+	Empty     bool     `json:"empty,omitempty"`
+	Layers    []string `json:"layers"`
+	Overrides []string `json:"overrides,omitempty"`
+}
+
 func runLs(cmd *cobra.Command, args []string) error {
 	source, _ := cmd.Flags().GetString("source")
+	output, _ := cmd.Flags().GetString("output")
+
+	if output != string(seed.OutputText) && output != string(seed.OutputJSON) {
+		return fmt.Errorf("unknown output %q (expected text or json)", output)
+	}
 
 	resolved, err := seed.ResolveSource(source)
 	if err != nil {
@@ -29,17 +51,77 @@ func runLs(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	profiles, _ := cmd.Flags().GetStringSlice("profile")
+	if err := plan.Select(profiles); err != nil {
+		return err
+	}
+
 	out := cmd.OutOrStdout()
+	if output == string(seed.OutputJSON) {
+		return listJSON(out, plan.Ops)
+	}
+
 	for _, op := range plan.Ops {
-		styles.PrintKeyValue(out, op.Dest, describe(op))
+		description := describe(op)
+		if len(plan.Layers) > 1 {
+			description += " " + describeLayers(op)
+		}
+
+		styles.PrintKeyValue(out, op.Dest, description)
 	}
 
 	return nil
 }
 
+func listJSON(out io.Writer, ops []seed.ResolvedOp) error {
+	entries := make([]lsEntry, 0, len(ops))
+	for _, op := range ops {
+		state := op.State
+		if state == "" {
+			state = seed.PresencePresent
+		}
+
+		entries = append(entries, lsEntry{
+			Dest:      op.Dest,
+			Op:        op.Op,
+			Format:    string(op.Format),
+			State:     string(state),
+			Mode:      op.Mode,
+			Secret:    op.Secret,
+			Template:  op.Template,
+			Target:    op.Target,
+			Empty:     op.Empty,
+			Layers:    op.Layers(),
+			Overrides: op.Overrides(),
+		})
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(entries)
+}
+
 func describe(op seed.ResolvedOp) string {
 	parts := []string{string(op.Op)}
 
+	if op.Format != "" {
+		parts = append(parts, string(op.Format))
+	}
+
+	if op.Target != "" {
+		parts = append(parts, "-> "+op.Target)
+	}
+
+	if op.Empty {
+		parts = append(parts, "empty")
+	}
+
+	if op.State == seed.PresenceAbsent {
+		parts = append(parts, "absent")
+	}
+
 	if op.Secret {
 		parts = append(parts, "secret")
 	}
@@ -51,6 +133,19 @@ func describe(op seed.ResolvedOp) string {
 	return strings.Join(parts, " ")
 }
 
+func describeLayers(op seed.ResolvedOp) string {
+	layers := strings.Join(op.Layers(), " + ")
+
+	if overrides := op.Overrides(); len(overrides) > 0 {
+		return fmt.Sprintf("(%s, overrides %s)", layers, strings.Join(overrides, ", "))
+	}
+
+	return fmt.Sprintf("(%s)", layers)
+}
+
 func init() {
+	lsCmd.Flags().StringSlice("profile", nil, "Include entries scoped to this profile")
+	lsCmd.Flags().String("output", "text", "Output format (text or json)")
+
 	SeedCmd.AddCommand(lsCmd)
 }
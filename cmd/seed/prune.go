@@ -0,0 +1,41 @@
+package seed
+
+import (
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/spf13/cobra"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:          "prune",
+	Short:        "Remove destinations dropped from the seed source",
+	Long:         "Remove what an earlier apply seeded but the source no longer declares — delete copied files and strip managed blocks and lines, leaving the rest of the file intact. Only destinations last applied from one of this source's layers are considered. Destinations edited since they were seeded are kept unless --force; merged, appended and prepended content cannot be un-applied and is only forgotten.",
+	SilenceUsage: true,
+	Annotations:  map[string]string{"since": "next"},
+	RunE:         runPrune,
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	source, _ := cmd.Flags().GetString("source")
+	force, _ := cmd.Flags().GetBool("force")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	resolved, err := seed.ResolveSource(source)
+	if err != nil {
+		return err
+	}
+
+	return seed.Prune(seed.PruneOptions{
+		Source: resolved,
+		Force:  force,
+		DryRun: dryRun,
+		Out:    cmd.OutOrStdout(),
+		Styled: isTerminal(cmd.OutOrStdout()),
+	})
+}
+
+func init() {
+	pruneCmd.Flags().Bool("force", false, "Remove destinations edited since they were seeded")
+	pruneCmd.Flags().Bool("dry-run", false, "Show what would be removed without removing it")
+
+	SeedCmd.AddCommand(pruneCmd)
+}
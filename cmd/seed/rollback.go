@@ -0,0 +1,74 @@
+package seed
+
+import (
+	"fmt"
+
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/kloudkit/ws-cli/internals/styles"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [dest...]",
+	Short: "Restore destinations from a seed backup",
+	Long:  "Restore destinations from the snapshot an apply takes before changing them — content, mode or link target, and ledger entry — writing each back atomically and removing those the run created. The last 10 runs are kept under the seed state directory.",
+	Example: `# Undo the latest apply
+ws seed rollback
+
+# List the available runs and restore an older one
+ws seed rollback --list
+ws seed rollback --run 20261017T161442.318Z
+
+# Restore only one destination, including entries removed from an emptied directory
+ws seed rollback ~/.config/nvim`,
+	SilenceUsage: true,
+	Annotations:  map[string]string{"since": "next"},
+	RunE:         runRollback,
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	run, _ := cmd.Flags().GetString("run")
+	list, _ := cmd.Flags().GetBool("list")
+
+	if list {
+		return listBackups(cmd)
+	}
+
+	return seed.Rollback(seed.RollbackOptions{
+		Run:    run,
+		Dests:  args,
+		Out:    cmd.OutOrStdout(),
+		Styled: isTerminal(cmd.OutOrStdout()),
+	})
+}
+
+func listBackups(cmd *cobra.Command) error {
+	backups, err := seed.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(backups) == 0 {
+		fmt.Fprintln(out, "No seed backups")
+		return nil
+	}
+
+	for _, backup := range backups {
+		noun := "destinations"
+		if len(backup.Entries) == 1 {
+			noun = "destination"
+		}
+
+		styles.PrintKeyValue(out, backup.ID, fmt.Sprintf("%d %s", len(backup.Entries), noun))
+	}
+
+	return nil
+}
+
+func init() {
+	rollbackCmd.Flags().String("run", "", "Backup run to restore (default: the latest)")
+	rollbackCmd.Flags().Bool("list", false, "List the available backup runs")
+
+	SeedCmd.AddCommand(rollbackCmd)
+}
@@ -1,14 +1,30 @@
 package seed
 
 import (
+	"fmt"
+
 	"github.com/kloudkit/ws-cli/internals/seed"
 	"github.com/spf13/cobra"
 )
 
 var rotateCmd = &cobra.Command{
-	Use:          "rotate",
-	Short:        "Re-encrypt managed secrets under a new master key",
-	Long:         "Re-encrypt every managed secret from the old master key (--master) to a new one (--new-master), in place. All-or-nothing: it verifies every secret decrypts before writing anything.",
+	Use:   "rotate [secret|dest...]",
+	Short: "Re-encrypt managed secrets under a new master key",
+	Long:  "Re-encrypt managed secrets in place under a new master key, or under the current one to upgrade legacy ciphertexts. Name secrets or destinations to rotate only those; recipient-encrypted secrets are always skipped. Every selected secret must decrypt before anything is written, and the write is journaled so an interrupted run can be resumed rather than left half-rotated. Only local sources can be rotated.",
+	Example: `# Check every secret decrypts and list what would be rewritten
+ws seed rotate --source ~/seed --master old.key --dry-run
+
+# Rotate two secrets only, by name or destination; --context bindings are kept
+ws seed rotate API_TOKEN ~/.netrc --source ~/seed --master old.key --new-master new.key
+
+# Finish a rotation that was interrupted part-way
+ws seed rotate --source ~/seed --master old.key --new-master new.key --resume
+
+# Re-encrypt legacy or outdated ciphertexts under the current key
+ws seed rotate --source ~/seed --master current.key --upgrade
+
+# Recipient-encrypted secrets are skipped; revoke access by recipient instead
+ws secrets recipients remove wspub1:… --source ~/seed`,
 	SilenceUsage: true,
 	Annotations:  map[string]string{"since": "next"},
 	RunE:         runRotate,
@@ -18,8 +34,15 @@ func runRotate(cmd *cobra.Command, args []string) error {
 	source, _ := cmd.Flags().GetString("source")
 	master, _ := cmd.Flags().GetString("master")
 	newMaster, _ := cmd.Flags().GetString("new-master")
+	upgrade, _ := cmd.Flags().GetBool("upgrade")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	resume, _ := cmd.Flags().GetBool("resume")
+
+	if resume && (dryRun || len(args) > 0) {
+		return fmt.Errorf("--resume cannot be combined with --dry-run or a subset")
+	}
 
-	resolved, err := seed.ResolveSource(source)
+	resolved, err := seed.ResolveLocalSource(source)
 	if err != nil {
 		return err
 	}
@@ -28,6 +51,10 @@ func runRotate(cmd *cobra.Command, args []string) error {
 		Source:       resolved,
 		MasterKey:    master,
 		NewMasterKey: newMaster,
+		Only:         args,
+		Upgrade:      upgrade,
+		DryRun:       dryRun,
+		Resume:       resume,
 		Out:          cmd.OutOrStdout(),
 		Styled:       isTerminal(cmd.OutOrStdout()),
 	})
@@ -36,6 +63,9 @@ func runRotate(cmd *cobra.Command, args []string) error {
 func init() {
 	rotateCmd.Flags().String("master", "", "Current master key or path to key file")
 	rotateCmd.Flags().String("new-master", "", "New master key or path to key file")
+	rotateCmd.Flags().Bool("upgrade", false, "Re-encrypt only legacy salt$ciphertext values and envelopes with outdated KDF parameters, keeping the current key")
+	rotateCmd.Flags().Bool("dry-run", false, "Decrypt the selected secrets and list what would be rewritten, without needing --new-master")
+	rotateCmd.Flags().Bool("resume", false, "Complete an interrupted rotation from the journal recorded under the seed state directory")
 
 	SeedCmd.AddCommand(rotateCmd)
 }
@@ -8,17 +8,46 @@ import (
 var SeedCmd = &cobra.Command{
 	Use:         "seed",
 	Short:       "Project declarative content onto the filesystem",
-	Long:        "Copy files and apply small edits from a seed source onto the filesystem at boot. Bare files mirror verbatim; a .seed.yaml manifest overlays behavior — copy, merge, append — and decrypts secrets under the master key. Point --source at a mounted volume to seed a container from durable storage.",
+	Long:        "Copy files and apply small edits from a seed source onto the filesystem at boot. Bare files and symlinks mirror verbatim; a .seed.yaml manifest overlays behavior — copy, merge, append, symlink, directory — and decrypts secrets under the master key. Point --source at a mounted volume to seed a container from durable storage.",
 	Annotations: map[string]string{"since": "next"},
 	Example: `# Preview what apply would write
 ws seed ls --source /mnt/seed
 
+# Review the changes apply would make
+ws seed apply --source /mnt/seed --dry-run
+
 # Apply it, overwriting existing destinations
-ws seed apply --source /mnt/seed --force`,
+ws seed apply --source /mnt/seed --force
+
+# Layer a team overlay and personal overrides on the org baseline;
+# later layers replace, or merge and edit on top of, earlier entries
+ws seed ls --source /mnt/org:/mnt/team:~/.ws/seed
+
+# Seed from a git repository, pinned to a tag or commit (git+https://…,
+# git@host:repo or a .git URL); remote layers are cached in the state directory
+ws seed apply --source git+https://git.example.com/org/seed.git#v1.4.0
+
+# Seed from a .tar.gz or .zip archive; HTTP archives need #sha256=<digest>
+ws seed apply --source https://example.com/seed.tar.gz#sha256=9f86d0…
+
+# Keep destinations in sync while editing the seed source
+ws seed apply --source ~/seed --force --watch
+
+# Undo the last apply
+ws seed rollback
+
+# Capture an existing dotfile into the seed source
+ws seed add ~/.config/starship.toml --op merge
+
+# Lint a seed source in CI, checking secrets against the key
+ws seed validate --source . --master /run/secrets/master.key
+
+# Include the entries scoped to the backend profile
+ws seed apply --profile backend`,
 }
 
 func init() {
 	source, _ := config.Resolve("seed", "source")
 
-	SeedCmd.PersistentFlags().String("source", source, "Seed source directory")
+	SeedCmd.PersistentFlags().String("source", source, "Seed source directories, separated by ':' and lowest precedence first")
 }
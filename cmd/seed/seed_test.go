@@ -2,12 +2,14 @@ package seed
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/kloudkit/ws-cli/internals/secrets"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"gotest.tools/v3/assert"
@@ -15,7 +17,11 @@ import (
 
 func resetCommandFlags(cmd *cobra.Command) {
 	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
-		flag.Value.Set(flag.DefValue)
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
 		flag.Changed = false
 	})
 
@@ -42,6 +48,7 @@ func TestSeedCommand(t *testing.T) {
 	t.Run("Apply", func(t *testing.T) {
 		t.Setenv("HOME", t.TempDir())
 		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
 
 		source := t.TempDir()
 		target := t.TempDir()
@@ -58,9 +65,29 @@ func TestSeedCommand(t *testing.T) {
 		assert.Assert(t, strings.Contains(output, "Seeded ["+dest+"]"))
 	})
 
+	t.Run("DryRun", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		manifest := fmt.Sprintf("version: v1\nseeds:\n  %s:\n    mode: \"0o644\"\n    content: \"cli\\n\"\n", dest)
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		output := run(t, "apply", "--source", source, "--dry-run")
+
+		_, err := os.Stat(dest)
+		assert.Assert(t, os.IsNotExist(err))
+		assert.Assert(t, strings.Contains(output, "+cli"))
+	})
+
 	t.Run("List", func(t *testing.T) {
 		t.Setenv("HOME", t.TempDir())
 		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
 
 		source := t.TempDir()
 		target := t.TempDir()
@@ -74,4 +101,174 @@ func TestSeedCommand(t *testing.T) {
 		assert.Assert(t, strings.Contains(output, dest))
 		assert.Assert(t, strings.Contains(output, "secret"))
 	})
+
+	t.Run("ListJSON", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		manifest := fmt.Sprintf("version: v1\nseeds:\n  %s:\n    secret: true\n    mode: \"0o600\"\n", dest)
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		var entries []lsEntry
+		assert.NilError(t, json.Unmarshal([]byte(run(t, "ls", "--source", source, "--output", "json")), &entries))
+
+		assert.Equal(t, len(entries), 1)
+		assert.Equal(t, entries[0].Dest, dest)
+		assert.Equal(t, entries[0].State, "present")
+		assert.Equal(t, entries[0].Mode, "0o600")
+		assert.Assert(t, entries[0].Secret)
+	})
+
+	t.Run("ListLayers", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		org := t.TempDir()
+		team := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		for _, layer := range []string{org, team} {
+			assert.NilError(t, os.MkdirAll(filepath.Join(layer, target), 0o755))
+			assert.NilError(t, os.WriteFile(filepath.Join(layer, dest), []byte("x\n"), 0o644))
+		}
+
+		output := run(t, "ls", "--source", org+string(filepath.ListSeparator)+team)
+
+		assert.Assert(t, strings.Contains(output, fmt.Sprintf("copy (%s, overrides %s)", team, org)))
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		manifest := fmt.Sprintf("version: v1\nseeds:\n  %s:\n    mode: \"0o644\"\n    content: \"cli\\n\"\n", dest)
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		assert.Assert(t, strings.Contains(run(t, "status", "--source", source), "never-applied"))
+
+		run(t, "apply", "--source", source)
+
+		assert.Assert(t, strings.Contains(run(t, "status", "--source", source), "in-sync"))
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		manifest := fmt.Sprintf("version: v1\nseeds:\n  %s:\n    profiles: [backend]\n    content: \"cli\\n\"\n", dest)
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		assert.Assert(t, !strings.Contains(run(t, "ls", "--source", source), dest))
+
+		run(t, "apply", "--source", source)
+		_, err := os.Stat(dest)
+		assert.Assert(t, os.IsNotExist(err))
+
+		output := run(t, "apply", "--source", source, "--profile", "backend")
+		assert.Assert(t, strings.Contains(output, "Seeded ["+dest+"]"))
+	})
+	t.Run("Validate", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		source := t.TempDir()
+
+		manifest := "version: v1\nseeds:\n  ${ws_home}/out.txt:\n    content: x\n"
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		assert.Assert(t, strings.Contains(run(t, "validate", "--source", source), "Seed source is valid"))
+
+		manifest += "    colour: red\n"
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		resetCommandFlags(SeedCmd)
+		buffer := new(bytes.Buffer)
+		SeedCmd.SetOut(buffer)
+		SeedCmd.SetErr(buffer)
+		SeedCmd.SetArgs([]string{"validate", "--source", source})
+
+		assert.ErrorContains(t, SeedCmd.Execute(), "1 problem found")
+		assert.Assert(t, strings.Contains(buffer.String(), `.seed.yaml:5:5: unknown key "colour"`))
+	})
+	t.Run("Add", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		source := t.TempDir()
+		dest := filepath.Join(home, ".gitconfig")
+		assert.NilError(t, os.WriteFile(dest, []byte("[user]\n  name = dev\n"), 0o644))
+
+		output := run(t, "add", dest, "--source", source, "--op", "merge")
+		assert.Assert(t, strings.Contains(output, "Added ["+dest+"]"))
+
+		manifest, err := os.ReadFile(filepath.Join(source, ".seed.yaml"))
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(string(manifest), "${ws_home}/.gitconfig:\n    op: merge\n"))
+	})
+	t.Run("Rollback", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		assert.NilError(t, os.WriteFile(dest, []byte("before\n"), 0o644))
+		assert.NilError(t, os.MkdirAll(filepath.Join(source, target), 0o755))
+		assert.NilError(t, os.WriteFile(filepath.Join(source, dest), []byte("after\n"), 0o644))
+
+		assert.Assert(t, strings.Contains(run(t, "rollback", "--list"), "No seed backups"))
+
+		run(t, "apply", "--source", source, "--force")
+		assert.Assert(t, strings.Contains(run(t, "rollback", "--list"), "1 destination"))
+
+		run(t, "rollback", dest)
+
+		got, err := os.ReadFile(dest)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "before\n")
+	})
+	t.Run("RotateDryRun", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
+
+		const master = "ws-seed-cli-master-key-0123456789"
+
+		key, err := secrets.ResolveMasterKey(master)
+		assert.NilError(t, err)
+		encrypted, err := secrets.Encrypt([]byte("V"), key)
+		assert.NilError(t, err)
+
+		source := t.TempDir()
+		manifest := fmt.Sprintf("version: v1\nsecrets:\n  TOK: %s\n  OTHER: %s\n", encrypted, encrypted)
+		assert.NilError(t, os.WriteFile(filepath.Join(source, ".seed.yaml"), []byte(manifest), 0o644))
+
+		output := run(t, "rotate", "TOK", "--source", source, "--master", master, "--dry-run")
+
+		assert.Assert(t, strings.Contains(output, `Would rotate secret "TOK"`))
+		assert.Assert(t, !strings.Contains(output, "OTHER"))
+	})
 }
@@ -0,0 +1,62 @@
+package seed
+
+import (
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/kloudkit/ws-cli/internals/styles"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:         "status",
+	Short:       "Report drift between seeded destinations and the filesystem",
+	Long:        "Compare each destination in the seed plan against the ledger apply records — in-sync, drifted (edited since it was seeded), missing, or never-applied — and flag entries whose source changed since the last apply. Read-only; use it to decide when --force is safe.",
+	Annotations: map[string]string{"since": "next"},
+	RunE:        runStatus,
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	source, _ := cmd.Flags().GetString("source")
+
+	resolved, err := seed.ResolveSource(source)
+	if err != nil {
+		return err
+	}
+
+	plan, err := seed.BuildPlan(resolved, false)
+	if err != nil {
+		return err
+	}
+
+	profiles, _ := cmd.Flags().GetStringSlice("profile")
+	if err := plan.Select(profiles); err != nil {
+		return err
+	}
+
+	ledger, err := seed.LoadLedger(seed.LedgerPath())
+	if err != nil {
+		return err
+	}
+
+	statuses, err := plan.Status(ledger)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, status := range statuses {
+		state := string(status.State)
+		if status.SourceChanged {
+			state += " (source changed)"
+		}
+
+		styles.PrintKeyValue(out, status.Dest, state)
+	}
+
+	return nil
+}
+
+func init() {
+	statusCmd.Flags().StringSlice("profile", nil, "Include entries scoped to this profile")
+
+	SeedCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,67 @@
+package seed
+
+import (
+	"fmt"
+
+	"github.com/kloudkit/ws-cli/internals/seed"
+	"github.com/kloudkit/ws-cli/internals/styles"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a seed source for errors without applying it",
+	Long:  "Lint a seed source offline — manifest keys and entries, templates, secrets, merge fragments and destinations — printing each problem as file:line: message. Exits non-zero when any are found, so it can gate a seed repository's CI.",
+	Example: `# Lint a local checkout; remote layers are not fetched
+ws seed validate --source .
+
+# Also check that every secret decrypts with the master key
+ws seed validate --source . --master /run/secrets/master.key
+
+# Check secrets encrypted for recipients with your identity
+ws seed validate --source . --identity ~/.ws/identity`,
+	SilenceUsage: true,
+	Annotations:  map[string]string{"since": "next"},
+	RunE:         runValidate,
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	source, _ := cmd.Flags().GetString("source")
+	master, _ := cmd.Flags().GetString("master")
+	identity, _ := cmd.Flags().GetString("identity")
+
+	resolved, err := seed.ResolveLocalSource(source)
+	if err != nil {
+		return err
+	}
+
+	issues, err := seed.Validate(seed.ValidateOptions{Source: resolved, MasterKey: master, Identity: identity})
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, issue := range issues {
+		fmt.Fprintln(out, issue)
+	}
+
+	if len(issues) > 0 {
+		noun := "problems"
+		if len(issues) == 1 {
+			noun = "problem"
+		}
+
+		return fmt.Errorf("%d %s found", len(issues), noun)
+	}
+
+	styles.PrintSuccess(out, "Seed source is valid")
+
+	return nil
+}
+
+func init() {
+	validateCmd.Flags().String("master", "", "Master key or path to key file used to check secrets")
+	validateCmd.Flags().String("identity", "", "Recipient identity or path to identity file used to check secrets")
+
+	SeedCmd.AddCommand(validateCmd)
+}
@@ -2,6 +2,11 @@ package editor
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/kloudkit/ws-cli/internals/net"
 )
@@ -97,6 +102,39 @@ func Open(req OpenRequest) error {
 	return err
 }
 
+func WaitClosed(ctx context.Context, path string, interval, appear time.Duration) error {
+	seen := false
+	deadline := time.Now().Add(appear)
+
+	for {
+		body, err := FetchEditors()
+		if err != nil {
+			return err
+		}
+
+		var tabs []Tab
+		if err := json.Unmarshal(body, &tabs); err != nil {
+			return fmt.Errorf("error parsing editor response: %w", err)
+		}
+
+		open := slices.ContainsFunc(tabs, func(tab Tab) bool { return tab.Path == path })
+		if seen && !open {
+			return nil
+		}
+		seen = seen || open
+
+		if !seen && time.Now().After(deadline) {
+			return fmt.Errorf("editor tab for %s did not open within %s", path, appear)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
 func Notify(req NotifyRequest) ([]byte, error) {
 	envelope := map[string]any{"type": "notify", "message": req.Message}
 
@@ -2,6 +2,7 @@ package editor_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/kloudkit/ws-cli/internals/editor"
 	"gotest.tools/v3/assert"
@@ -178,6 +180,36 @@ func TestNotifyOmitsEmptyOptionals(t *testing.T) {
 	assert.Assert(t, !hasTimeout)
 }
 
+func TestWaitClosed(t *testing.T) {
+	polls := 0
+	startPipe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, envelopeOf(t, r)["type"], "editorList")
+		polls++
+
+		switch {
+		case polls == 1:
+			_, _ = w.Write([]byte("[]"))
+		case polls < 4:
+			_, _ = w.Write([]byte(`[{"path":"/dev/shm/secret","languageId":null,"active":true,"dirty":true}]`))
+		default:
+			_, _ = w.Write([]byte(`[{"path":"/workspace/main.go","languageId":"go","active":true,"dirty":false}]`))
+		}
+	}))
+
+	err := editor.WaitClosed(context.Background(), "/dev/shm/secret", time.Millisecond, time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, polls, 4)
+}
+
+func TestWaitClosedTabNeverOpens(t *testing.T) {
+	startPipe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	}))
+
+	err := editor.WaitClosed(context.Background(), "/dev/shm/secret", time.Millisecond, 20*time.Millisecond)
+	assert.ErrorContains(t, err, "did not open within 20ms")
+}
+
 func TestErrorResponseSurfacesBody(t *testing.T) {
 	startPipe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -8,7 +8,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -20,15 +23,55 @@ const (
 	Argon2KeyLen  = 32
 	SaltLen       = 16
 	NonceLen      = 12
+
+	EnvelopeVersion = "ws1"
+	EnvelopeKDF     = "argon2id"
+	EnvelopeBound   = "ad"
+
+	contextPrefix = "ws1 context:"
+
+	maxArgon2Time   = 64
+	maxArgon2Memory = 2 * 1024 * 1024 // 2GB
+)
+
+type KDFParams struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+var (
+	DefaultKDFParams = KDFParams{Time: Argon2Time, Memory: Argon2Memory, Threads: Argon2Threads}
+	legacyKDFParams  = KDFParams{Time: Argon2Time, Memory: Argon2Memory, Threads: Argon2Threads}
 )
 
+type envelope struct {
+	params              KDFParams
+	legacy              bool
+	bound               bool
+	salt                []byte
+	cipherTextWithNonce []byte
+}
+
+func (p KDFParams) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Time, p.Threads)
+}
+
 func Encrypt(plainText []byte, masterKey []byte) (string, error) {
+	return encryptWith(plainText, masterKey, DefaultKDFParams, "")
+}
+
+func EncryptContext(plainText []byte, masterKey []byte, context string) (string, error) {
+	return encryptWith(plainText, masterKey, DefaultKDFParams, context)
+}
+
+func encryptWith(plainText []byte, masterKey []byte, params KDFParams, context string) (string, error) {
 	salt := make([]byte, SaltLen)
 	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
 		return "", fmt.Errorf("failed to generate salt: %w", err)
 	}
 
-	aesGCM, err := deriveKeyAndGCM(masterKey, salt, Argon2Time, Argon2Memory, Argon2Threads, Argon2KeyLen)
+	aesGCM, err := deriveKeyAndGCM(masterKey, salt, params.Time, params.Memory, params.Threads, Argon2KeyLen)
 	if err != nil {
 		return "", err
 	}
@@ -38,13 +81,35 @@ func Encrypt(plainText []byte, masterKey []byte) (string, error) {
 		return "", fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	cipherText := aesGCM.Seal(nonce, nonce, plainText, nil)
+	cipherText := aesGCM.Seal(nonce, nonce, plainText, associatedData(context))
 
-	return fmt.Sprintf("%s$%s",
+	header := fmt.Sprintf("$%s$%s$%s$", EnvelopeVersion, EnvelopeKDF, params)
+	if context != "" {
+		header += EnvelopeBound + "$"
+	}
+
+	return header + fmt.Sprintf("%s$%s",
 		base64.RawStdEncoding.EncodeToString(salt),
 		base64.RawStdEncoding.EncodeToString(cipherText)), nil
 }
 
+func IsBound(encodedValue string) bool {
+	if IsRecipientEncrypted(encodedValue) {
+		parsed, err := parseRecipientEnvelope(encodedValue)
+		return err == nil && parsed.bound
+	}
+
+	parsed, err := parseEnvelope(encodedValue)
+
+	return err == nil && parsed.bound
+}
+
+func NeedsUpgrade(encodedValue string) bool {
+	parsed, err := parseEnvelope(encodedValue)
+
+	return err == nil && (parsed.legacy || parsed.params != DefaultKDFParams)
+}
+
 func NormalizeEncrypted(encrypted string) string {
 	encrypted = strings.TrimSpace(encrypted)
 	encrypted = strings.ReplaceAll(encrypted, "\r", "")
@@ -69,32 +134,197 @@ func ResolveEncryptedValue(encrypted string) (string, error) {
 }
 
 func Decrypt(encodedValue string, masterKey []byte) ([]byte, error) {
-	parts := strings.Split(encodedValue, "$")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid encrypted format")
+	return DecryptContext(encodedValue, masterKey, "")
+}
+
+func DecryptContext(encodedValue string, masterKey []byte, context string) ([]byte, error) {
+	parsed, err := parseEnvelope(encodedValue)
+	if err != nil {
+		return nil, err
+	}
+
+	ad, err := boundData(parsed.bound, context)
+	if err != nil {
+		return nil, err
 	}
 
-	salt, err := base64.RawStdEncoding.DecodeString(parts[0])
+	aesGCM, err := parsed.derive(masterKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode salt: %w", err)
+		return nil, err
 	}
 
-	cipherTextWithNonce, err := base64.RawStdEncoding.DecodeString(parts[1])
+	return open(aesGCM, parsed.cipherTextWithNonce, ad)
+}
+
+type KeyCache struct {
+	masterKey []byte
+	mu        sync.Mutex
+	derived   map[string]*derivedKey
+}
+
+type derivedKey struct {
+	once   sync.Once
+	aesGCM cipher.AEAD
+	err    error
+}
+
+func NewKeyCache(masterKey []byte) *KeyCache {
+	return &KeyCache{masterKey: masterKey, derived: map[string]*derivedKey{}}
+}
+
+func (c *KeyCache) Decrypt(encodedValue string) ([]byte, error) {
+	return c.DecryptContext(encodedValue, "")
+}
+
+func (c *KeyCache) DecryptContext(encodedValue, context string) ([]byte, error) {
+	parsed, err := parseEnvelope(encodedValue)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode ciphertext: %w", err)
+		return nil, err
 	}
 
-	aesGCM, err := deriveKeyAndGCM(masterKey, salt, Argon2Time, Argon2Memory, Argon2Threads, Argon2KeyLen)
+	ad, err := boundData(parsed.bound, context)
 	if err != nil {
 		return nil, err
 	}
 
+	cacheKey := parsed.params.String() + "$" + string(parsed.salt)
+
+	c.mu.Lock()
+	derived, ok := c.derived[cacheKey]
+	if !ok {
+		derived = &derivedKey{}
+		c.derived[cacheKey] = derived
+	}
+	c.mu.Unlock()
+
+	derived.once.Do(func() {
+		derived.aesGCM, derived.err = parsed.derive(c.masterKey)
+	})
+
+	if derived.err != nil {
+		return nil, derived.err
+	}
+
+	return open(derived.aesGCM, parsed.cipherTextWithNonce, ad)
+}
+
+func parseEnvelope(encodedValue string) (envelope, error) {
+	parts := strings.Split(encodedValue, "$")
+
+	var parsed envelope
+	switch {
+	case len(parts) == 2:
+		parsed.params, parsed.legacy = legacyKDFParams, true
+	case len(parts) == 7 && parts[0] == "" && parts[4] == EnvelopeBound:
+		parsed.bound = true
+		parts = slices.Delete(parts, 4, 5)
+		fallthrough
+	case len(parts) == 6 && parts[0] == "":
+		if parts[1] != EnvelopeVersion {
+			return envelope{}, fmt.Errorf("unsupported encrypted format version %q", parts[1])
+		}
+
+		if parts[2] != EnvelopeKDF {
+			return envelope{}, fmt.Errorf("unsupported key derivation %q", parts[2])
+		}
+
+		params, err := parseKDFParams(parts[3])
+		if err != nil {
+			return envelope{}, err
+		}
+
+		parsed.params, parts = params, parts[4:]
+	case IsRecipientEncrypted(encodedValue):
+		return envelope{}, fmt.Errorf("value is encrypted for recipients; decrypt it with an identity")
+	default:
+		return envelope{}, fmt.Errorf("invalid encrypted format")
+	}
+
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[0]); err != nil {
+		return envelope{}, fmt.Errorf("failed to decode salt: %w", err)
+	}
+
+	if parsed.cipherTextWithNonce, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return envelope{}, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	return parsed, nil
+}
+
+func parseKDFParams(value string) (KDFParams, error) {
+	var params KDFParams
+	seen := map[string]bool{}
+
+	for field := range strings.SplitSeq(value, ",") {
+		name, raw, ok := strings.Cut(field, "=")
+		if !ok || seen[name] {
+			return KDFParams{}, fmt.Errorf("invalid key derivation parameters %q", value)
+		}
+		seen[name] = true
+
+		number, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return KDFParams{}, fmt.Errorf("invalid key derivation parameters %q", value)
+		}
+
+		switch name {
+		case "m":
+			params.Memory = uint32(number)
+		case "t":
+			params.Time = uint32(number)
+		case "p":
+			if number > 255 {
+				return KDFParams{}, fmt.Errorf("invalid key derivation parameters %q", value)
+			}
+			params.Threads = uint8(number)
+		default:
+			return KDFParams{}, fmt.Errorf("invalid key derivation parameters %q", value)
+		}
+	}
+
+	if len(seen) != 3 || params.Time == 0 || params.Threads == 0 {
+		return KDFParams{}, fmt.Errorf("invalid key derivation parameters %q", value)
+	}
+
+	if params.Time > maxArgon2Time || params.Memory > maxArgon2Memory || params.Memory < 8*uint32(params.Threads) {
+		return KDFParams{}, fmt.Errorf("key derivation parameters %q are out of range", value)
+	}
+
+	return params, nil
+}
+
+func (e envelope) derive(masterKey []byte) (cipher.AEAD, error) {
+	return deriveKeyAndGCM(masterKey, e.salt, e.params.Time, e.params.Memory, e.params.Threads, Argon2KeyLen)
+}
+
+func open(aesGCM cipher.AEAD, cipherTextWithNonce []byte, ad []byte) ([]byte, error) {
 	nonceSize := aesGCM.NonceSize()
 	if len(cipherTextWithNonce) < nonceSize {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
 
-	return aesGCM.Open(nil, cipherTextWithNonce[:nonceSize], cipherTextWithNonce[nonceSize:], nil)
+	return aesGCM.Open(nil, cipherTextWithNonce[:nonceSize], cipherTextWithNonce[nonceSize:], ad)
+}
+
+func associatedData(context string) []byte {
+	if context == "" {
+		return nil
+	}
+
+	return []byte(contextPrefix + context)
+}
+
+func boundData(bound bool, context string) ([]byte, error) {
+	if !bound {
+		return nil, nil
+	}
+
+	if context == "" {
+		return nil, fmt.Errorf("value is bound to a context; supply the context it was encrypted with")
+	}
+
+	return associatedData(context), nil
 }
 
 func deriveKeyAndGCM(masterKey, salt []byte, time, memory uint32, threads uint8, keyLen uint32) (cipher.AEAD, error) {
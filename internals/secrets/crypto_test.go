@@ -13,13 +13,153 @@ func TestEncryptDecrypt(t *testing.T) {
 
 	encrypted, err := Encrypt([]byte(plainText), masterKey)
 	assert.NilError(t, err)
-	assert.Assert(t, strings.Count(encrypted, "$") == 1)
+	assert.Assert(t, strings.HasPrefix(encrypted, "$ws1$argon2id$m=65536,t=3,p=4$"))
+	assert.Equal(t, strings.Count(encrypted, "$"), 5)
 
 	decrypted, err := Decrypt(encrypted, masterKey)
 	assert.NilError(t, err)
 	assert.Equal(t, plainText, string(decrypted))
 }
 
+func TestEnvelope(t *testing.T) {
+	masterKey := []byte("12345678901234567890123456789012")
+
+	t.Run("LegacyFormat", func(t *testing.T) {
+		encrypted, err := Encrypt([]byte("legacy"), masterKey)
+		assert.NilError(t, err)
+
+		parts := strings.Split(encrypted, "$")
+		legacy := parts[4] + "$" + parts[5]
+
+		decrypted, err := Decrypt(legacy, masterKey)
+		assert.NilError(t, err)
+		assert.Equal(t, string(decrypted), "legacy")
+
+		decrypted, err = NewKeyCache(masterKey).Decrypt(legacy)
+		assert.NilError(t, err)
+		assert.Equal(t, string(decrypted), "legacy")
+
+		assert.Assert(t, NeedsUpgrade(legacy))
+		assert.Assert(t, !NeedsUpgrade(encrypted))
+	})
+
+	t.Run("EmbeddedParams", func(t *testing.T) {
+		params := KDFParams{Time: 1, Memory: 1024, Threads: 1}
+
+		encrypted, err := encryptWith([]byte("cheap"), masterKey, params, "")
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(encrypted, "$ws1$argon2id$m=1024,t=1,p=1$"))
+
+		decrypted, err := Decrypt(encrypted, masterKey)
+		assert.NilError(t, err)
+		assert.Equal(t, string(decrypted), "cheap")
+
+		assert.Assert(t, NeedsUpgrade(encrypted))
+	})
+
+	t.Run("ParamsAreAuthenticatedByDerivation", func(t *testing.T) {
+		encrypted, err := encryptWith([]byte("data"), masterKey, KDFParams{Time: 1, Memory: 1024, Threads: 1}, "")
+		assert.NilError(t, err)
+
+		tampered := strings.Replace(encrypted, "t=1", "t=2", 1)
+
+		_, err = Decrypt(tampered, masterKey)
+		assert.ErrorContains(t, err, "message authentication failed")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			encoded       string
+			errorContains string
+		}{
+			{"version", "$ws9$argon2id$m=1024,t=1,p=1$c2FsdA$Y3Q", `unsupported encrypted format version "ws9"`},
+			{"kdf", "$ws1$scrypt$m=1024,t=1,p=1$c2FsdA$Y3Q", `unsupported key derivation "scrypt"`},
+			{"missing param", "$ws1$argon2id$m=1024,t=1$c2FsdA$Y3Q", "invalid key derivation parameters"},
+			{"unknown param", "$ws1$argon2id$m=1024,t=1,p=1,x=2$c2FsdA$Y3Q", "invalid key derivation parameters"},
+			{"zero time", "$ws1$argon2id$m=1024,t=0,p=1$c2FsdA$Y3Q", "invalid key derivation parameters"},
+			{"huge memory", "$ws1$argon2id$m=99999999,t=1,p=1$c2FsdA$Y3Q", "out of range"},
+			{"parts", "$ws1$argon2id$c2FsdA$Y3Q", "invalid encrypted format"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := Decrypt(tt.encoded, masterKey)
+				assert.ErrorContains(t, err, tt.errorContains)
+				assert.Assert(t, !NeedsUpgrade(tt.encoded))
+			})
+		}
+	})
+}
+
+func TestContextBinding(t *testing.T) {
+	masterKey := []byte("12345678901234567890123456789012")
+
+	bound, err := EncryptContext([]byte("token"), masterKey, "secrets.API_TOKEN")
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(bound, "$ws1$argon2id$m=65536,t=3,p=4$ad$"))
+	assert.Assert(t, IsBound(bound))
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		decrypted, err := DecryptContext(bound, masterKey, "secrets.API_TOKEN")
+		assert.NilError(t, err)
+		assert.Equal(t, string(decrypted), "token")
+
+		decrypted, err = NewKeyCache(masterKey).DecryptContext(bound, "secrets.API_TOKEN")
+		assert.NilError(t, err)
+		assert.Equal(t, string(decrypted), "token")
+	})
+
+	t.Run("SwappedContextFails", func(t *testing.T) {
+		_, err := DecryptContext(bound, masterKey, "secrets.DB_PASSWORD")
+		assert.ErrorContains(t, err, "message authentication failed")
+	})
+
+	t.Run("MissingContextFails", func(t *testing.T) {
+		_, err := Decrypt(bound, masterKey)
+		assert.ErrorContains(t, err, "bound to a context")
+	})
+
+	t.Run("UnboundIgnoresContext", func(t *testing.T) {
+		unbound, err := Encrypt([]byte("plain"), masterKey)
+		assert.NilError(t, err)
+		assert.Assert(t, !IsBound(unbound))
+
+		decrypted, err := DecryptContext(unbound, masterKey, "secrets.ANY")
+		assert.NilError(t, err)
+		assert.Equal(t, string(decrypted), "plain")
+	})
+
+	t.Run("MarkerCannotBeStripped", func(t *testing.T) {
+		stripped := strings.Replace(bound, "$ad$", "$", 1)
+
+		_, err := Decrypt(stripped, masterKey)
+		assert.ErrorContains(t, err, "message authentication failed")
+	})
+}
+
+func TestKeyCache(t *testing.T) {
+	masterKey := make([]byte, 32)
+
+	first, err := Encrypt([]byte("one"), masterKey)
+	assert.NilError(t, err)
+	second, err := Encrypt([]byte("two"), masterKey)
+	assert.NilError(t, err)
+
+	cache := NewKeyCache(masterKey)
+
+	for _, tt := range []struct{ encoded, want string }{{first, "one"}, {second, "two"}, {first, "one"}} {
+		decrypted, err := cache.Decrypt(tt.encoded)
+		assert.NilError(t, err)
+		assert.Equal(t, string(decrypted), tt.want)
+	}
+
+	assert.Equal(t, len(cache.derived), 2)
+
+	_, err = NewKeyCache([]byte("22345678901234567890123456789012")).Decrypt(first)
+	assert.ErrorContains(t, err, "message authentication failed")
+}
+
 func TestDecryptErrors(t *testing.T) {
 	tests := []struct {
 		name          string
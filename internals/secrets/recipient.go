@@ -0,0 +1,448 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kloudkit/ws-cli/internals/config"
+	"github.com/kloudkit/ws-cli/internals/env"
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+)
+
+const (
+	RecipientEnvelopeVersion = "ws1r"
+	RecipientKDF             = "x25519"
+	RecipientPrefix          = "wspub1:"
+	IdentityPrefix           = "wssec1:"
+	DataKeyLen               = 32
+
+	wrapInfo        = "ws1r x25519 data key"
+	recipientIDSize = 8
+)
+
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+type stanza struct {
+	id        string
+	ephemeral []byte
+	wrapped   []byte
+}
+
+type recipientEnvelope struct {
+	bound   bool
+	stanzas []stanza
+	payload []byte
+}
+
+func GenerateIdentity() (Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to generate identity: %w", err)
+	}
+
+	return Identity{key: key}, nil
+}
+
+func ParseIdentity(value string) (Identity, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(value), IdentityPrefix)
+	if !ok {
+		return Identity{}, fmt.Errorf("invalid identity (expected %s…)", IdentityPrefix)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid identity: %w", err)
+	}
+	defer zeroBytes(raw)
+
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid identity: %w", err)
+	}
+
+	return Identity{key: key}, nil
+}
+
+func ParseRecipient(value string) (Recipient, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(value), RecipientPrefix)
+	if !ok {
+		return Recipient{}, fmt.Errorf("invalid recipient %q (expected %s…)", value, RecipientPrefix)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Recipient{}, fmt.Errorf("invalid recipient %q: %w", value, err)
+	}
+
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return Recipient{}, fmt.Errorf("invalid recipient %q: %w", value, err)
+	}
+
+	return Recipient{key: key}, nil
+}
+
+func ParseRecipients(values []string) ([]Recipient, error) {
+	recipients := make([]Recipient, 0, len(values))
+	for _, value := range values {
+		recipient, err := ParseRecipient(value)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+func (i Identity) String() string {
+	return IdentityPrefix + base64.RawURLEncoding.EncodeToString(i.key.Bytes())
+}
+
+func (i Identity) Recipient() Recipient {
+	return Recipient{key: i.key.PublicKey()}
+}
+
+func (r Recipient) String() string {
+	return RecipientPrefix + base64.RawURLEncoding.EncodeToString(r.key.Bytes())
+}
+
+func (r Recipient) ID() string {
+	sum := sha256.Sum256(r.key.Bytes())
+
+	return hex.EncodeToString(sum[:recipientIDSize])
+}
+
+func DefaultIdentityPath() string {
+	return filepath.Join(env.Home(), ".ws", "identity")
+}
+
+func ResolveIdentity(flagValue string) (Identity, error) {
+	value := flagValue
+	if value == "" {
+		value, _ = config.Resolve("secrets", "identity")
+	}
+
+	if value == "" && internalIO.FileExists(DefaultIdentityPath()) {
+		value = DefaultIdentityPath()
+	}
+
+	if value == "" {
+		return Identity{}, fmt.Errorf(
+			"identity not found (use --identity, WS_SECRETS_IDENTITY=<value|path>, " +
+				"or create ~/.ws/identity with ws secrets keygen)",
+		)
+	}
+
+	if !strings.HasPrefix(value, IdentityPrefix) && internalIO.FileExists(value) {
+		return readIdentityFile(value)
+	}
+
+	return ParseIdentity(value)
+}
+
+func readIdentityFile(filePath string) (Identity, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to read identity file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		return ParseIdentity(line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Identity{}, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	return Identity{}, fmt.Errorf("identity file %s has no identity", filePath)
+}
+
+func IsRecipientEncrypted(encodedValue string) bool {
+	return strings.HasPrefix(encodedValue, "$"+RecipientEnvelopeVersion+"$")
+}
+
+func EncryptFor(plainText []byte, recipients []Recipient) (string, error) {
+	return EncryptForContext(plainText, recipients, "")
+}
+
+func EncryptForContext(plainText []byte, recipients []Recipient, context string) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("at least one recipient is required")
+	}
+
+	dataKey := make([]byte, DataKeyLen)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer zeroBytes(dataKey)
+
+	aesGCM, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	stanzas, err := wrapFor(dataKey, recipients)
+	if err != nil {
+		return "", err
+	}
+
+	return recipientEnvelope{
+		bound:   context != "",
+		stanzas: stanzas,
+		payload: aesGCM.Seal(nonce, nonce, plainText, associatedData(context)),
+	}.String(), nil
+}
+
+func DecryptWith(encodedValue string, identity Identity) ([]byte, error) {
+	return DecryptWithContext(encodedValue, identity, "")
+}
+
+func DecryptWithContext(encodedValue string, identity Identity, context string) ([]byte, error) {
+	parsed, err := parseRecipientEnvelope(encodedValue)
+	if err != nil {
+		return nil, err
+	}
+
+	ad, err := boundData(parsed.bound, context)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := parsed.unwrap(identity)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(dataKey)
+
+	aesGCM, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(aesGCM, parsed.payload, ad)
+}
+
+func Rewrap(encodedValue string, identity Identity, recipients []Recipient) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("at least one recipient is required")
+	}
+
+	parsed, err := parseRecipientEnvelope(encodedValue)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := parsed.unwrap(identity)
+	if err != nil {
+		return "", err
+	}
+	defer zeroBytes(dataKey)
+
+	if parsed.stanzas, err = wrapFor(dataKey, recipients); err != nil {
+		return "", err
+	}
+
+	return parsed.String(), nil
+}
+
+func RecipientIDs(encodedValue string) ([]string, error) {
+	parsed, err := parseRecipientEnvelope(encodedValue)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(parsed.stanzas))
+	for _, s := range parsed.stanzas {
+		ids = append(ids, s.id)
+	}
+
+	return ids, nil
+}
+
+func wrapFor(dataKey []byte, recipients []Recipient) ([]stanza, error) {
+	stanzas := make([]stanza, 0, len(recipients))
+
+	for _, recipient := range recipients {
+		if slices.ContainsFunc(stanzas, func(s stanza) bool { return s.id == recipient.ID() }) {
+			continue
+		}
+
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+		}
+
+		aesGCM, err := wrapKey(ephemeral, recipient.key, ephemeral.PublicKey().Bytes(), recipient.key.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		nonce := make([]byte, aesGCM.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+
+		stanzas = append(stanzas, stanza{
+			id:        recipient.ID(),
+			ephemeral: ephemeral.PublicKey().Bytes(),
+			wrapped:   aesGCM.Seal(nonce, nonce, dataKey, nil),
+		})
+	}
+
+	return stanzas, nil
+}
+
+func (e recipientEnvelope) unwrap(identity Identity) ([]byte, error) {
+	id := identity.Recipient().ID()
+	failure := fmt.Errorf("identity %s is not a recipient of this secret", id)
+
+	for _, s := range e.stanzas {
+		if s.id != id {
+			continue
+		}
+
+		ephemeral, err := ecdh.X25519().NewPublicKey(s.ephemeral)
+		if err != nil {
+			failure = fmt.Errorf("invalid recipient stanza: %w", err)
+			continue
+		}
+
+		aesGCM, err := wrapKey(identity.key, ephemeral, s.ephemeral, identity.key.PublicKey().Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		dataKey, err := open(aesGCM, s.wrapped, nil)
+		if err == nil {
+			return dataKey, nil
+		}
+
+		failure = fmt.Errorf("identity %s could not unwrap the data key: %w", id, err)
+	}
+
+	return nil, failure
+}
+
+func wrapKey(private *ecdh.PrivateKey, public *ecdh.PublicKey, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wrap key: %w", err)
+	}
+	defer zeroBytes(shared)
+
+	key, err := hkdf.Key(sha256.New, shared, slices.Concat(ephemeral, recipient), wrapInfo, DataKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wrap key: %w", err)
+	}
+	defer zeroBytes(key)
+
+	return newGCM(key)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func parseRecipientEnvelope(encodedValue string) (recipientEnvelope, error) {
+	parts := strings.Split(encodedValue, "$")
+
+	var parsed recipientEnvelope
+	if len(parts) == 6 && parts[3] == EnvelopeBound {
+		parsed.bound = true
+		parts = slices.Delete(parts, 3, 4)
+	}
+
+	if len(parts) != 5 || parts[0] != "" || parts[1] != RecipientEnvelopeVersion {
+		return recipientEnvelope{}, fmt.Errorf("invalid recipient encrypted format")
+	}
+
+	if parts[2] != RecipientKDF {
+		return recipientEnvelope{}, fmt.Errorf("unsupported recipient key type %q", parts[2])
+	}
+
+	for field := range strings.SplitSeq(parts[3], ";") {
+		id, rest, ok := strings.Cut(field, ".")
+		ephemeral, wrapped, ok2 := strings.Cut(rest, ".")
+		if !ok || !ok2 {
+			return recipientEnvelope{}, fmt.Errorf("invalid recipient stanza")
+		}
+
+		s := stanza{id: id}
+
+		var err error
+		if s.ephemeral, err = base64.RawStdEncoding.DecodeString(ephemeral); err != nil {
+			return recipientEnvelope{}, fmt.Errorf("invalid recipient stanza: %w", err)
+		}
+
+		if s.wrapped, err = base64.RawStdEncoding.DecodeString(wrapped); err != nil {
+			return recipientEnvelope{}, fmt.Errorf("invalid recipient stanza: %w", err)
+		}
+
+		parsed.stanzas = append(parsed.stanzas, s)
+	}
+
+	payload, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return recipientEnvelope{}, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	parsed.payload = payload
+
+	return parsed, nil
+}
+
+func (e recipientEnvelope) String() string {
+	stanzas := make([]string, 0, len(e.stanzas))
+	for _, s := range e.stanzas {
+		stanzas = append(stanzas, fmt.Sprintf("%s.%s.%s",
+			s.id,
+			base64.RawStdEncoding.EncodeToString(s.ephemeral),
+			base64.RawStdEncoding.EncodeToString(s.wrapped),
+		))
+	}
+
+	header := fmt.Sprintf("$%s$%s$", RecipientEnvelopeVersion, RecipientKDF)
+	if e.bound {
+		header += EnvelopeBound + "$"
+	}
+
+	return header + fmt.Sprintf("%s$%s", strings.Join(stanzas, ";"),
+		base64.RawStdEncoding.EncodeToString(e.payload))
+}
@@ -0,0 +1,171 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func identity(t *testing.T) Identity {
+	t.Helper()
+
+	generated, err := GenerateIdentity()
+	assert.NilError(t, err)
+
+	return generated
+}
+
+func TestRecipientKeys(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		generated := identity(t)
+
+		parsed, err := ParseIdentity(generated.String())
+		assert.NilError(t, err)
+		assert.Equal(t, parsed.String(), generated.String())
+
+		recipient, err := ParseRecipient(generated.Recipient().String())
+		assert.NilError(t, err)
+		assert.Equal(t, recipient.ID(), generated.Recipient().ID())
+		assert.Equal(t, len(recipient.ID()), 16)
+		assert.Assert(t, strings.HasPrefix(recipient.String(), RecipientPrefix))
+	})
+
+	t.Run("InvalidRecipient", func(t *testing.T) {
+		_, err := ParseRecipient("ssh-ed25519 AAAA")
+		assert.ErrorContains(t, err, "expected wspub1:")
+
+		_, err = ParseRecipient(RecipientPrefix + "short")
+		assert.ErrorContains(t, err, "invalid recipient")
+	})
+
+	t.Run("ResolveFromFile", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+		t.Setenv("WS_SECRETS_IDENTITY", "")
+
+		_, err := ResolveIdentity("")
+		assert.ErrorContains(t, err, "identity not found")
+
+		generated := identity(t)
+		content := "# public key: " + generated.Recipient().String() + "\n" + generated.String() + "\n"
+		assert.NilError(t, os.MkdirAll(filepath.Dir(DefaultIdentityPath()), 0o700))
+		assert.NilError(t, os.WriteFile(DefaultIdentityPath(), []byte(content), 0o600))
+
+		resolved, err := ResolveIdentity("")
+		assert.NilError(t, err)
+		assert.Equal(t, resolved.String(), generated.String())
+
+		resolved, err = ResolveIdentity(generated.String())
+		assert.NilError(t, err)
+		assert.Equal(t, resolved.String(), generated.String())
+	})
+}
+
+func TestRecipientEncryption(t *testing.T) {
+	alice, bob, carol := identity(t), identity(t), identity(t)
+
+	t.Run("EveryRecipientDecrypts", func(t *testing.T) {
+		encrypted, err := EncryptFor([]byte("shared"), []Recipient{alice.Recipient(), bob.Recipient()})
+		assert.NilError(t, err)
+		assert.Assert(t, IsRecipientEncrypted(encrypted))
+
+		for _, who := range []Identity{alice, bob} {
+			plain, err := DecryptWith(encrypted, who)
+			assert.NilError(t, err)
+			assert.Equal(t, string(plain), "shared")
+		}
+
+		_, err = DecryptWith(encrypted, carol)
+		assert.ErrorContains(t, err, "is not a recipient")
+	})
+
+	t.Run("RewrapKeepsPayload", func(t *testing.T) {
+		encrypted, err := EncryptFor([]byte("payload"), []Recipient{alice.Recipient(), bob.Recipient()})
+		assert.NilError(t, err)
+
+		rewrapped, err := Rewrap(encrypted, alice, []Recipient{alice.Recipient(), carol.Recipient()})
+		assert.NilError(t, err)
+
+		payload := func(value string) string { return value[strings.LastIndex(value, "$"):] }
+		assert.Equal(t, payload(rewrapped), payload(encrypted))
+
+		plain, err := DecryptWith(rewrapped, carol)
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "payload")
+
+		_, err = DecryptWith(rewrapped, bob)
+		assert.ErrorContains(t, err, "is not a recipient")
+
+		ids, err := RecipientIDs(rewrapped)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, ids, []string{alice.Recipient().ID(), carol.Recipient().ID()})
+	})
+
+	t.Run("RewrapRequiresRecipient", func(t *testing.T) {
+		encrypted, err := EncryptFor([]byte("payload"), []Recipient{alice.Recipient()})
+		assert.NilError(t, err)
+
+		_, err = Rewrap(encrypted, bob, []Recipient{bob.Recipient()})
+		assert.ErrorContains(t, err, "is not a recipient")
+	})
+
+	t.Run("MasterKeyDecryptRejects", func(t *testing.T) {
+		encrypted, err := EncryptFor([]byte("payload"), []Recipient{alice.Recipient()})
+		assert.NilError(t, err)
+
+		_, err = Decrypt(encrypted, make([]byte, 32))
+		assert.ErrorContains(t, err, "decrypt it with an identity")
+	})
+
+	t.Run("ContextBinding", func(t *testing.T) {
+		encrypted, err := EncryptForContext([]byte("payload"), []Recipient{alice.Recipient()}, "seeds.~/.netrc")
+		assert.NilError(t, err)
+		assert.Assert(t, IsBound(encrypted))
+
+		plain, err := DecryptWithContext(encrypted, alice, "seeds.~/.netrc")
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "payload")
+
+		_, err = DecryptWithContext(encrypted, alice, "seeds.~/.ssh/id_ed25519")
+		assert.ErrorContains(t, err, "message authentication failed")
+
+		_, err = DecryptWith(encrypted, alice)
+		assert.ErrorContains(t, err, "bound to a context")
+
+		rewrapped, err := Rewrap(encrypted, alice, []Recipient{alice.Recipient(), bob.Recipient()})
+		assert.NilError(t, err)
+		assert.Assert(t, IsBound(rewrapped))
+
+		plain, err = DecryptWithContext(rewrapped, bob, "seeds.~/.netrc")
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "payload")
+	})
+
+	t.Run("CollidingStanzaSkipped", func(t *testing.T) {
+		encrypted, err := EncryptFor([]byte("shared"), []Recipient{alice.Recipient(), bob.Recipient()})
+		assert.NilError(t, err)
+
+		parsed, err := parseRecipientEnvelope(encrypted)
+		assert.NilError(t, err)
+
+		decoy := parsed.stanzas[1]
+		decoy.id = parsed.stanzas[0].id
+		parsed.stanzas = append([]stanza{decoy}, parsed.stanzas...)
+
+		plain, err := DecryptWith(parsed.String(), alice)
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "shared")
+
+		parsed.stanzas = parsed.stanzas[:1]
+		_, err = DecryptWith(parsed.String(), alice)
+		assert.ErrorContains(t, err, "could not unwrap the data key")
+	})
+
+	t.Run("NoRecipients", func(t *testing.T) {
+		_, err := EncryptFor([]byte("payload"), nil)
+		assert.ErrorContains(t, err, "at least one recipient")
+	})
+}
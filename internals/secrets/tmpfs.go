@@ -0,0 +1,70 @@
+package secrets
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
+)
+
+func PrivateDir() (string, func(), error) {
+	base, err := memoryBackedDir()
+	if err != nil {
+		return "", func() {}, err
+	}
+
+	dir, err := os.MkdirTemp(base, "ws-secrets-")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("failed to create private directory: %w", err)
+	}
+
+	if err := os.Chmod(dir, 0o700); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", func() {}, fmt.Errorf("failed to create private directory: %w", err)
+	}
+
+	return dir, func() { scrubDir(dir) }, nil
+}
+
+func memoryBackedDir() (string, error) {
+	for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if dir != "" && isMemoryBacked(dir) {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("no memory-backed directory for plaintext (mount a tmpfs at $XDG_RUNTIME_DIR or /dev/shm)")
+}
+
+func isMemoryBacked(dir string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false
+	}
+
+	magic := uint32(stat.Type)
+
+	return magic == tmpfsMagic || magic == ramfsMagic
+}
+
+func scrubDir(dir string) {
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+
+		if info, err := entry.Info(); err == nil {
+			_ = os.WriteFile(path, make([]byte, info.Size()), 0o600)
+		}
+
+		return nil
+	})
+
+	_ = os.RemoveAll(dir)
+}
@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPrivateDir(t *testing.T) {
+	t.Run("MemoryBackedAndScrubbed", func(t *testing.T) {
+		dir, cleanup, err := PrivateDir()
+		assert.NilError(t, err)
+
+		info, err := os.Stat(dir)
+		assert.NilError(t, err)
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0o700))
+		assert.Assert(t, isMemoryBacked(dir))
+
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("plaintext"), 0o600))
+
+		cleanup()
+
+		_, err = os.Stat(dir)
+		assert.Assert(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,240 @@
+package seed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+type retraction struct {
+	exists  bool
+	remove  bool
+	changed bool
+	content []byte
+	mode    fs.FileMode
+	managed []byte
+	secret  bool
+}
+
+func (r reporter) removed(dest string, changed bool) {
+	if r.report != nil {
+		result := ResultRemoved
+		if !changed {
+			result = ResultUnchanged
+		}
+
+		r.report.record(result, "")
+		return
+	}
+
+	if r.styled {
+		styles.PrintSuccess(r.out, fmt.Sprintf("Removed [%s]", dest))
+		return
+	}
+
+	fmt.Fprintf(r.out, "Removed [%s]\n", dest)
+}
+
+func (p *Plan) retract(op ResolvedOp, keys *keyResolver) (retraction, error) {
+	var result retraction
+
+	info, err := os.Lstat(op.Dest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return retraction{}, fmt.Errorf("destination unreadable: %w", err)
+	default:
+		result.exists = true
+		result.mode = info.Mode().Perm()
+	}
+
+	switch op.Op {
+	case OpCopy:
+		result.remove = result.exists
+		result.changed = result.exists
+		return result, nil
+	case OpSymlink:
+		if result.exists && info.Mode().Type() != fs.ModeSymlink {
+			return retraction{}, fmt.Errorf("exists and is not a symlink")
+		}
+
+		result.remove = result.exists
+		result.changed = result.exists
+		return result, nil
+	case OpDirectory:
+		if !result.exists {
+			return result, nil
+		}
+
+		if !info.IsDir() {
+			return retraction{}, fmt.Errorf("exists and is not a directory")
+		}
+
+		if entries, err := os.ReadDir(op.Dest); err != nil || len(entries) > 0 {
+			return retraction{}, fmt.Errorf("directory not empty")
+		}
+
+		result.remove = true
+		result.changed = true
+		return result, nil
+	case OpBlock:
+		result.secret = op.Secret || op.Template
+		if !result.exists {
+			return result, nil
+		}
+
+		existing := readExisting(op.Dest)
+		if result.content, err = removeBlock(existing, op.Comment); err != nil {
+			return retraction{}, err
+		}
+
+		result.changed = !bytes.Equal(existing, result.content)
+		return result, nil
+	}
+
+	raw, err := p.sourceBytes(op)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return retraction{}, fmt.Errorf("no source available")
+		}
+
+		return retraction{}, fmt.Errorf("source unreadable: %w", err)
+	}
+
+	body, err := p.transform(op, raw, keys)
+	if err != nil {
+		return retraction{}, err
+	}
+
+	managed := bytes.TrimRight(body, "\n")
+	if len(managed) == 0 {
+		return retraction{}, fmt.Errorf("op: lineinfile requires content")
+	}
+
+	key := lineKey(string(managed))
+	result.managed = []byte(key)
+	result.secret = op.Secret || (op.Template && referencesSecrets(raw))
+
+	if !result.exists {
+		return result, nil
+	}
+
+	existing := readExisting(op.Dest)
+	result.content = removeLines(existing, func(line string) bool {
+		return lineKey(line) == key
+	})
+	result.changed = !bytes.Equal(existing, result.content)
+
+	return result, nil
+}
+
+func (p *Plan) retractOne(op ResolvedOp, keys *keyResolver, rep reporter, ledger *Ledger, backups *backupRun) error {
+	ancestor := nearestExistingAncestor(op.Dest)
+	if !ownsPath(ancestor) {
+		rep.skip(op.Dest, "destination not owned")
+		return fmt.Errorf("destination not owned")
+	}
+
+	result, err := p.retract(op, keys)
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	rep.secret(result.secret)
+
+	if result.remove && op.Op == OpCopy && !op.Force && !ledger.inSync(op.Dest) {
+		rep.skip(op.Dest, "modified")
+		return nil
+	}
+
+	if result.changed {
+		if err := op.runHooks(HookBefore, rep); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+
+		if err := backups.snapshot(op.Dest); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+
+		anchor := chooseAnchor(op.Dest, p.Vars, ancestor)
+
+		switch {
+		case result.remove && op.Op == OpSymlink:
+			err = removeNode(anchor, op.Dest, fs.ModeSymlink)
+		case result.remove && op.Op == OpDirectory:
+			err = removeNode(anchor, op.Dest, fs.ModeDir)
+		case result.remove:
+			err = removeAtomic(anchor, op.Dest)
+		default:
+			err = writeAtomic(anchor, op.Dest, result.content, result.mode)
+		}
+
+		if err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+	}
+
+	if err := ledger.recordAbsent(op, result.managed); err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	rep.removed(op.Dest, result.changed)
+
+	if result.changed {
+		if err := op.runHooks(HookOnChange, rep); err != nil {
+			rep.hookFailed(op.Dest, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e LedgerEntry) checkAbsent(dest string) (State, error) {
+	if e.Op.node() {
+		if _, err := os.Lstat(dest); errors.Is(err, fs.ErrNotExist) {
+			return StateInSync, nil
+		}
+
+		return StateDrifted, nil
+	}
+
+	current, err := os.ReadFile(dest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return StateInSync, nil
+	}
+
+	switch e.Op {
+	case OpBlock:
+		if _, found, err := currentBlock(current, e.Comment); err != nil || found {
+			return StateDrifted, nil
+		}
+
+		return StateInSync, nil
+	case OpLineInfile:
+		matches, err := e.matcher()
+		if err != nil {
+			return "", err
+		}
+
+		for _, line := range strings.Split(string(current), "\n") {
+			if matches([]byte(lineKey(line))) {
+				return StateDrifted, nil
+			}
+		}
+
+		return StateInSync, nil
+	}
+
+	return StateDrifted, nil
+}
@@ -0,0 +1,139 @@
+package seed
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestApplyAbsent(t *testing.T) {
+	t.Run("CopyDeletesDestination", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "stale.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    content: \"stale\\n\"\n", dest))
+		apply(t, Options{Source: source})
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    state: absent\n", dest))
+
+		output := apply(t, Options{Source: source})
+		assert.Assert(t, !fileExists(dest))
+		assert.Assert(t, strings.Contains(output, "Removed ["+dest+"]"))
+
+		apply(t, Options{Source: source})
+		assert.Assert(t, !fileExists(dest))
+	})
+
+	t.Run("ModifiedCopyKeptWithoutForce", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "stale.txt")
+		unmanaged := filepath.Join(target, "mine.txt")
+		write(t, unmanaged, "mine\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    content: \"stale\\n\"\n", dest))
+		apply(t, Options{Source: source})
+		write(t, dest, "edited\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    state: absent\n  %s:\n    state: absent\n", dest, unmanaged))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (modified)"))
+
+		output = apply(t, Options{Source: source})
+		assert.Equal(t, readFile(t, dest), "edited\n")
+		assert.Equal(t, readFile(t, unmanaged), "mine\n")
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (modified)"))
+		assert.Assert(t, strings.Contains(output, "Skipping ["+unmanaged+"] (modified)"))
+
+		apply(t, Options{Source: source, Force: true})
+		assert.Assert(t, !fileExists(dest))
+		assert.Assert(t, !fileExists(unmanaged))
+	})
+
+	t.Run("BlockRemovedIdempotently", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "rc")
+		write(t, dest, "head\n# >>> ws-seed >>>\nx=1\n# <<< ws-seed <<<\ntail\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: block\n    state: absent\n", dest))
+
+		apply(t, Options{Source: source})
+		assert.Equal(t, readFile(t, dest), "head\ntail\n")
+
+		apply(t, Options{Source: source})
+		assert.Equal(t, readFile(t, dest), "head\ntail\n")
+	})
+
+	t.Run("LineRemovedByKey", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "env")
+		write(t, dest, "A=1\nFOO=old\nB=2\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: lineinfile\n    state: absent\n    content: \"FOO=new\"\n", dest))
+
+		apply(t, Options{Source: source})
+		assert.Equal(t, readFile(t, dest), "A=1\nB=2\n")
+
+		apply(t, Options{Source: source})
+		assert.Equal(t, readFile(t, dest), "A=1\nB=2\n")
+	})
+
+	t.Run("MissingDestinationIsNoop", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "never.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: block\n    state: absent\n", dest))
+
+		apply(t, Options{Source: source})
+		assert.Assert(t, !fileExists(dest))
+	})
+
+	t.Run("DryRunReportsRemoval", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "stale.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    content: \"stale\\n\"\n", dest))
+		apply(t, Options{Source: source})
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    state: absent\n", dest))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+
+		assert.Assert(t, fileExists(dest))
+		assert.Assert(t, strings.Contains(output, "Remove ["+dest+"]"))
+		assert.Assert(t, strings.Contains(output, "1 to remove"))
+	})
+
+	t.Run("StatusTracksRetraction", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "env")
+		write(t, dest, "FOO=old\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: lineinfile\n    state: absent\n    content: \"FOO=new\"\n", dest))
+
+		assert.Equal(t, statusOf(t, source)[dest].State, StateNeverApplied)
+
+		apply(t, Options{Source: source})
+		assert.Equal(t, statusOf(t, source)[dest].State, StateInSync)
+
+		write(t, dest, "FOO=back\n")
+		assert.Equal(t, statusOf(t, source)[dest].State, StateDrifted)
+	})
+}
@@ -0,0 +1,388 @@
+package seed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"github.com/kloudkit/ws-cli/internals/secrets"
+	"github.com/kloudkit/ws-cli/internals/styles"
+	"gopkg.in/yaml.v3"
+)
+
+type AddOptions struct {
+	Source    string
+	Path      string
+	Op        Op
+	Secret    bool
+	Template  bool
+	MasterKey string
+	Force     bool
+	Out       io.Writer
+	Styled    bool
+}
+
+type addEntry struct {
+	key    string
+	fields [][2]string
+}
+
+func Add(opts AddOptions) error {
+	layers := Layers(opts.Source)
+	if len(layers) == 0 {
+		return fmt.Errorf("no seed source configured (use --source)")
+	}
+
+	layer := layers[len(layers)-1]
+	vars := resolveVars()
+
+	dest, err := vars.expand(opts.Path)
+	if err != nil {
+		return err
+	}
+
+	if dest, err = filepath.Abs(dest); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(dest)
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %w", dest, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%q is not a regular file", dest)
+	}
+
+	if isUnder(dest, layer) {
+		return fmt.Errorf("%q is inside the seed source", dest)
+	}
+
+	op := SeedOp{Op: opts.Op, Secret: opts.Secret, Template: opts.Template}
+	if op.Op == "" {
+		op.Op = OpCopy
+	}
+
+	if op.Op.node() {
+		return fmt.Errorf("op: %s cannot capture a file", op.Op)
+	}
+
+	if op.Secret && op.Template {
+		return fmt.Errorf("a secret entry cannot also be a template")
+	}
+
+	if op.Op == OpCopy && !op.Secret && info.Mode().Perm() != 0o644 {
+		op.Mode = formatMode(info.Mode())
+	}
+
+	if err := validateOp(dest, op); err != nil {
+		return err
+	}
+
+	source := rhymingSource(layer, dest)
+	if internalIO.FileExists(source) && !opts.Force {
+		return fmt.Errorf("%q is already in the seed source (use --force to replace it)", source)
+	}
+
+	content, err := os.ReadFile(dest)
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %w", dest, err)
+	}
+
+	entry := addEntry{key: manifestKey(dest, vars), fields: entryFields(op)}
+
+	perm := info.Mode().Perm()
+	if op.Secret {
+		encrypted, err := encryptCaptured(ManifestPath(layer), content, opts.MasterKey, seedContext(entry.key))
+		zeroBytes(content)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %q: %w", dest, err)
+		}
+
+		content, perm = []byte(encrypted+"\n"), 0o600
+	}
+
+	restore, err := snapshotSource(source)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSource(source, content, perm); err != nil {
+		return err
+	}
+
+	if op.hasBehavior() {
+		if err := addManifestEntry(ManifestPath(layer), dest, entry, vars, opts.Force); err != nil {
+			if restoreErr := restore(); restoreErr != nil {
+				return fmt.Errorf("%w (and failed to restore %q: %v)", err, source, restoreErr)
+			}
+
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("Added [%s] as %s", dest, source)
+	if opts.Styled {
+		styles.PrintSuccess(opts.Out, message)
+	} else {
+		fmt.Fprintln(opts.Out, message)
+	}
+
+	return nil
+}
+
+func encryptCaptured(manifestPath string, content []byte, masterKey, context string) (string, error) {
+	if internalIO.FileExists(manifestPath) {
+		recipients, err := manifestRecipients(manifestPath)
+		if err != nil {
+			return "", err
+		}
+
+		if len(recipients) > 0 {
+			return secrets.EncryptForContext(content, recipients, context)
+		}
+	}
+
+	master, err := secrets.ResolveMasterKey(masterKey)
+	if err != nil {
+		return "", err
+	}
+	defer zeroBytes(master)
+
+	return secrets.EncryptContext(content, master, context)
+}
+
+func snapshotSource(path string) (func() error, error) {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return func() error { return os.Remove(path) }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	return func() error { return writeSource(path, previous, info.Mode().Perm()) }, nil
+}
+
+func manifestKey(dest string, vars Vars) string {
+	if isUnder(dest, vars.Home) && dest != filepath.Clean(vars.Home) {
+		rel, _ := filepath.Rel(vars.Home, dest)
+		return "${ws_home}/" + filepath.ToSlash(rel)
+	}
+
+	return dest
+}
+
+func entryFields(op SeedOp) [][2]string {
+	var fields [][2]string
+
+	if op.Op != OpCopy {
+		fields = append(fields, [2]string{"op", string(op.Op)})
+	}
+
+	if op.Mode != "" {
+		fields = append(fields, [2]string{"mode", fmt.Sprintf("%q", op.Mode)})
+	}
+
+	if op.Secret {
+		fields = append(fields, [2]string{"secret", "true"})
+	}
+
+	if op.Template {
+		fields = append(fields, [2]string{"template", "true"})
+	}
+
+	return fields
+}
+
+func writeSource(path string, content []byte, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %q: %w", filepath.Dir(path), err)
+	}
+
+	if err := atomicReplace(path, content); err != nil {
+		return fmt.Errorf("failed to write %q: %w", path, err)
+	}
+
+	return os.Chmod(path, perm)
+}
+
+func addManifestEntry(manifestPath, dest string, entry addEntry, vars Vars, force bool) error {
+	data, err := os.ReadFile(manifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.WriteFile(manifestPath, []byte("version: v1\nseeds:\n"+entry.render(2, 2)), 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read manifest %q: %w", manifestPath, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	root := documentRoot(&doc)
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse manifest: expected a mapping")
+	}
+
+	seeds := mappingValue(root, "seeds")
+	if seeds != nil && seeds.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(seeds.Content); i += 2 {
+			existing, err := vars.expand(seeds.Content[i].Value)
+			if err != nil || existing != dest {
+				continue
+			}
+
+			if !force {
+				return fmt.Errorf("seed %q already has a manifest entry (use --force to replace it)", seeds.Content[i].Value)
+			}
+
+			if seeds.Style&yaml.FlowStyle != 0 {
+				seeds.Content = append(seeds.Content[:i], seeds.Content[i+2:]...)
+
+				return appendNodeEntry(manifestPath, &doc, seeds, entry)
+			}
+
+			return replaceEntry(manifestPath, data, root, seeds, i, entry)
+		}
+	}
+
+	if seeds == nil {
+		text := string(data)
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+
+		return atomicReplace(manifestPath, []byte(text+"seeds:\n"+entry.render(2, 2)))
+	}
+
+	if seeds.Kind != yaml.MappingNode || seeds.Style&yaml.FlowStyle != 0 || len(seeds.Content) == 0 {
+		return appendNodeEntry(manifestPath, &doc, seeds, entry)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	at := sectionEnd(lines, root, seeds)
+
+	return atomicReplace(manifestPath, spliceLines(lines, at, at, entry.render(entryLayout(seeds, 0))))
+}
+
+func replaceEntry(manifestPath string, data []byte, root, seeds *yaml.Node, i int, entry addEntry) error {
+	lines := strings.SplitAfter(string(data), "\n")
+	start := seeds.Content[i].Line - 1
+	end := sectionEnd(lines, root, seeds)
+
+	if i+2 < len(seeds.Content) {
+		end = seeds.Content[i+2].Line - 1
+
+		for end > start+1 {
+			trimmed := strings.TrimSpace(lines[end-1])
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				break
+			}
+
+			end--
+		}
+	}
+
+	return atomicReplace(manifestPath, spliceLines(lines, start, end, entry.render(entryLayout(seeds, i))))
+}
+
+func entryLayout(seeds *yaml.Node, i int) (int, int) {
+	indent := seeds.Content[i].Column - 1
+	step := 2
+
+	for j := i + 1; j < len(seeds.Content); j += 2 {
+		if value := seeds.Content[j]; value.Kind == yaml.MappingNode && len(value.Content) > 0 && value.Style&yaml.FlowStyle == 0 {
+			step = value.Content[0].Column - 1 - (seeds.Content[j-1].Column - 1)
+			break
+		}
+	}
+
+	return indent, step
+}
+
+func spliceLines(lines []string, start, end int, text string) []byte {
+	var out bytes.Buffer
+	for _, line := range lines[:start] {
+		out.WriteString(line)
+	}
+
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	out.WriteString(text)
+
+	for _, line := range lines[end:] {
+		out.WriteString(line)
+	}
+
+	return out.Bytes()
+}
+
+func sectionEnd(lines []string, root, section *yaml.Node) int {
+	end := len(lines)
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i+1] == section && i+2 < len(root.Content) {
+			end = root.Content[i+2].Line - 1
+			break
+		}
+	}
+
+	for end > 0 {
+		trimmed := strings.TrimSpace(lines[end-1])
+		if trimmed != "" && !(strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(lines[end-1], " ")) {
+			break
+		}
+
+		end--
+	}
+
+	return end
+}
+
+func appendNodeEntry(manifestPath string, doc *yaml.Node, seeds *yaml.Node, entry addEntry) error {
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, field := range entry.fields {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(field[1]), &node); err != nil {
+			return err
+		}
+
+		value.Content = append(value.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field[0]},
+			documentRoot(&node),
+		)
+	}
+
+	seeds.Kind, seeds.Tag, seeds.Style = yaml.MappingNode, "!!map", 0
+	seeds.Content = append(seeds.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.key}, value)
+
+	return writeManifestFile(manifestPath, doc)
+}
+
+func (e addEntry) render(indent, step int) string {
+	var out strings.Builder
+
+	key, _ := yaml.Marshal(e.key)
+	fmt.Fprintf(&out, "%s%s:\n", strings.Repeat(" ", indent), strings.TrimSuffix(string(key), "\n"))
+
+	for _, field := range e.fields {
+		fmt.Fprintf(&out, "%s%s: %s\n", strings.Repeat(" ", indent+step), field[0], field[1])
+	}
+
+	return out.String()
+}
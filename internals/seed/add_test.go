@@ -0,0 +1,255 @@
+package seed
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kloudkit/ws-cli/internals/secrets"
+	"gotest.tools/v3/assert"
+)
+
+func add(t *testing.T, opts AddOptions) {
+	t.Helper()
+	var buffer bytes.Buffer
+	opts.Out = &buffer
+	assert.NilError(t, Add(opts))
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	return string(data)
+}
+
+func TestAdd(t *testing.T) {
+	t.Run("MirrorOnly", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".bashrc")
+		write(t, dest, "alias ll='ls -l'\n")
+
+		add(t, AddOptions{Source: source, Path: dest})
+
+		assert.Equal(t, read(t, rhyming(source, dest)), "alias ll='ls -l'\n")
+		assert.Assert(t, !fileExists(ManifestPath(source)))
+	})
+
+	t.Run("RoundTrips", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".config", "tool.json")
+		write(t, dest, `{"theme": "dark"}`)
+
+		add(t, AddOptions{Source: source, Path: dest, Op: OpMerge})
+
+		assert.Equal(t, read(t, ManifestPath(source)), "version: v1\nseeds:\n  ${ws_home}/.config/tool.json:\n    op: merge\n")
+
+		write(t, dest, `{"font": "mono"}`)
+		apply(t, Options{Source: source, Force: true})
+
+		assert.DeepEqual(t, decodeBack(t, []byte(read(t, dest)), dest), map[string]any{"theme": "dark", "font": "mono"})
+	})
+
+	t.Run("Secret", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".netrc")
+		write(t, dest, "machine example.com password hunter2\n")
+
+		add(t, AddOptions{Source: source, Path: dest, Secret: true, MasterKey: testMaster})
+
+		captured := read(t, rhyming(source, dest))
+		assert.Assert(t, !strings.Contains(captured, "hunter2"))
+		assert.Equal(t, mode(t, rhyming(source, dest)), os.FileMode(0o600))
+
+		master, err := secrets.ResolveMasterKey(testMaster)
+		assert.NilError(t, err)
+		plain, err := secrets.DecryptContext(secrets.NormalizeEncrypted(captured), master, "seeds.${ws_home}/.netrc")
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "machine example.com password hunter2\n")
+		assert.Assert(t, secrets.IsBound(secrets.NormalizeEncrypted(captured)))
+
+		assert.Assert(t, strings.Contains(read(t, ManifestPath(source)), "    secret: true\n"))
+	})
+
+	t.Run("ManifestFailureRemovesSource", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".netrc")
+		write(t, dest, "machine example.com password hunter2\n")
+		write(t, ManifestPath(source), "seeds: [\n")
+
+		err := Add(AddOptions{Source: source, Path: dest, Secret: true, MasterKey: testMaster, Out: &bytes.Buffer{}})
+
+		assert.ErrorContains(t, err, "failed to parse manifest")
+		assert.Assert(t, !fileExists(rhyming(source, dest)))
+	})
+
+	t.Run("ManifestFailureRestoresSource", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".config", "app.json")
+		write(t, dest, `{"new":true}`)
+		write(t, rhyming(source, dest), `{"old":true}`)
+		write(t, ManifestPath(source), "seeds: [\n")
+
+		err := Add(AddOptions{Source: source, Path: dest, Op: OpMerge, Force: true, Out: &bytes.Buffer{}})
+
+		assert.ErrorContains(t, err, "failed to parse manifest")
+		assert.Equal(t, read(t, rhyming(source, dest)), `{"old":true}`)
+	})
+
+	t.Run("KeepsMode", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, "bin", "tool")
+		write(t, dest, "#!/bin/sh\n")
+		assert.NilError(t, os.Chmod(dest, 0o755))
+
+		add(t, AddOptions{Source: source, Path: dest})
+
+		assert.Assert(t, strings.Contains(read(t, ManifestPath(source)), "    mode: \"0o755\"\n"))
+	})
+
+	t.Run("PreservesFormatting", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".profile")
+		write(t, dest, "export EDITOR=vim\n")
+
+		write(t, ManifestPath(source), strings.Join([]string{
+			"version: v1",
+			"",
+			"# Team dotfiles",
+			"seeds:",
+			"    ${ws_home}/.gitconfig:   # shared identity",
+			"        op: merge",
+			"",
+			"# Boot hooks",
+			"mirror:",
+			"    ${ws_home}/.ssh:",
+			"        when: ws_ssh",
+			"",
+		}, "\n"))
+
+		add(t, AddOptions{Source: source, Path: dest, Template: true})
+
+		assert.Equal(t, read(t, ManifestPath(source)), strings.Join([]string{
+			"version: v1",
+			"",
+			"# Team dotfiles",
+			"seeds:",
+			"    ${ws_home}/.gitconfig:   # shared identity",
+			"        op: merge",
+			"    ${ws_home}/.profile:",
+			"        template: true",
+			"",
+			"# Boot hooks",
+			"mirror:",
+			"    ${ws_home}/.ssh:",
+			"        when: ws_ssh",
+			"",
+		}, "\n"))
+
+		_, err := ParseManifest([]byte(read(t, ManifestPath(source))))
+		assert.NilError(t, err)
+	})
+
+	t.Run("ExistingRequiresForce", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".vimrc")
+		write(t, dest, "set nu\n")
+
+		add(t, AddOptions{Source: source, Path: dest, Template: true})
+
+		var buffer bytes.Buffer
+		err := Add(AddOptions{Source: source, Path: dest, Template: true, Out: &buffer})
+		assert.ErrorContains(t, err, "already in the seed source")
+
+		write(t, dest, "set rnu\n")
+		add(t, AddOptions{Source: source, Path: dest, Op: OpAppend, Force: true})
+
+		assert.Equal(t, read(t, rhyming(source, dest)), "set rnu\n")
+
+		manifest, err := ParseManifest([]byte(read(t, ManifestPath(source))))
+		assert.NilError(t, err)
+		assert.Equal(t, len(manifest.Seeds), 1)
+		assert.Equal(t, manifest.Seeds["${ws_home}/.vimrc"].Op, OpAppend)
+	})
+
+	t.Run("ForcePreservesFormatting", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".profile")
+		write(t, dest, "export EDITOR=vim\n")
+		write(t, rhyming(source, dest), "export EDITOR=nano\n")
+
+		write(t, ManifestPath(source), strings.Join([]string{
+			"version: v1",
+			"seeds:",
+			"    # Login shell",
+			"    ${ws_home}/.profile:",
+			"        op: copy",
+			"        force: true",
+			"",
+			"    # Shared identity",
+			"    ${ws_home}/.gitconfig:   # merged",
+			"        op: merge",
+			"",
+		}, "\n"))
+
+		add(t, AddOptions{Source: source, Path: dest, Template: true, Force: true})
+
+		assert.Equal(t, read(t, ManifestPath(source)), strings.Join([]string{
+			"version: v1",
+			"seeds:",
+			"    # Login shell",
+			"    ${ws_home}/.profile:",
+			"        template: true",
+			"",
+			"    # Shared identity",
+			"    ${ws_home}/.gitconfig:   # merged",
+			"        op: merge",
+			"",
+		}, "\n"))
+	})
+
+	t.Run("TopLayer", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		org := t.TempDir()
+		personal := t.TempDir()
+		dest := filepath.Join(home, ".inputrc")
+		write(t, dest, "set bell-style none\n")
+
+		add(t, AddOptions{Source: org + ":" + personal, Path: dest})
+
+		assert.Assert(t, fileExists(rhyming(personal, dest)))
+		assert.Assert(t, !fileExists(rhyming(org, dest)))
+	})
+
+	t.Run("SecretTemplateRejected", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		dest := filepath.Join(home, ".env")
+		write(t, dest, "A=1\n")
+
+		var buffer bytes.Buffer
+		err := Add(AddOptions{Source: t.TempDir(), Path: dest, Secret: true, Template: true, Out: &buffer})
+		assert.ErrorContains(t, err, "cannot also be a template")
+	})
+}
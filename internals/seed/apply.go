@@ -8,6 +8,8 @@ import (
 	"io/fs"
 	"os"
 	"slices"
+	"sync"
+	"time"
 
 	internalIO "github.com/kloudkit/ws-cli/internals/io"
 	"github.com/kloudkit/ws-cli/internals/secrets"
@@ -15,20 +17,49 @@ import (
 )
 
 type Options struct {
-	Source    string
-	Force     bool
-	Dests     []string
-	MasterKey string
-	Out       io.Writer
-	Styled    bool
+	Source      string
+	Force       bool
+	Dests       []string
+	MasterKey   string
+	Identity    string
+	Profiles    []string
+	DryRun      bool
+	Out         io.Writer
+	Styled      bool
+	Output      Output
+	Jobs        int
+	HookTimeout time.Duration
+
+	forceDests map[string]bool
+	backups    *backupRun
+}
+
+const defaultJobs = 4
+
+type applied struct {
+	rep    reporter
+	output []byte
+	tally  dryRunTally
+	err    error
 }
 
 type reporter struct {
 	out    io.Writer
 	styled bool
+	report *jsonReport
 }
 
-func (r reporter) seeded(dest string) {
+func (r reporter) seeded(dest string, changed bool) {
+	if r.report != nil {
+		result := ResultSeeded
+		if !changed {
+			result = ResultUnchanged
+		}
+
+		r.report.record(result, "")
+		return
+	}
+
 	if r.styled {
 		styles.PrintSuccess(r.out, fmt.Sprintf("Seeded [%s]", dest))
 		return
@@ -38,6 +69,11 @@ func (r reporter) seeded(dest string) {
 }
 
 func (r reporter) skip(dest, reason string) {
+	if r.report != nil {
+		r.report.record(ResultSkipped, reason)
+		return
+	}
+
 	if r.styled {
 		styles.PrintWarning(r.out, fmt.Sprintf("Skipping [%s] (%s)", dest, reason))
 		return
@@ -46,9 +82,28 @@ func (r reporter) skip(dest, reason string) {
 	fmt.Fprintf(r.out, "Skipping [%s] (%s)\n", dest, reason)
 }
 
+func (r reporter) warn(message string) {
+	if r.report != nil {
+		r.report.summary.Warnings = append(r.report.summary.Warnings, message)
+		return
+	}
+
+	if r.styled {
+		styles.PrintWarning(r.out, message)
+		return
+	}
+
+	fmt.Fprintf(r.out, "Warning %s\n", message)
+}
+
 func (r reporter) notice(dest string) {
 	message := fmt.Sprintf("[%s] runs next boot; ensure +x if executable", dest)
 
+	if r.report != nil {
+		r.report.summary.Warnings = append(r.report.summary.Warnings, message)
+		return
+	}
+
 	if r.styled {
 		styles.PrintKeyValue(r.out, "Notice", message)
 		return
@@ -58,22 +113,66 @@ func (r reporter) notice(dest string) {
 }
 
 type keyResolver struct {
-	flag    string
-	secrets map[string]string
-	key     []byte
-	loaded  bool
-	err     error
+	flag         string
+	identityFlag string
+	secrets      map[string]string
+	once         sync.Once
+	key          []byte
+	cache        *secrets.KeyCache
+	err          error
+	identityOnce sync.Once
+	identity     secrets.Identity
+	identityErr  error
 }
 
 func (k *keyResolver) master() ([]byte, error) {
-	if !k.loaded {
-		k.key, k.err = secrets.ResolveMasterKey(k.flag)
-		k.loaded = true
-	}
+	k.once.Do(func() {
+		if k.key, k.err = secrets.ResolveMasterKey(k.flag); k.err == nil {
+			k.cache = secrets.NewKeyCache(k.key)
+		}
+	})
 
 	return k.key, k.err
 }
 
+func (k *keyResolver) recipientIdentity() (secrets.Identity, error) {
+	k.identityOnce.Do(func() {
+		k.identity, k.identityErr = secrets.ResolveIdentity(k.identityFlag)
+	})
+
+	return k.identity, k.identityErr
+}
+
+func (k *keyResolver) ready(value string) error {
+	if secrets.IsRecipientEncrypted(secrets.NormalizeEncrypted(value)) {
+		_, err := k.recipientIdentity()
+		return err
+	}
+
+	_, err := k.master()
+
+	return err
+}
+
+func (k *keyResolver) decrypt(value, context string) ([]byte, error) {
+	value = secrets.NormalizeEncrypted(value)
+
+	if secrets.IsRecipientEncrypted(value) {
+		identity, err := k.recipientIdentity()
+		if err != nil {
+			return nil, err
+		}
+
+		return secrets.DecryptWithContext(value, identity, context)
+	}
+
+	if _, err := k.master(); err != nil {
+		return nil, err
+	}
+
+	return k.cache.DecryptContext(value, context)
+}
+
 func (k *keyResolver) zero() {
 	for i := range k.key {
 		k.key[i] = 0
@@ -86,17 +185,12 @@ func (k *keyResolver) resolveNamed(name string) ([]byte, error) {
 		return nil, fmt.Errorf("secret %q not declared", name)
 	}
 
-	master, err := k.master()
-	if err != nil {
-		return nil, err
-	}
-
 	resolved, err := secrets.ResolveEncryptedValue(value)
 	if err != nil {
 		return nil, err
 	}
 
-	return secrets.Decrypt(secrets.NormalizeEncrypted(resolved), master)
+	return k.decrypt(resolved, secretContext(name))
 }
 
 func Apply(opts Options) error {
@@ -105,6 +199,10 @@ func Apply(opts Options) error {
 		return err
 	}
 
+	if err := plan.Select(opts.Profiles); err != nil {
+		return err
+	}
+
 	ops := plan.Ops
 	if len(opts.Dests) > 0 {
 		ops, err = plan.filterDests(opts.Dests)
@@ -113,22 +211,108 @@ func Apply(opts Options) error {
 		}
 	}
 
-	declared := map[string]string{}
-	if plan.Manifest != nil {
-		declared = plan.Manifest.Secrets
+	for i := range ops {
+		ops[i].Force = ops[i].Force || opts.forceDests[ops[i].Dest]
+		ops[i].HookTimeout = opts.HookTimeout
 	}
 
-	keys := &keyResolver{flag: opts.MasterKey, secrets: declared}
+	keys := &keyResolver{flag: opts.MasterKey, identityFlag: opts.Identity, secrets: plan.Secrets}
 	defer keys.zero()
-	rep := reporter{out: opts.Out, styled: opts.Styled}
+	rep := newReporter(opts.Out, opts.Styled, opts.Output, opts.DryRun)
+
+	var (
+		ledger  *Ledger
+		backups *backupRun
+	)
+	if !opts.DryRun {
+		unlock, err := acquireLock(rep)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if ledger, err = LoadLedger(LedgerPath()); err != nil {
+			rep.warn(err.Error())
+		}
+
+		backups = opts.backups
+		if backups == nil {
+			backups = newBackupRun(ledger)
+		}
+
+		backups.ledger = ledger
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = defaultJobs
+	}
+
+	results := make([]applied, len(ops))
+	done := make([]chan struct{}, len(ops))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	slots := make(chan struct{}, jobs)
+	for i, op := range ops {
+		after := dependencies(ops, i)
+
+		go func() {
+			defer close(done[i])
+
+			for _, j := range after {
+				<-done[j]
+			}
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			var buffer bytes.Buffer
+			child := rep.fork(&buffer)
+			child.begin(op)
+
+			var err error
+			if opts.DryRun {
+				err = plan.previewOne(op, keys, child, &results[i].tally)
+			} else {
+				err = plan.applyOne(op, keys, child, ledger, backups)
+			}
+
+			child.finish(err)
+			results[i].rep, results[i].output, results[i].err = child, buffer.Bytes(), err
+		}()
+	}
 
 	failures := 0
-	for _, op := range ops {
-		if err := plan.applyOne(op, keys, rep); err != nil {
+	tally := dryRunTally{}
+	for i := range ops {
+		<-done[i]
+
+		rep.join(results[i].rep, results[i].output)
+		tally.add(results[i].tally)
+
+		if results[i].err != nil {
 			failures++
 		}
 	}
 
+	if opts.DryRun {
+		rep.summary(tally)
+	}
+
+	if err := backups.save(rep); err != nil {
+		rep.warn(err.Error())
+	}
+
+	if ledger != nil {
+		if err := ledger.Save(LedgerPath()); err != nil {
+			rep.warn(err.Error())
+		}
+	}
+
+	rep.close()
+
 	if failures > 0 {
 		noun := "entries"
 		if failures == 1 {
@@ -141,83 +325,234 @@ func Apply(opts Options) error {
 	return nil
 }
 
-func (p *Plan) applyOne(op ResolvedOp, keys *keyResolver, rep reporter) error {
+func dependencies(ops []ResolvedOp, i int) []int {
+	var after []int
+	for j := range i {
+		if isUnder(ops[i].Dest, ops[j].Dest) {
+			after = append(after, j)
+		}
+	}
+
+	return after
+}
+
+func precheck(op ResolvedOp) (string, string, error) {
 	ancestor := nearestExistingAncestor(op.Dest)
 	if !ownsPath(ancestor) {
-		rep.skip(op.Dest, "destination not owned")
-		return fmt.Errorf("destination not owned")
+		return ancestor, "destination not owned", fmt.Errorf("destination not owned")
 	}
 
 	if op.Op != OpBlock && op.Op != OpLineInfile && !internalIO.CanOverride(op.Dest, op.Force) {
-		rep.skip(op.Dest, "exists")
-		return nil
+		return ancestor, "exists", nil
+	}
+
+	return ancestor, "", nil
+}
+
+func (p *Plan) applyOne(op ResolvedOp, keys *keyResolver, rep reporter, ledger *Ledger, backups *backupRun) error {
+	switch {
+	case op.State == PresenceAbsent:
+		return p.retractOne(op, keys, rep, ledger, backups)
+	case op.Op == OpSymlink:
+		return p.linkOne(op, rep, ledger, backups)
+	case op.Op == OpDirectory:
+		return p.directoryOne(op, rep, ledger, backups)
 	}
 
-	content, mode, err := p.materialize(op, keys)
+	ancestor, reason, err := precheck(op)
+	if reason != "" {
+		rep.skip(op.Dest, reason)
+		return err
+	}
+
+	result, err := p.materialize(op, keys)
 	if err != nil {
 		rep.skip(op.Dest, err.Error())
 		return err
 	}
 
-	if (op.Op == OpBlock || op.Op == OpLineInfile) && bytes.Equal(content, readExisting(op.Dest)) {
-		rep.seeded(op.Dest)
+	rep.secret(result.secret)
+
+	if (op.Op == OpBlock || op.Op == OpLineInfile) && bytes.Equal(result.content, readExisting(op.Dest)) {
+		if err := ledger.record(op, result); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+
+		rep.seeded(op.Dest, false)
 		return nil
 	}
 
+	changed := changes(op.Dest, result.content, result.mode)
+	if changed && result.warning != "" {
+		rep.warn(result.warning)
+	}
+
+	if changed {
+		if err := op.runHooks(HookBefore, rep); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+	}
+
+	if changed {
+		if err := backups.snapshot(op.Dest); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+	}
+
 	anchor := chooseAnchor(op.Dest, p.Vars, ancestor)
-	if err := writeAtomic(anchor, op.Dest, content, mode); err != nil {
+	if err := writeAtomic(anchor, op.Dest, result.content, result.mode); err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	if err := ledger.record(op, result); err != nil {
 		rep.skip(op.Dest, err.Error())
 		return err
 	}
 
-	if consumedNotice(op.Dest, p.Vars.Home) {
+	if consumedNotice(op.Dest, p.Vars.Home) && len(op.hookCommands(HookOnChange)) == 0 {
 		rep.notice(op.Dest)
 	}
 
-	rep.seeded(op.Dest)
+	rep.seeded(op.Dest, changed)
+
+	if changed {
+		if err := op.runHooks(HookOnChange, rep); err != nil {
+			rep.hookFailed(op.Dest, err)
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (p *Plan) materialize(op ResolvedOp, keys *keyResolver) ([]byte, fs.FileMode, error) {
+type materialized struct {
+	content    []byte
+	managed    []byte
+	mode       fs.FileMode
+	secret     bool
+	sourceHash string
+	warning    string
+}
+
+func (p *Plan) materialize(op ResolvedOp, keys *keyResolver) (materialized, error) {
 	raw, err := p.sourceBytes(op)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			return nil, 0, fmt.Errorf("no source available")
+			return materialized{}, fmt.Errorf("no source available")
 		}
 
-		return nil, 0, fmt.Errorf("source unreadable: %w", err)
+		return materialized{}, fmt.Errorf("source unreadable: %w", err)
 	}
 
-	mode, err := resolveMode(op, op.Secret || (op.Template && referencesSecrets(raw)))
+	existing, base, err := p.current(op, keys)
 	if err != nil {
-		return nil, 0, err
+		return materialized{}, err
+	}
+
+	secretBearing := op.Secret || (op.Template && referencesSecrets(raw)) || (base != nil && base.secret)
+
+	mode, err := resolveMode(op, secretBearing)
+	if err != nil {
+		return materialized{}, err
+	}
+
+	if base != nil && op.Mode == "" && !secretBearing {
+		mode = base.mode
 	}
 
 	content, err := p.transform(op, raw, keys)
 	if err != nil {
-		return nil, 0, err
+		return materialized{}, err
 	}
 
+	body := content
+	warning := ""
+
 	switch op.Op {
 	case OpMerge:
-		if content, err = mergeContent(readExisting(op.Dest), content, op.Dest); err != nil {
-			return nil, 0, err
+		var layoutLost bool
+		if content, layoutLost, err = mergeContent(existing, content, op.Dest, op.Format, op.Merge); err != nil {
+			return materialized{}, err
+		}
+
+		if layoutLost {
+			warning = fmt.Sprintf("%s: comments and key order could not be preserved; the merged file is rewritten in canonical form", op.Dest)
 		}
 	case OpAppend:
-		content = slices.Concat(readExisting(op.Dest), content)
+		content = slices.Concat(existing, content)
 	case OpPrepend:
-		content = slices.Concat(content, readExisting(op.Dest))
+		content = slices.Concat(content, existing)
 	case OpBlock:
-		if content, err = ensureBlock(readExisting(op.Dest), content, op.Comment); err != nil {
-			return nil, 0, err
+		if content, err = ensureBlock(existing, content, op.Comment); err != nil {
+			return materialized{}, err
 		}
 	case OpLineInfile:
-		if content, err = ensureLine(readExisting(op.Dest), content); err != nil {
-			return nil, 0, err
+		if content, err = ensureLine(existing, content); err != nil {
+			return materialized{}, err
 		}
 	}
 
-	return content, mode, nil
+	sourceHash, err := p.sourceHash(op)
+	if err != nil {
+		return materialized{}, err
+	}
+
+	return materialized{
+		content:    content,
+		managed:    managedContent(op, body, content),
+		mode:       mode,
+		secret:     secretBearing,
+		sourceHash: sourceHash,
+		warning:    warning,
+	}, nil
+}
+
+func (p *Plan) sourceHash(op ResolvedOp) (string, error) {
+	raw, err := p.sourceBytes(op)
+	if err != nil {
+		return "", err
+	}
+
+	if !op.stacks() {
+		return hashBytes(raw), nil
+	}
+
+	below, err := p.sourceHash(*op.Base)
+	if err != nil {
+		return "", err
+	}
+
+	return hashBytes([]byte(below + hashBytes(raw))), nil
+}
+
+func (p *Plan) current(op ResolvedOp, keys *keyResolver) ([]byte, *materialized, error) {
+	below := op.Base
+	if !op.stacks() || (!below.Op.inPlace() && !internalIO.CanOverride(op.Dest, below.Force)) {
+		return readExisting(op.Dest), nil, nil
+	}
+
+	result, err := p.materialize(*below, keys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("layer %s: %w", below.Layer, err)
+	}
+
+	return result.content, &result, nil
+}
+
+func managedContent(op ResolvedOp, body, content []byte) []byte {
+	switch op.Op {
+	case OpBlock:
+		beginMarker, endMarker := blockMarkers(op.Comment)
+		return renderBlock(body, beginMarker, endMarker)
+	case OpLineInfile:
+		return bytes.TrimRight(body, "\n")
+	}
+
+	return content
 }
 
 func (p *Plan) transform(op ResolvedOp, raw []byte, keys *keyResolver) ([]byte, error) {
@@ -227,12 +562,15 @@ func (p *Plan) transform(op ResolvedOp, raw []byte, keys *keyResolver) ([]byte,
 			return nil, fmt.Errorf("secret source unresolved")
 		}
 
-		master, err := keys.master()
-		if err != nil {
+		if err := keys.ready(resolved); err != nil {
+			if secrets.IsRecipientEncrypted(secrets.NormalizeEncrypted(resolved)) {
+				return nil, fmt.Errorf("identity unavailable")
+			}
+
 			return nil, fmt.Errorf("master key unavailable")
 		}
 
-		plain, err := secrets.Decrypt(secrets.NormalizeEncrypted(resolved), master)
+		plain, err := keys.decrypt(resolved, seedContext(op.Key))
 		if err != nil {
 			return nil, fmt.Errorf("decrypt failed")
 		}
@@ -248,6 +586,14 @@ func (p *Plan) transform(op ResolvedOp, raw []byte, keys *keyResolver) ([]byte,
 }
 
 func (p *Plan) sourceBytes(op ResolvedOp) ([]byte, error) {
+	switch op.Op {
+	case OpSymlink:
+		target, err := op.linkTarget()
+		return []byte(target), err
+	case OpDirectory:
+		return nil, nil
+	}
+
 	if op.Content != nil {
 		return []byte(*op.Content), nil
 	}
@@ -0,0 +1,590 @@
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+const (
+	backupsName     = "backups"
+	backupManifest  = "backup.json"
+	backupRetention = 10
+	backupIDLayout  = "20060102T150405.000Z"
+)
+
+type BackupKind string
+
+const (
+	BackupFile      BackupKind = "file"
+	BackupSymlink   BackupKind = "symlink"
+	BackupDirectory BackupKind = "directory"
+)
+
+type BackupEntry struct {
+	Dest    string       `json:"dest"`
+	Existed bool         `json:"existed"`
+	Kind    BackupKind   `json:"kind,omitempty"`
+	Mode    string       `json:"mode,omitempty"`
+	Target  string       `json:"target,omitempty"`
+	File    string       `json:"file,omitempty"`
+	Tree    string       `json:"tree,omitempty"`
+	Ledger  *LedgerEntry `json:"ledger,omitempty"`
+}
+
+type Backup struct {
+	ID        string        `json:"id"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Entries   []BackupEntry `json:"entries"`
+}
+
+type backupRun struct {
+	mu     sync.Mutex
+	dir    string
+	ledger *Ledger
+	backup Backup
+}
+
+type RollbackOptions struct {
+	Run    string
+	Dests  []string
+	Out    io.Writer
+	Styled bool
+}
+
+func BackupsDir() string {
+	return filepath.Join(StateDir(), backupsName)
+}
+
+func (r reporter) backedUp(id string, count int) {
+	if r.report != nil {
+		r.report.summary.Backup = id
+		return
+	}
+
+	noun := "destinations"
+	if count == 1 {
+		noun = "destination"
+	}
+
+	message := fmt.Sprintf("Backed up %d %s as run %s", count, noun, id)
+
+	if r.styled {
+		fmt.Fprintln(r.out, styles.Muted().Render(message))
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func (r reporter) restored(dest string) {
+	if r.styled {
+		styles.PrintSuccess(r.out, fmt.Sprintf("Restored [%s]", dest))
+		return
+	}
+
+	fmt.Fprintf(r.out, "Restored [%s]\n", dest)
+}
+
+func newBackupRun(ledger *Ledger) *backupRun {
+	now := time.Now().UTC()
+
+	return &backupRun{ledger: ledger, backup: Backup{ID: now.Format(backupIDLayout), CreatedAt: now}}
+}
+
+func (b *backupRun) snapshot(dest string, children ...string) error {
+	if b == nil || b.holds(dest) {
+		return nil
+	}
+
+	entry := BackupEntry{Dest: dest}
+
+	info, err := os.Lstat(dest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to back up: %w", err)
+	default:
+		entry.Existed = true
+		entry.Mode = formatMode(info.Mode())
+	}
+
+	var content []byte
+	switch {
+	case !entry.Existed:
+	case info.Mode()&fs.ModeSymlink != 0:
+		entry.Kind = BackupSymlink
+		if entry.Target, err = os.Readlink(dest); err != nil {
+			return fmt.Errorf("failed to back up: %w", err)
+		}
+	case info.IsDir():
+		entry.Kind = BackupDirectory
+	default:
+		entry.Kind = BackupFile
+		if content, err = os.ReadFile(dest); err != nil {
+			return fmt.Errorf("failed to back up: %w", err)
+		}
+	}
+
+	if previous, ok := b.ledger.entry(dest); ok {
+		entry.Ledger = &previous
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.dir == "" {
+		dir, err := createBackupDir(b.backup.ID)
+		if err != nil {
+			return err
+		}
+
+		b.dir, b.backup.ID = dir, filepath.Base(dir)
+	}
+
+	stored := filepath.Join("files", strconv.Itoa(len(b.backup.Entries)))
+
+	if entry.Kind == BackupFile {
+		entry.File = stored
+
+		if err := os.MkdirAll(filepath.Join(b.dir, "files"), 0o700); err != nil {
+			return fmt.Errorf("failed to back up: %w", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(b.dir, entry.File), content, 0o600); err != nil {
+			return fmt.Errorf("failed to back up: %w", err)
+		}
+	}
+
+	if entry.Kind == BackupDirectory && len(children) > 0 {
+		entry.Tree = stored
+
+		if err := b.snapshotTree(dest, entry.Tree, children); err != nil {
+			return fmt.Errorf("failed to back up: %w", err)
+		}
+	}
+
+	b.backup.Entries = append(b.backup.Entries, entry)
+
+	return nil
+}
+
+func (b *backupRun) holds(dest string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return slices.ContainsFunc(b.backup.Entries, func(entry BackupEntry) bool { return entry.Dest == dest })
+}
+
+func (b *backupRun) snapshotTree(dest, tree string, children []string) error {
+	if err := os.MkdirAll(filepath.Join(b.dir, tree), 0o700); err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(b.dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	for _, name := range children {
+		if err := copyTree(filepath.Join(dest, name), root, filepath.Join(tree, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyTree(src string, dst *os.Root, rel string) error {
+	var dirs []string
+	modes := map[string]fs.FileMode{}
+
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		sub, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(rel, sub)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			return dst.Symlink(link, target)
+		case info.IsDir():
+			dirs, modes[target] = append(dirs, target), info.Mode().Perm()
+
+			return dst.Mkdir(target, 0o700)
+		case info.Mode().IsRegular():
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			if err := dst.WriteFile(target, content, 0o600); err != nil {
+				return err
+			}
+
+			return dst.Chmod(target, info.Mode().Perm())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range slices.Backward(dirs) {
+		if err := dst.Chmod(dir, modes[dir]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *backupRun) save(rep reporter) error {
+	if b == nil || b.dir == "" {
+		return nil
+	}
+
+	slices.SortFunc(b.backup.Entries, func(x, y BackupEntry) int {
+		return strings.Compare(x.Dest, y.Dest)
+	})
+
+	data, err := json.MarshalIndent(b.backup, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup: %w", err)
+	}
+
+	if err := writeAtomic(b.dir, filepath.Join(b.dir, backupManifest), append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+
+	rep.backedUp(b.backup.ID, len(b.backup.Entries))
+
+	return pruneBackups(backupRetention)
+}
+
+func createBackupDir(id string) (string, error) {
+	if err := os.MkdirAll(BackupsDir(), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	candidate := id
+	for i := 2; ; i++ {
+		dir := filepath.Join(BackupsDir(), candidate)
+
+		err := os.Mkdir(dir, 0o700)
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to create backup directory: %w", err)
+		}
+
+		candidate = fmt.Sprintf("%s-%d", id, i)
+	}
+}
+
+func ListBackups() ([]Backup, error) {
+	entries, err := os.ReadDir(BackupsDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backups: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		backup, err := LoadBackup(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backup)
+	}
+
+	slices.SortFunc(backups, func(x, y Backup) int {
+		return x.CreatedAt.Compare(y.CreatedAt)
+	})
+
+	return backups, nil
+}
+
+func LoadBackup(id string) (Backup, error) {
+	if !filepath.IsLocal(id) || filepath.Base(id) != id {
+		return Backup{}, fmt.Errorf("invalid backup run %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(BackupsDir(), id, backupManifest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Backup{}, fmt.Errorf("no backup run %q", id)
+	}
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to read backup %q: %w", id, err)
+	}
+
+	var backup Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return Backup{}, fmt.Errorf("failed to parse backup %q: %w", id, err)
+	}
+
+	return backup, nil
+}
+
+func pruneBackups(keep int) error {
+	backups, err := ListBackups()
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > keep {
+		if err := os.RemoveAll(filepath.Join(BackupsDir(), backups[0].ID)); err != nil {
+			return fmt.Errorf("failed to remove backup %q: %w", backups[0].ID, err)
+		}
+
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+func Rollback(opts RollbackOptions) error {
+	rep := reporter{out: opts.Out, styled: opts.Styled}
+
+	unlock, err := acquireLock(rep)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id := opts.Run
+	if id == "" {
+		backups, err := ListBackups()
+		if err != nil {
+			return err
+		}
+
+		if len(backups) == 0 {
+			return fmt.Errorf("no seed backups to roll back")
+		}
+
+		id = backups[len(backups)-1].ID
+	}
+
+	backup, err := LoadBackup(id)
+	if err != nil {
+		return err
+	}
+
+	entries, err := backup.selectDests(opts.Dests)
+	if err != nil {
+		return err
+	}
+
+	contents := make([][]byte, len(entries))
+	trees := make([]string, len(entries))
+	for i, entry := range entries {
+		if entry.Tree != "" {
+			trees[i] = filepath.Join(BackupsDir(), backup.ID, entry.Tree)
+			if _, err := os.Stat(trees[i]); err != nil {
+				return fmt.Errorf("backup of %q is incomplete: %w", entry.Dest, err)
+			}
+		}
+
+		if entry.Kind != BackupFile {
+			continue
+		}
+
+		if contents[i], err = os.ReadFile(filepath.Join(BackupsDir(), backup.ID, entry.File)); err != nil {
+			return fmt.Errorf("backup of %q is incomplete: %w", entry.Dest, err)
+		}
+	}
+
+	ledger, err := LoadLedger(LedgerPath())
+	if err != nil {
+		return err
+	}
+
+	vars := resolveVars()
+
+	failures := 0
+	for i, entry := range entries {
+		if err := entry.restore(vars, contents[i], trees[i]); err != nil {
+			rep.skip(entry.Dest, err.Error())
+			failures++
+			continue
+		}
+
+		ledger.restore(entry)
+		rep.restored(entry.Dest)
+	}
+
+	if err := ledger.Save(LedgerPath()); err != nil {
+		return err
+	}
+
+	if failures > 0 {
+		noun := "destinations"
+		if failures == 1 {
+			noun = "destination"
+		}
+
+		return fmt.Errorf("%d %s failed to roll back", failures, noun)
+	}
+
+	return nil
+}
+
+func (b Backup) selectDests(args []string) ([]BackupEntry, error) {
+	if len(args) == 0 {
+		return b.Entries, nil
+	}
+
+	vars := resolveVars()
+
+	var selected []BackupEntry
+	for _, arg := range args {
+		dest, err := vars.expand(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		index := slices.IndexFunc(b.Entries, func(entry BackupEntry) bool { return entry.Dest == dest })
+		if index < 0 {
+			return nil, fmt.Errorf("backup run %s has no entry for %s", b.ID, dest)
+		}
+
+		selected = append(selected, b.Entries[index])
+	}
+
+	return selected, nil
+}
+
+func (e BackupEntry) restore(vars Vars, content []byte, tree string) error {
+	ancestor := nearestExistingAncestor(e.Dest)
+	if !ownsPath(ancestor) {
+		return fmt.Errorf("destination not owned")
+	}
+
+	anchor := chooseAnchor(e.Dest, vars, ancestor)
+
+	if !e.Existed {
+		info, err := os.Lstat(e.Dest)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return fmt.Errorf("destination unreadable: %w", err)
+		case info.Mode()&fs.ModeSymlink != 0:
+			return removeNode(anchor, e.Dest, fs.ModeSymlink)
+		case info.IsDir():
+			return removeNode(anchor, e.Dest, fs.ModeDir)
+		}
+
+		return removeAtomic(anchor, e.Dest)
+	}
+
+	mode, err := internalIO.ParseFileMode(e.Mode)
+	if err != nil {
+		return err
+	}
+
+	switch e.Kind {
+	case BackupSymlink:
+		if info, err := os.Lstat(e.Dest); err == nil && info.Mode()&fs.ModeSymlink == 0 {
+			if err := removeAtomic(anchor, e.Dest); err != nil {
+				return err
+			}
+		}
+
+		return writeSymlink(anchor, e.Dest, e.Target)
+	case BackupDirectory:
+		if err := ensureDirectory(anchor, e.Dest, mode, nil); err != nil {
+			return err
+		}
+
+		return restoreTree(e.Dest, tree)
+	}
+
+	if info, err := os.Lstat(e.Dest); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if err := removeNode(anchor, e.Dest, fs.ModeSymlink); err != nil {
+			return err
+		}
+	}
+
+	return writeAtomic(anchor, e.Dest, content, mode)
+}
+
+func restoreTree(dest, tree string) error {
+	if tree == "" {
+		return nil
+	}
+
+	children, err := os.ReadDir(tree)
+	if err != nil {
+		return fmt.Errorf("backup unreadable: %w", err)
+	}
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return fmt.Errorf("failed to open root %q: %w", dest, err)
+	}
+	defer root.Close()
+
+	for _, child := range children {
+		if err := root.RemoveAll(child.Name()); err != nil {
+			return fmt.Errorf("failed to restore %q: %w", child.Name(), err)
+		}
+
+		if err := copyTree(filepath.Join(tree, child.Name()), root, child.Name()); err != nil {
+			return fmt.Errorf("failed to restore %q: %w", child.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (l *Ledger) restore(backup BackupEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if backup.Ledger == nil {
+		delete(l.Entries, backup.Dest)
+		return
+	}
+
+	l.Entries[backup.Dest] = *backup.Ledger
+}
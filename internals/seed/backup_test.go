@@ -0,0 +1,185 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func rollback(t *testing.T, opts RollbackOptions) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	opts.Out = &buffer
+	assert.NilError(t, Rollback(opts))
+	return buffer.String()
+}
+
+func TestBackupAndRollback(t *testing.T) {
+	t.Run("RestoresForcedOverwrite", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "settings.json")
+		write(t, dest, `{"mine": true}`)
+		assert.NilError(t, os.Chmod(dest, 0o640))
+
+		write(t, rhyming(source, dest), `{"theirs": true}`)
+
+		output := apply(t, Options{Source: source, Force: true})
+		assert.Assert(t, strings.Contains(output, "Backed up 1 destination as run "))
+		assert.Equal(t, read(t, dest), `{"theirs": true}`)
+
+		output = rollback(t, RollbackOptions{})
+		assert.Assert(t, strings.Contains(output, "Restored ["+dest+"]"))
+		assert.Equal(t, read(t, dest), `{"mine": true}`)
+		assert.Equal(t, mode(t, dest), os.FileMode(0o640))
+
+		ledger, err := LoadLedger(LedgerPath())
+		assert.NilError(t, err)
+		_, tracked := ledger.Entries[dest]
+		assert.Assert(t, !tracked)
+	})
+
+	t.Run("RestoresMerge", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "settings.json")
+		write(t, dest, `{"a": 1}`)
+
+		write(t, rhyming(source, dest), `{"b": 2}`)
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: merge\n    force: true\n", dest))
+
+		apply(t, Options{Source: source})
+		assert.Equal(t, len(decodeBack(t, []byte(read(t, dest)), dest)), 2)
+
+		rollback(t, RollbackOptions{})
+		assert.Equal(t, read(t, dest), `{"a": 1}`)
+	})
+
+	t.Run("RemovesCreated", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		file := filepath.Join(target, "new.txt")
+		link := filepath.Join(target, "link")
+
+		write(t, rhyming(source, file), "x\n")
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /etc/hosts\n", link))
+
+		apply(t, Options{Source: source})
+		assert.Assert(t, fileExists(file))
+
+		rollback(t, RollbackOptions{})
+
+		_, err := os.Lstat(link)
+		assert.Assert(t, os.IsNotExist(err))
+		assert.Assert(t, !fileExists(file))
+	})
+
+	t.Run("RestoresEmptiedDirectory", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "scratch")
+		write(t, filepath.Join(dest, "notes.txt"), "mine")
+		write(t, filepath.Join(dest, "nested", "key"), "secret")
+		assert.NilError(t, os.Chmod(filepath.Join(dest, "nested", "key"), 0o600))
+		assert.NilError(t, os.Symlink("notes.txt", filepath.Join(dest, "latest")))
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: directory\n    empty: true\n", dest))
+
+		apply(t, Options{Source: source, Force: true})
+		entries, err := os.ReadDir(dest)
+		assert.NilError(t, err)
+		assert.Equal(t, len(entries), 0)
+
+		rollback(t, RollbackOptions{})
+
+		assert.Equal(t, read(t, filepath.Join(dest, "notes.txt")), "mine")
+		assert.Equal(t, read(t, filepath.Join(dest, "nested", "key")), "secret")
+		assert.Equal(t, mode(t, filepath.Join(dest, "nested", "key")), os.FileMode(0o600))
+
+		link, err := os.Readlink(filepath.Join(dest, "latest"))
+		assert.NilError(t, err)
+		assert.Equal(t, link, "notes.txt")
+	})
+
+	t.Run("UnchangedIsNotBackedUp", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		write(t, rhyming(source, dest), "x\n")
+		apply(t, Options{Source: source})
+
+		output := apply(t, Options{Source: source, Force: true})
+		assert.Assert(t, !strings.Contains(output, "Backed up"))
+
+		backups, err := ListBackups()
+		assert.NilError(t, err)
+		assert.Equal(t, len(backups), 1)
+	})
+
+	t.Run("SelectRunAndDest", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		first := filepath.Join(target, "a.txt")
+		second := filepath.Join(target, "b.txt")
+		write(t, first, "a0\n")
+		write(t, second, "b0\n")
+
+		write(t, rhyming(source, first), "a1\n")
+		write(t, rhyming(source, second), "b1\n")
+		apply(t, Options{Source: source, Force: true})
+
+		write(t, rhyming(source, first), "a2\n")
+		apply(t, Options{Source: source, Force: true})
+
+		backups, err := ListBackups()
+		assert.NilError(t, err)
+		assert.Equal(t, len(backups), 2)
+
+		rollback(t, RollbackOptions{Run: backups[0].ID, Dests: []string{first}})
+
+		assert.Equal(t, read(t, first), "a0\n")
+		assert.Equal(t, read(t, second), "b1\n")
+
+		var buffer bytes.Buffer
+		err = Rollback(RollbackOptions{Run: backups[1].ID, Dests: []string{second}, Out: &buffer})
+		assert.ErrorContains(t, err, "has no entry for "+second)
+
+		err = Rollback(RollbackOptions{Run: "../escape", Out: &buffer})
+		assert.ErrorContains(t, err, "invalid backup run")
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		for i := range backupRetention + 2 {
+			write(t, rhyming(source, dest), fmt.Sprintf("%d\n", i))
+			apply(t, Options{Source: source, Force: true})
+		}
+
+		backups, err := ListBackups()
+		assert.NilError(t, err)
+		assert.Equal(t, len(backups), backupRetention)
+	})
+
+	t.Run("NothingToRollBack", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+
+		var buffer bytes.Buffer
+		assert.ErrorContains(t, Rollback(RollbackOptions{Out: &buffer}), "no seed backups")
+	})
+}
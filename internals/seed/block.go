@@ -55,33 +55,63 @@ func appendBlock(existing, block []byte) []byte {
 	return buffer.Bytes()
 }
 
-func ensureBlock(existing, body []byte, comment string) ([]byte, error) {
-	beginMarker, endMarker := blockMarkers(comment)
-	block := renderBlock(body, beginMarker, endMarker)
-	lines := bytes.SplitAfter(existing, []byte("\n"))
-
+func locateBlock(lines [][]byte, beginMarker, endMarker string) (int, int, error) {
 	begin, end := -1, -1
 	for i, line := range lines {
 		switch strings.TrimRight(string(line), "\n") {
 		case beginMarker:
 			if begin >= 0 {
-				return nil, fmt.Errorf("malformed managed block: duplicate begin marker")
+				return 0, 0, fmt.Errorf("malformed managed block: duplicate begin marker")
 			}
 			begin = i
 		case endMarker:
 			if end >= 0 {
-				return nil, fmt.Errorf("malformed managed block: duplicate end marker")
+				return 0, 0, fmt.Errorf("malformed managed block: duplicate end marker")
 			}
 			end = i
 		}
 	}
 
 	if begin < 0 && end < 0 {
-		return appendBlock(existing, block), nil
+		return -1, -1, nil
 	}
 
 	if begin < 0 || end < 0 || end < begin {
-		return nil, fmt.Errorf("malformed managed block: markers out of order")
+		return 0, 0, fmt.Errorf("malformed managed block: markers out of order")
+	}
+
+	return begin, end, nil
+}
+
+func currentBlock(existing []byte, comment string) ([]byte, bool, error) {
+	beginMarker, endMarker := blockMarkers(comment)
+	lines := bytes.SplitAfter(existing, []byte("\n"))
+
+	begin, end, err := locateBlock(lines, beginMarker, endMarker)
+	if err != nil || begin < 0 {
+		return nil, false, err
+	}
+
+	block := bytes.Join(lines[begin:end+1], nil)
+	if block[len(block)-1] != '\n' {
+		block = append(block, '\n')
+	}
+
+	return block, true, nil
+}
+
+func ensureBlock(existing, body []byte, comment string) ([]byte, error) {
+	beginMarker, endMarker := blockMarkers(comment)
+	block := renderBlock(body, beginMarker, endMarker)
+	lines := bytes.SplitAfter(existing, []byte("\n"))
+
+	begin, end, err := locateBlock(lines, beginMarker, endMarker)
+	if err != nil {
+		return nil, err
+	}
+
+	if begin < 0 {
+		return appendBlock(existing, block), nil
 	}
 
 	var buffer bytes.Buffer
@@ -95,3 +125,23 @@ func ensureBlock(existing, body []byte, comment string) ([]byte, error) {
 
 	return buffer.Bytes(), nil
 }
+
+func removeBlock(existing []byte, comment string) ([]byte, error) {
+	beginMarker, endMarker := blockMarkers(comment)
+	lines := bytes.SplitAfter(existing, []byte("\n"))
+
+	begin, end, err := locateBlock(lines, beginMarker, endMarker)
+	if err != nil || begin < 0 {
+		return existing, err
+	}
+
+	var buffer bytes.Buffer
+	for _, line := range lines[:begin] {
+		buffer.Write(line)
+	}
+	for _, line := range lines[end+1:] {
+		buffer.Write(line)
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -94,6 +94,52 @@ func TestEnsureBlock(t *testing.T) {
 	}
 }
 
+func TestRemoveBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		comment  string
+		want     string
+		err      string
+	}{
+		{
+			name:     "StripsBlockPreservingSurround",
+			existing: "head\n# >>> ws-seed >>>\nbody\n# <<< ws-seed <<<\ntail\n",
+			want:     "head\ntail\n",
+		},
+		{
+			name:     "CustomComment",
+			existing: "// >>> ws-seed >>>\nbody\n// <<< ws-seed <<<\n",
+			comment:  "//",
+			want:     "",
+		},
+		{
+			name:     "NoBlockIsNoop",
+			existing: "untouched\n",
+			want:     "untouched\n",
+		},
+		{
+			name:     "MalformedRejected",
+			existing: "# >>> ws-seed >>>\norphan\n",
+			err:      "markers out of order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := removeBlock([]byte(tt.existing), tt.comment)
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tt.want)
+		})
+	}
+}
+
 func TestApplyBlock(t *testing.T) {
 	t.Run("CreatesWhenAbsent", func(t *testing.T) {
 		setEnv(t, t.TempDir())
@@ -0,0 +1,242 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContext  = 3
+	maxDiffEdits = 1000
+)
+
+type editKind byte
+
+const (
+	editEqual  editKind = ' '
+	editDelete editKind = '-'
+	editInsert editKind = '+'
+)
+
+type edit struct {
+	kind editKind
+	line string
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	parts := bytes.SplitAfter(content, []byte("\n"))
+	if len(parts[len(parts)-1]) == 0 {
+		parts = parts[:len(parts)-1]
+	}
+
+	lines := make([]string, len(parts))
+	for i, part := range parts {
+		lines[i] = string(part)
+	}
+
+	return lines
+}
+
+func diffLines(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b)-prefix-suffix)
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{editEqual, line})
+	}
+
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if middle, ok := shortestEdit(middleA, middleB); ok {
+		edits = append(edits, middle...)
+	} else {
+		for _, line := range middleA {
+			edits = append(edits, edit{editDelete, line})
+		}
+		for _, line := range middleB {
+			edits = append(edits, edit{editInsert, line})
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{editEqual, line})
+	}
+
+	return edits
+}
+
+// shortestEdit is Myers' O((n+m)·D) diff. Each step keeps only the [-d, d]
+// diagonals it reached, and it gives up past maxDiffEdits so a rewritten file
+// becomes one replace hunk instead of a quadratic trace.
+func shortestEdit(a, b []string) ([]edit, bool) {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	var trace [][]int
+	found := false
+
+	for d := 0; d <= n+m && !found; d++ {
+		if d > maxDiffEdits {
+			return nil, false
+		}
+
+		if d > 0 {
+			trace = append(trace, append([]int(nil), v[offset-d+1:offset+d]...))
+		} else {
+			trace = append(trace, nil)
+		}
+
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	var edits []edit
+	x, y := n, m
+
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := func(k int) int { return trace[d][k+d-1] }
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			prevK = k + 1
+		}
+
+		prevX := prev(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{editEqual, a[x-1]})
+			x--
+			y--
+		}
+
+		if x == prevX {
+			edits = append(edits, edit{editInsert, b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{editDelete, a[x-1]})
+			x--
+		}
+	}
+
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{editEqual, a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits, true
+}
+
+func unifiedDiff(from, to string, old, new []byte) string {
+	edits := diffLines(splitLines(old), splitLines(new))
+
+	var changes []int
+	for i, e := range edits {
+		if e.kind != editEqual {
+			changes = append(changes, i)
+		}
+	}
+
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var buffer strings.Builder
+	fmt.Fprintf(&buffer, "--- %s\n+++ %s\n", from, to)
+
+	for start := 0; start < len(changes); {
+		end := start
+		for end+1 < len(changes) && changes[end+1]-changes[end] <= 2*diffContext {
+			end++
+		}
+
+		writeHunk(&buffer, edits, changes[start], changes[end])
+		start = end + 1
+	}
+
+	return buffer.String()
+}
+
+func writeHunk(buffer *strings.Builder, edits []edit, first, last int) {
+	lo := max(first-diffContext, 0)
+	hi := min(last+diffContext, len(edits)-1)
+
+	oldLine, newLine := 1, 1
+	for _, e := range edits[:lo] {
+		if e.kind != editInsert {
+			oldLine++
+		}
+		if e.kind != editDelete {
+			newLine++
+		}
+	}
+
+	oldLen, newLen := 0, 0
+	for _, e := range edits[lo : hi+1] {
+		if e.kind != editInsert {
+			oldLen++
+		}
+		if e.kind != editDelete {
+			newLen++
+		}
+	}
+
+	fmt.Fprintf(buffer, "@@ -%s +%s @@\n", hunkRange(oldLine, oldLen), hunkRange(newLine, newLen))
+
+	for _, e := range edits[lo : hi+1] {
+		buffer.WriteByte(byte(e.kind))
+		buffer.WriteString(e.line)
+
+		if !strings.HasSuffix(e.line, "\n") {
+			buffer.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		start--
+	}
+
+	if length == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, length)
+}
@@ -0,0 +1,82 @@
+package seed
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Run("Identical", func(t *testing.T) {
+		assert.Equal(t, unifiedDiff("a", "b", []byte("same\n"), []byte("same\n")), "")
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		got := unifiedDiff("/dev/null", "/x", nil, []byte("one\ntwo\n"))
+
+		assert.Equal(t, got, "--- /dev/null\n+++ /x\n@@ -0,0 +1,2 @@\n+one\n+two\n")
+	})
+
+	t.Run("ModifyWithContext", func(t *testing.T) {
+		old := []byte("1\n2\n3\n4\n5\n6\n7\n8\n")
+		new := []byte("1\n2\n3\n4\nfive\n6\n7\n8\n")
+
+		got := unifiedDiff("/x", "/x", old, new)
+
+		assert.Equal(t, got, "--- /x\n+++ /x\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n")
+	})
+
+	t.Run("DistantChangesSplitHunks", func(t *testing.T) {
+		old := []byte("a\n1\n2\n3\n4\n5\n6\n7\n8\nz\n")
+		new := []byte("A\n1\n2\n3\n4\n5\n6\n7\n8\nZ\n")
+
+		got := unifiedDiff("/x", "/x", old, new)
+
+		assert.Equal(t, got, "--- /x\n+++ /x\n"+
+			"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n"+
+			"@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-z\n+Z\n")
+	})
+
+	t.Run("MissingTrailingNewline", func(t *testing.T) {
+		got := unifiedDiff("/x", "/x", []byte("a"), []byte("a\n"))
+
+		assert.Equal(t, got, "--- /x\n+++ /x\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n")
+	})
+	t.Run("LargeRewriteIsOneHunk", func(t *testing.T) {
+		var old, new strings.Builder
+		for i := range 6000 {
+			fmt.Fprintf(&old, "old %d\n", i)
+			fmt.Fprintf(&new, "new %d\n", i)
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		got := unifiedDiff("/x", "/x", []byte(old.String()), []byte(new.String()))
+		runtime.ReadMemStats(&after)
+
+		assert.Assert(t, strings.HasPrefix(got, "--- /x\n+++ /x\n@@ -1,6000 +1,6000 @@\n-old 0\n"))
+		assert.Equal(t, strings.Count(got, "@@ "), 1)
+		assert.Assert(t, after.TotalAlloc-before.TotalAlloc < 64<<20)
+	})
+
+	t.Run("EditsReproduceTarget", func(t *testing.T) {
+		a := splitLines([]byte("a\nb\nc\nd\ne\nf\ng\n"))
+		b := splitLines([]byte("b\nx\nc\ne\ny\ng\nz\n"))
+
+		var oldSide, newSide []string
+		for _, e := range diffLines(a, b) {
+			if e.kind != editInsert {
+				oldSide = append(oldSide, e.line)
+			}
+			if e.kind != editDelete {
+				newSide = append(newSide, e.line)
+			}
+		}
+
+		assert.DeepEqual(t, oldSide, a)
+		assert.DeepEqual(t, newSide, b)
+	})
+}
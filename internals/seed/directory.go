@@ -0,0 +1,227 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+const defaultDirectoryMode fs.FileMode = 0o755
+
+type directoryState struct {
+	exists bool
+	mode   fs.FileMode
+	extra  []string
+}
+
+func (r reporter) emptied(entries int) {
+	if r.report != nil {
+		return
+	}
+
+	message := fmt.Sprintf("remove %d entries", entries)
+	if entries == 1 {
+		message = "remove 1 entry"
+	}
+
+	if r.styled {
+		fmt.Fprintln(r.out, styles.Muted().Render(message))
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func (op ResolvedOp) directoryMode() (fs.FileMode, error) {
+	if op.Mode == "" {
+		return defaultDirectoryMode, nil
+	}
+
+	return internalIO.ParseFileMode(op.Mode)
+}
+
+func (p *Plan) places(path string) bool {
+	for _, op := range p.Ops {
+		if op.State != PresenceAbsent && isUnder(op.Dest, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Plan) inspectDirectory(dest string, empty bool) (directoryState, error) {
+	info, err := os.Lstat(dest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return directoryState{}, nil
+	}
+	if err != nil {
+		return directoryState{}, fmt.Errorf("destination unreadable: %w", err)
+	}
+
+	if !info.IsDir() {
+		return directoryState{}, fmt.Errorf("exists and is not a directory")
+	}
+
+	state := directoryState{exists: true, mode: info.Mode().Perm()}
+
+	if empty {
+		entries, err := os.ReadDir(dest)
+		if err != nil {
+			return directoryState{}, fmt.Errorf("destination unreadable: %w", err)
+		}
+
+		for _, entry := range entries {
+			if !p.places(filepath.Join(dest, entry.Name())) {
+				state.extra = append(state.extra, entry.Name())
+			}
+		}
+	}
+
+	return state, nil
+}
+
+func (p *Plan) directoryOne(op ResolvedOp, rep reporter, ledger *Ledger, backups *backupRun) error {
+	ancestor := nearestExistingAncestor(op.Dest)
+	if !ownsPath(ancestor) {
+		rep.skip(op.Dest, "destination not owned")
+		return fmt.Errorf("destination not owned")
+	}
+
+	mode, err := op.directoryMode()
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	state, err := p.inspectDirectory(op.Dest, op.Empty)
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	if len(state.extra) > 0 && !op.Force {
+		rep.skip(op.Dest, "exists")
+		return nil
+	}
+
+	changed := !state.exists || state.mode != mode || len(state.extra) > 0
+
+	if changed {
+		if err := op.runHooks(HookBefore, rep); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+
+		if err := backups.snapshot(op.Dest, state.extra...); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+
+		if err := ensureDirectory(chooseAnchor(op.Dest, p.Vars, ancestor), op.Dest, mode, state.extra); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+	}
+
+	if err := ledger.record(op, materialized{mode: mode, sourceHash: hashBytes(nil)}); err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	rep.seeded(op.Dest, changed)
+
+	if changed {
+		if err := op.runHooks(HookOnChange, rep); err != nil {
+			rep.hookFailed(op.Dest, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Plan) previewDirectory(op ResolvedOp, rep reporter, tally *dryRunTally) error {
+	if !ownsPath(nearestExistingAncestor(op.Dest)) {
+		rep.skip(op.Dest, "destination not owned")
+		tally.skipped++
+		return fmt.Errorf("destination not owned")
+	}
+
+	mode, err := op.directoryMode()
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		tally.skipped++
+		return err
+	}
+
+	state, err := p.inspectDirectory(op.Dest, op.Empty)
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		tally.skipped++
+		return err
+	}
+
+	switch {
+	case !state.exists:
+		rep.planned(changeCreate, op.Dest)
+		tally.create++
+	case len(state.extra) > 0 && !op.Force:
+		rep.skip(op.Dest, "exists")
+		tally.skipped++
+		return nil
+	case state.mode != mode || len(state.extra) > 0:
+		rep.planned(changeModify, op.Dest)
+		tally.modify++
+	default:
+		rep.planned(changeUnchanged, op.Dest)
+		tally.unchanged++
+		return nil
+	}
+
+	if state.exists && state.mode != mode {
+		rep.modeChange(state.mode, mode)
+	}
+
+	if len(state.extra) > 0 {
+		rep.emptied(len(state.extra))
+	}
+
+	op.planHooks(rep)
+
+	return nil
+}
+
+func ensureDirectory(anchor, dest string, mode fs.FileMode, remove []string) error {
+	root, err := os.OpenRoot(anchor)
+	if err != nil {
+		return fmt.Errorf("failed to open root %q: %w", anchor, err)
+	}
+	defer root.Close()
+
+	rel, err := filepath.Rel(anchor, dest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve relative path: %w", err)
+	}
+
+	if err := root.MkdirAll(rel, mode); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := root.Chmod(rel, mode); err != nil {
+		return fmt.Errorf("failed to set mode: %w", err)
+	}
+
+	for _, name := range remove {
+		if err := root.RemoveAll(filepath.Join(rel, name)); err != nil {
+			return fmt.Errorf("failed to empty directory: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestApplyDirectory(t *testing.T) {
+	t.Run("CreatedWithMode", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, ".ssh", "sockets")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: directory\n    mode: \"0o700\"\n", dest))
+
+		apply(t, Options{Source: source})
+
+		info, err := os.Stat(dest)
+		assert.NilError(t, err)
+		assert.Assert(t, info.IsDir())
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0o700))
+	})
+
+	t.Run("ExistingModeCorrected", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "cache")
+		assert.NilError(t, os.Mkdir(dest, 0o777))
+		assert.NilError(t, os.Chmod(dest, 0o777))
+		write(t, filepath.Join(dest, "keep"), "x")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: directory\n", dest))
+
+		apply(t, Options{Source: source})
+
+		assert.Equal(t, mode(t, dest), os.FileMode(0o755))
+		assert.Assert(t, fileExists(filepath.Join(dest, "keep")))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "scratch")
+		write(t, filepath.Join(dest, "a.txt"), "a")
+		write(t, filepath.Join(dest, "nested", "b.txt"), "b")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: directory\n    empty: true\n", dest))
+
+		output := apply(t, Options{Source: source, DryRun: true, Force: true})
+		assert.Assert(t, strings.Contains(output, "remove 2 entries"))
+
+		apply(t, Options{Source: source, Force: true})
+
+		entries, err := os.ReadDir(dest)
+		assert.NilError(t, err)
+		assert.Equal(t, len(entries), 0)
+	})
+
+	t.Run("EmptyRequiresForce", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "scratch")
+		write(t, filepath.Join(dest, "notes.txt"), "mine")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: directory\n    empty: true\n", dest))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (exists)"))
+
+		output = apply(t, Options{Source: source})
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (exists)"))
+		assert.Equal(t, readFile(t, filepath.Join(dest, "notes.txt")), "mine")
+	})
+
+	t.Run("EmptyKeepsPlannedChildren", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "scratch")
+		child := filepath.Join(dest, "nested", "kept.txt")
+		log := filepath.Join(target, "hooks.log")
+		write(t, filepath.Join(dest, "stale.txt"), "old")
+
+		writeManifest(t, source, fmt.Sprintf(
+			"seeds:\n  %s:\n    op: directory\n    empty: true\n    force: true\n    onChange: echo changed >> %s\n  %s:\n    content: \"kept\\n\"\n",
+			dest, log, child,
+		))
+
+		apply(t, Options{Source: source})
+		assert.Assert(t, !fileExists(filepath.Join(dest, "stale.txt")))
+		assert.Equal(t, readFile(t, child), "kept\n")
+
+		output := apply(t, Options{Source: source})
+		assert.Assert(t, !strings.Contains(output, "Ran onChange"))
+		assert.Equal(t, readFile(t, child), "kept\n")
+		assert.Equal(t, readFile(t, log), "changed\n")
+	})
+
+	t.Run("FileInTheWay", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "dir")
+		write(t, dest, "file\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: directory\n", dest))
+
+		output := applyErr(t, Options{Source: source})
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (exists and is not a directory)"))
+	})
+
+	t.Run("AbsentRemovesOnlyWhenEmpty", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "old")
+		write(t, filepath.Join(dest, "file"), "x")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: directory\n    state: absent\n", dest))
+
+		output := applyErr(t, Options{Source: source})
+		assert.Assert(t, strings.Contains(output, "(directory not empty)"))
+
+		assert.NilError(t, os.Remove(filepath.Join(dest, "file")))
+		apply(t, Options{Source: source})
+		assert.Assert(t, !fileExists(dest))
+	})
+}
@@ -0,0 +1,240 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+type change string
+
+const (
+	changeCreate    change = "Create"
+	changeModify    change = "Modify"
+	changeRemove    change = "Remove"
+	changeUnchanged change = "Unchanged"
+)
+
+type dryRunTally struct {
+	create    int
+	modify    int
+	remove    int
+	unchanged int
+	skipped   int
+}
+
+func (t *dryRunTally) add(other dryRunTally) {
+	t.create += other.create
+	t.modify += other.modify
+	t.remove += other.remove
+	t.unchanged += other.unchanged
+	t.skipped += other.skipped
+}
+
+func (r reporter) planned(kind change, dest string) {
+	if r.report != nil {
+		result := ResultPlanned
+		if kind == changeUnchanged {
+			result = ResultUnchanged
+		}
+
+		r.report.record(result, "")
+		r.report.update(func(entry *EntryReport) { entry.Change = strings.ToLower(string(kind)) })
+		return
+	}
+
+	if r.styled {
+		styles.PrintKeyValue(r.out, string(kind), dest)
+		return
+	}
+
+	fmt.Fprintf(r.out, "%s [%s]\n", kind, dest)
+}
+
+func (r reporter) modeChange(from, to fs.FileMode) {
+	if r.report != nil {
+		r.report.update(func(entry *EntryReport) { entry.Mode = formatMode(to) })
+		return
+	}
+
+	fmt.Fprintf(r.out, "mode %#o -> %#o\n", from, to)
+}
+
+func (r reporter) redacted() {
+	if r.report != nil {
+		r.report.update(func(entry *EntryReport) { entry.Redacted = true })
+		return
+	}
+
+	if r.styled {
+		fmt.Fprintln(r.out, styles.Muted().Render("(secret content redacted)"))
+		return
+	}
+
+	fmt.Fprintln(r.out, "(secret content redacted)")
+}
+
+func (r reporter) diff(text string) {
+	if r.report != nil {
+		r.report.update(func(entry *EntryReport) { entry.Diff = text })
+		return
+	}
+
+	if !r.styled {
+		fmt.Fprint(r.out, text)
+		return
+	}
+
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Fprintln(r.out, styles.Key().Render(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Fprintln(r.out, styles.Info().Render(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Fprintln(r.out, styles.Success().Render(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Fprintln(r.out, styles.Error().Render(line))
+		default:
+			fmt.Fprintln(r.out, line)
+		}
+	}
+}
+
+func (r reporter) summary(tally dryRunTally) {
+	message := fmt.Sprintf(
+		"Dry run: %d to create, %d to modify, %d to remove, %d unchanged, %d skipped",
+		tally.create, tally.modify, tally.remove, tally.unchanged, tally.skipped,
+	)
+
+	if r.report != nil {
+		return
+	}
+
+	if r.styled {
+		fmt.Fprintln(r.out, styles.Header().Render(message))
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func (p *Plan) previewOne(op ResolvedOp, keys *keyResolver, rep reporter, tally *dryRunTally) error {
+	switch {
+	case op.State == PresenceAbsent:
+		return p.previewRetract(op, keys, rep, tally)
+	case op.Op == OpSymlink:
+		return p.previewLink(op, rep, tally)
+	case op.Op == OpDirectory:
+		return p.previewDirectory(op, rep, tally)
+	}
+
+	_, reason, err := precheck(op)
+	if reason != "" {
+		rep.skip(op.Dest, reason)
+		tally.skipped++
+		return err
+	}
+
+	result, err := p.materialize(op, keys)
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		tally.skipped++
+		return err
+	}
+
+	rep.secret(result.secret)
+
+	from := op.Dest
+	existing, readErr := os.ReadFile(op.Dest)
+	info, statErr := os.Stat(op.Dest)
+
+	kind := changeModify
+	switch {
+	case readErr != nil || statErr != nil:
+		kind = changeCreate
+		from = os.DevNull
+	case bytes.Equal(existing, result.content) && info.Mode().Perm() == result.mode:
+		kind = changeUnchanged
+	}
+
+	rep.planned(kind, op.Dest)
+
+	switch kind {
+	case changeCreate:
+		tally.create++
+	case changeModify:
+		tally.modify++
+	case changeUnchanged:
+		tally.unchanged++
+		return nil
+	}
+
+	if statErr == nil && info.Mode().Perm() != result.mode {
+		rep.modeChange(info.Mode().Perm(), result.mode)
+	}
+
+	if result.warning != "" {
+		rep.warn(result.warning)
+	}
+
+	op.planHooks(rep)
+
+	if result.secret {
+		rep.redacted()
+		return nil
+	}
+
+	rep.diff(unifiedDiff(from, op.Dest, existing, result.content))
+
+	return nil
+}
+
+func (p *Plan) previewRetract(op ResolvedOp, keys *keyResolver, rep reporter, tally *dryRunTally) error {
+	if !ownsPath(nearestExistingAncestor(op.Dest)) {
+		rep.skip(op.Dest, "destination not owned")
+		tally.skipped++
+		return fmt.Errorf("destination not owned")
+	}
+
+	result, err := p.retract(op, keys)
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		tally.skipped++
+		return err
+	}
+
+	rep.secret(result.secret)
+
+	switch {
+	case result.remove && op.Op == OpCopy && !op.Force && !inSyncDests([]string{op.Dest})[op.Dest]:
+		rep.skip(op.Dest, "modified")
+		tally.skipped++
+	case result.remove:
+		rep.planned(changeRemove, op.Dest)
+		op.planHooks(rep)
+		tally.remove++
+	case result.changed:
+		rep.planned(changeModify, op.Dest)
+		op.planHooks(rep)
+		tally.modify++
+
+		if result.secret {
+			rep.redacted()
+			return nil
+		}
+
+		rep.diff(unifiedDiff(op.Dest, op.Dest, readExisting(op.Dest), result.content))
+	default:
+		rep.planned(changeUnchanged, op.Dest)
+		tally.unchanged++
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"github.com/kloudkit/ws-cli/internals/secrets"
+	"github.com/kloudkit/ws-cli/internals/styles"
+	"gopkg.in/yaml.v3"
+)
+
+type EditOptions struct {
+	Source    string
+	Target    string
+	MasterKey string
+	Identity  string
+	Context   string
+	Edit      func(path string) error
+	Out       io.Writer
+	Styled    bool
+}
+
+func EditSecret(opts EditOptions) error {
+	target, _, err := editTarget(opts)
+	if err != nil {
+		return err
+	}
+
+	context := opts.Context
+	if context == "" {
+		context = target.context()
+	}
+
+	var (
+		master   []byte
+		identity secrets.Identity
+		plain    []byte
+	)
+
+	recipient := secrets.IsRecipientEncrypted(target.cipher)
+	if recipient {
+		if identity, err = secrets.ResolveIdentity(opts.Identity); err != nil {
+			return err
+		}
+
+		plain, err = secrets.DecryptWithContext(target.cipher, identity, context)
+	} else {
+		if master, err = secrets.ResolveMasterKey(opts.MasterKey); err != nil {
+			return err
+		}
+		defer zeroBytes(master)
+
+		plain, err = secrets.DecryptContext(target.cipher, master, context)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: decrypt failed: %w", target.describe, err)
+	}
+	defer zeroBytes(plain)
+
+	name := "secret"
+	if target.dest != "" {
+		name = filepath.Base(target.dest)
+	}
+
+	raw, err := editPlaintext(plain, name, opts.Edit)
+	if err != nil {
+		return err
+	}
+	defer zeroBytes(raw)
+
+	edited := raw
+	if target.section == "secrets" {
+		edited = bytes.TrimRight(raw, "\r\n")
+	}
+
+	if bytes.Equal(edited, plain) {
+		fmt.Fprintf(opts.Out, "No changes to %s\n", target.describe)
+		return nil
+	}
+
+	if !secrets.IsBound(target.cipher) {
+		context = ""
+	}
+
+	var updated string
+	if recipient {
+		var recipients []secrets.Recipient
+		if recipients, err = editRecipients(opts, target); err != nil {
+			return err
+		}
+
+		updated, err = secrets.EncryptForContext(edited, recipients, context)
+	} else {
+		updated, err = secrets.EncryptContext(edited, master, context)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: re-encrypt failed: %w", target.describe, err)
+	}
+
+	unlock, err := acquireLock(reporter{out: opts.Out, styled: opts.Styled})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, docs, err := editTarget(opts)
+	if err != nil {
+		return err
+	}
+
+	if current.cipher != target.cipher {
+		return fmt.Errorf("%s changed while it was being edited; nothing was written", target.describe)
+	}
+
+	if err := current.writeBack(updated); err != nil {
+		return fmt.Errorf("%s: %w", current.describe, err)
+	}
+
+	if current.writePath == "" {
+		if err := writeManifestFile(current.manifestPath, docs[current.manifestPath]); err != nil {
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("Updated %s", target.describe)
+	if opts.Styled {
+		styles.PrintSuccess(opts.Out, message)
+	} else {
+		fmt.Fprintln(opts.Out, message)
+	}
+
+	return nil
+}
+
+func editTarget(opts EditOptions) (rotateTarget, map[string]*yaml.Node, error) {
+	if internalIO.FileExists(opts.Target) {
+		path, err := filepath.Abs(opts.Target)
+		if err != nil {
+			return rotateTarget{}, nil, err
+		}
+
+		target, err := fileTarget(fmt.Sprintf("file %q", opts.Target), path)
+
+		return target, nil, err
+	}
+
+	layers := Layers(opts.Source)
+	if len(layers) == 0 {
+		return rotateTarget{}, nil, fmt.Errorf("%q is not a file; to edit a manifest key configure a seed source (use --source)", opts.Target)
+	}
+
+	targets, docs, err := collectLayers(layers)
+	if err != nil {
+		return rotateTarget{}, nil, err
+	}
+
+	for i := len(targets) - 1; i >= 0; i-- {
+		if targets[i].context() == opts.Target {
+			return targets[i], docs, nil
+		}
+	}
+
+	return rotateTarget{}, nil, fmt.Errorf("no file, secret or secret seed matches %q (use secrets.<name> or seeds.<destination>)", opts.Target)
+}
+
+func editRecipients(opts EditOptions, target rotateTarget) ([]secrets.Recipient, error) {
+	manifestPath := target.manifestPath
+	if manifestPath == "" {
+		layers := Layers(opts.Source)
+		if len(layers) == 0 {
+			return nil, fmt.Errorf("%s is encrypted for recipients; configure the seed source that lists them (use --source)", target.describe)
+		}
+
+		manifestPath = ManifestPath(layers[len(layers)-1])
+	}
+
+	recipients, err := manifestRecipients(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%s is encrypted for recipients but %s lists none", target.describe, manifestPath)
+	}
+
+	return recipients, nil
+}
+
+func editPlaintext(plain []byte, name string, edit func(path string) error) ([]byte, error) {
+	dir, cleanup, err := secrets.PrivateDir()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, plain, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write plaintext: %w", err)
+	}
+
+	if err := edit(path); err != nil {
+		return nil, err
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read edited plaintext: %w", err)
+	}
+
+	return edited, nil
+}
@@ -0,0 +1,205 @@
+package seed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kloudkit/ws-cli/internals/secrets"
+	"gotest.tools/v3/assert"
+)
+
+func editSecret(t *testing.T, opts EditOptions) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	opts.Out = &buffer
+	assert.NilError(t, EditSecret(opts))
+	return buffer.String()
+}
+
+func replaceWith(t *testing.T, content string, seen *string) func(string) error {
+	return func(path string) error {
+		info, err := os.Stat(path)
+		assert.NilError(t, err)
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+
+		if seen != nil {
+			*seen = path
+		}
+
+		return os.WriteFile(path, []byte(content), 0o600)
+	}
+}
+
+func TestEditSecret(t *testing.T) {
+	t.Run("ManifestSecretKeepsBinding", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  # rotated quarterly\n  API_TOKEN: %s\n",
+			encryptBound(t, "OLD", testMaster, "secrets.API_TOKEN"),
+		))
+
+		var plaintextPath string
+		output := editSecret(t, EditOptions{
+			Source:    source,
+			Target:    "secrets.API_TOKEN",
+			MasterKey: testMaster,
+			Edit:      replaceWith(t, "NEW\n", &plaintextPath),
+		})
+
+		assert.Assert(t, strings.Contains(output, `Updated secret "API_TOKEN"`))
+		assert.Assert(t, !fileExists(plaintextPath))
+		assert.Assert(t, !fileExists(filepath.Dir(plaintextPath)))
+		assert.Assert(t, strings.Contains(readFile(t, ManifestPath(source)), "# rotated quarterly"))
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		assert.Assert(t, secrets.IsBound(manifest.Secrets["API_TOKEN"]))
+
+		master, err := secrets.ResolveMasterKey(testMaster)
+		assert.NilError(t, err)
+		plain, err := secrets.DecryptContext(manifest.Secrets["API_TOKEN"], master, "secrets.API_TOKEN")
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "NEW")
+	})
+
+	t.Run("UnchangedWritesNothing", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "SAME", testMaster)))
+		before := readFile(t, ManifestPath(source))
+
+		output := editSecret(t, EditOptions{
+			Source:    source,
+			Target:    "secrets.TOK",
+			MasterKey: testMaster,
+			Edit:      replaceWith(t, "SAME\n", nil),
+		})
+
+		assert.Assert(t, strings.Contains(output, `No changes to secret "TOK"`))
+		assert.Equal(t, readFile(t, ManifestPath(source)), before)
+	})
+
+	t.Run("EncryptedFileGetsFreshSalt", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "id_key")
+		original := encrypt(t, "KEY-ONE\n", testMaster)
+		write(t, rhyming(source, dest), original)
+
+		editSecret(t, EditOptions{
+			Target:    rhyming(source, dest),
+			MasterKey: testMaster,
+			Edit:      replaceWith(t, "KEY-TWO\n", nil),
+		})
+
+		updated := readFile(t, rhyming(source, dest))
+		assert.Assert(t, strings.Split(updated, "$")[4] != strings.Split(original, "$")[4])
+		decrypts(t, updated, testMaster, "KEY-TWO\n")
+	})
+
+	t.Run("RecipientSeedReencryptedForListedRecipients", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice, bob, carol := newIdentity(t), newIdentity(t), newIdentity(t)
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "config.json")
+		write(t, rhyming(source, dest), encryptFor(t, "{}\n", alice, bob, carol))
+		writeManifest(t, source, fmt.Sprintf(
+			"recipients:\n  - %s\n  - %s\nseeds:\n  %s:\n    secret: true\n",
+			alice.Recipient(), bob.Recipient(), dest,
+		))
+
+		var plaintextPath string
+		editSecret(t, EditOptions{
+			Source:   source,
+			Target:   "seeds." + dest,
+			Identity: alice.String(),
+			Edit:     replaceWith(t, "{\"a\":1}\n", &plaintextPath),
+		})
+
+		assert.Equal(t, filepath.Base(plaintextPath), "config.json")
+
+		updated := readFile(t, rhyming(source, dest))
+		decryptsFor(t, updated, bob, "{\"a\":1}\n")
+
+		_, err := secrets.DecryptWith(secrets.NormalizeEncrypted(updated), carol)
+		assert.ErrorContains(t, err, "is not a recipient")
+	})
+
+	t.Run("RecipientFileNeedsListedRecipients", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice := newIdentity(t)
+		dest := filepath.Join(t.TempDir(), "token")
+		write(t, dest, encryptFor(t, "OLD", alice))
+		before := readFile(t, dest)
+
+		err := EditSecret(EditOptions{
+			Target:   dest,
+			Identity: alice.String(),
+			Edit:     replaceWith(t, "NEW", nil),
+			Out:      &bytes.Buffer{},
+		})
+
+		assert.ErrorContains(t, err, "configure the seed source that lists them")
+		assert.Equal(t, readFile(t, dest), before)
+	})
+
+	t.Run("ConcurrentChangeRefused", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "OLD", testMaster)))
+		other := fmt.Sprintf("version: v1\nsecrets:\n  TOK: %s\n", encrypt(t, "THEIRS", testMaster))
+
+		err := EditSecret(EditOptions{
+			Source:    source,
+			Target:    "secrets.TOK",
+			MasterKey: testMaster,
+			Edit: func(path string) error {
+				write(t, ManifestPath(source), other)
+				return os.WriteFile(path, []byte("MINE"), 0o600)
+			},
+			Out: &bytes.Buffer{},
+		})
+
+		assert.ErrorContains(t, err, "changed while it was being edited")
+		assert.Equal(t, readFile(t, ManifestPath(source)), other)
+	})
+
+	t.Run("EditorFailureWritesNothing", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "OLD", testMaster)))
+		before := readFile(t, ManifestPath(source))
+
+		err := EditSecret(EditOptions{
+			Source:    source,
+			Target:    "secrets.TOK",
+			MasterKey: testMaster,
+			Edit: func(path string) error {
+				_ = os.WriteFile(path, []byte("HALF"), 0o600)
+				return errors.New("editor crashed")
+			},
+			Out: &bytes.Buffer{},
+		})
+
+		assert.ErrorContains(t, err, "editor crashed")
+		assert.Equal(t, readFile(t, ManifestPath(source)), before)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "OLD", testMaster)))
+
+		err := EditSecret(EditOptions{Source: source, Target: "secrets.NOPE", MasterKey: testMaster, Out: &bytes.Buffer{}})
+
+		assert.ErrorContains(t, err, `no file, secret or secret seed matches "secrets.NOPE"`)
+	})
+}
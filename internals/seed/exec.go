@@ -0,0 +1,59 @@
+package seed
+
+import (
+	"fmt"
+	"maps"
+)
+
+type SecretsOptions struct {
+	Source    string
+	MasterKey string
+	Identity  string
+	Names     []string
+}
+
+func DecryptSecrets(opts SecretsOptions) (map[string][]byte, error) {
+	layers := Layers(opts.Source)
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no seed source configured (use --source)")
+	}
+
+	declared := map[string]string{}
+	for _, layer := range layers {
+		manifest, err := loadLayerManifest(layer)
+		if err != nil {
+			return nil, err
+		}
+
+		if manifest != nil {
+			maps.Copy(declared, manifest.Secrets)
+		}
+	}
+
+	keys := &keyResolver{flag: opts.MasterKey, identityFlag: opts.Identity, secrets: declared}
+	defer keys.zero()
+
+	decrypted := make(map[string][]byte, len(opts.Names))
+	for _, name := range opts.Names {
+		if _, ok := decrypted[name]; ok {
+			continue
+		}
+
+		var err error
+		if _, ok := declared[name]; !ok {
+			err = fmt.Errorf("secret %q is not declared in %s", name, ManifestName)
+		} else if decrypted[name], err = keys.resolveNamed(name); err != nil {
+			err = fmt.Errorf("secret %q: %w", name, err)
+		}
+
+		if err != nil {
+			for _, value := range decrypted {
+				zeroBytes(value)
+			}
+
+			return nil, err
+		}
+	}
+
+	return decrypted, nil
+}
@@ -0,0 +1,61 @@
+package seed
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDecryptSecrets(t *testing.T) {
+	t.Run("LayeredAndBound", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		base, overlay := t.TempDir(), t.TempDir()
+		keyFile := filepath.Join(t.TempDir(), "key.enc")
+		write(t, keyFile, encrypt(t, "FROM-FILE", testMaster))
+
+		writeManifest(t, base, fmt.Sprintf(
+			"secrets:\n  TOKEN: %s\n  KEY: file:%s\n",
+			encrypt(t, "BASE", testMaster), keyFile,
+		))
+		writeManifest(t, overlay, fmt.Sprintf(
+			"secrets:\n  TOKEN: %s\n",
+			encryptBound(t, "OVERLAY", testMaster, "secrets.TOKEN"),
+		))
+
+		decrypted, err := DecryptSecrets(SecretsOptions{
+			Source:    base + string(filepath.ListSeparator) + overlay,
+			MasterKey: testMaster,
+			Names:     []string{"TOKEN", "KEY", "TOKEN"},
+		})
+
+		assert.NilError(t, err)
+		assert.Equal(t, len(decrypted), 2)
+		assert.Equal(t, string(decrypted["TOKEN"]), "OVERLAY")
+		assert.Equal(t, string(decrypted["KEY"]), "FROM-FILE")
+	})
+
+	t.Run("UndeclaredFails", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOKEN: %s\n", encrypt(t, "V", testMaster)))
+
+		_, err := DecryptSecrets(SecretsOptions{Source: source, MasterKey: testMaster, Names: []string{"MISSING"}})
+
+		assert.ErrorContains(t, err, `secret "MISSING" is not declared in .seed.yaml`)
+	})
+
+	t.Run("SwappedBoundFails", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  API_TOKEN: %s\n",
+			encryptBound(t, "PASSWORD", testMaster, "secrets.DB_PASSWORD"),
+		))
+
+		_, err := DecryptSecrets(SecretsOptions{Source: source, MasterKey: testMaster, Names: []string{"API_TOKEN"}})
+
+		assert.ErrorContains(t, err, `secret "API_TOKEN"`)
+	})
+}
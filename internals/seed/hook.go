@@ -0,0 +1,213 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+type HookKind string
+
+const (
+	HookBefore   HookKind = "before"
+	HookOnChange HookKind = "onChange"
+)
+
+const (
+	DefaultHookTimeout = 2 * time.Minute
+	hookWaitDelay      = 2 * time.Second
+)
+
+type Hooks struct {
+	Before   string `yaml:"before"`
+	OnChange string `yaml:"onChange"`
+	Timeout  string `yaml:"hookTimeout"`
+}
+
+type hookRun struct {
+	command string
+	timeout time.Duration
+}
+
+func (h Hooks) empty() bool {
+	return strings.TrimSpace(h.Before) == "" && strings.TrimSpace(h.OnChange) == ""
+}
+
+func (h Hooks) validate() error {
+	if h.Timeout == "" {
+		return nil
+	}
+
+	if timeout, err := time.ParseDuration(h.Timeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid hookTimeout %q (expected a duration such as 30s)", h.Timeout)
+	}
+
+	return nil
+}
+
+func (h Hooks) timeout(fallback time.Duration) time.Duration {
+	if timeout, err := time.ParseDuration(h.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+
+	return fallback
+}
+
+func (h Hooks) command(kind HookKind) string {
+	if kind == HookBefore {
+		return strings.TrimSpace(h.Before)
+	}
+
+	return strings.TrimSpace(h.OnChange)
+}
+
+func (r reporter) hook(kind HookKind, dest, output string) {
+	message := fmt.Sprintf("Ran %s [%s]", kind, dest)
+
+	if r.report != nil {
+		r.report.update(func(entry *EntryReport) {
+			entry.Hooks = append(entry.Hooks, HookReport{Kind: kind, Output: output})
+		})
+		return
+	}
+
+	if r.styled {
+		styles.PrintKeyValue(r.out, "Hook", message)
+	} else {
+		fmt.Fprintf(r.out, "Hook %s\n", message)
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if r.styled {
+			fmt.Fprintln(r.out, styles.Muted().Render("  "+line))
+		} else {
+			fmt.Fprintf(r.out, "  %s\n", line)
+		}
+	}
+}
+
+func (r reporter) hookFailed(dest string, err error) {
+	message := fmt.Sprintf("Failed [%s] (%s)", dest, err)
+
+	if r.report != nil {
+		r.report.record(ResultFailed, err.Error())
+		return
+	}
+
+	if r.styled {
+		styles.PrintError(r.out, message)
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func (r reporter) hookPlanned(kind HookKind, command string) {
+	message := fmt.Sprintf("would run %s: %s", kind, command)
+
+	if r.report != nil {
+		r.report.update(func(entry *EntryReport) {
+			entry.Hooks = append(entry.Hooks, HookReport{Kind: kind, Command: command})
+		})
+		return
+	}
+
+	if r.styled {
+		fmt.Fprintln(r.out, styles.Muted().Render(message))
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func (op ResolvedOp) hooks(kind HookKind, fallback time.Duration) []hookRun {
+	var runs []hookRun
+	if op.stacks() {
+		runs = op.Base.hooks(kind, fallback)
+	}
+
+	if command := op.Hooks.command(kind); command != "" {
+		runs = append(runs, hookRun{command: command, timeout: op.Hooks.timeout(fallback)})
+	}
+
+	return runs
+}
+
+func (op ResolvedOp) hookCommands(kind HookKind) []string {
+	var commands []string
+	for _, run := range op.hooks(kind, 0) {
+		commands = append(commands, run.command)
+	}
+
+	return commands
+}
+
+func (op ResolvedOp) runHooks(kind HookKind, rep reporter) error {
+	fallback := op.HookTimeout
+	if fallback <= 0 {
+		fallback = DefaultHookTimeout
+	}
+
+	for _, run := range op.hooks(kind, fallback) {
+		if err := op.runHook(kind, run, rep); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (op ResolvedOp) runHook(kind HookKind, run hookRun, rep reporter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), run.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", run.command)
+	cmd.Dir = nearestExistingAncestor(op.Dest)
+	cmd.Env = append(os.Environ(), "WS_SEED_DEST="+op.Dest, "WS_SEED_HOOK="+string(kind))
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = hookWaitDelay
+
+	output, err := cmd.CombinedOutput()
+	rep.hook(kind, op.Dest, string(output))
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s hook timed out after %s", kind, run.timeout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %w", kind, err)
+	}
+
+	return nil
+}
+
+func (op ResolvedOp) planHooks(rep reporter) {
+	for _, kind := range []HookKind{HookBefore, HookOnChange} {
+		for _, command := range op.hookCommands(kind) {
+			rep.hookPlanned(kind, command)
+		}
+	}
+}
+
+func changes(dest string, content []byte, mode fs.FileMode) bool {
+	info, err := os.Stat(dest)
+	if err != nil {
+		return true
+	}
+
+	return info.Mode().Perm() != mode || !bytes.Equal(readExisting(dest), content)
+}
@@ -0,0 +1,146 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func hookManifest(dest, hooks string) string {
+	return fmt.Sprintf("seeds:\n  %s:\n    content: \"v1\\n\"\n%s", dest, hooks)
+}
+
+func TestHooks(t *testing.T) {
+	t.Run("RunOnlyWhenChanged", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "tmux.conf")
+		log := filepath.Join(target, "hooks.log")
+
+		writeManifest(t, source, hookManifest(dest, fmt.Sprintf(
+			"    force: true\n    before: echo before >> %[1]s\n    onChange: echo \"changed $WS_SEED_DEST\" >> %[1]s; echo reloaded\n",
+			log,
+		)))
+
+		output := apply(t, Options{Source: source})
+		assert.Assert(t, strings.Contains(output, "Hook Ran onChange ["+dest+"]\n  reloaded\n"))
+
+		got, err := os.ReadFile(log)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "before\nchanged "+dest+"\n")
+
+		output = apply(t, Options{Source: source})
+		assert.Assert(t, !strings.Contains(output, "Ran onChange"))
+
+		got, err = os.ReadFile(log)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "before\nchanged "+dest+"\n")
+	})
+
+	t.Run("BeforeFailureSkipsWrite", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, source, hookManifest(dest, "    before: echo refusing; exit 3\n"))
+
+		output := applyErr(t, Options{Source: source})
+
+		assert.Assert(t, !fileExists(dest))
+		assert.Assert(t, strings.Contains(output, "  refusing\n"))
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (before hook failed: exit status 3)"))
+	})
+
+	t.Run("HangingHookTimesOut", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, source, hookManifest(dest, "    hookTimeout: 200ms\n    before: echo waiting; sleep 30 & sleep 30\n"))
+
+		started := time.Now()
+		output := applyErr(t, Options{Source: source})
+
+		assert.Assert(t, time.Since(started) < 10*time.Second)
+		assert.Assert(t, !fileExists(dest))
+		assert.Assert(t, strings.Contains(output, "  waiting\n"))
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (before hook timed out after 200ms)"))
+	})
+
+	t.Run("InvalidTimeoutRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    content: x\n    hookTimeout: soon\n"))
+		assert.ErrorContains(t, err, `invalid hookTimeout "soon"`)
+	})
+
+	t.Run("OnChangeFailureCounted", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+		other := filepath.Join(target, "other.txt")
+
+		writeManifest(t, source, hookManifest(dest, "    onChange: \"false\"\n")+
+			fmt.Sprintf("  %s:\n    content: \"ok\\n\"\n", other))
+
+		var buffer strings.Builder
+		err := Apply(Options{Source: source, Out: &buffer})
+
+		assert.ErrorContains(t, err, "1 seed entry failed to apply")
+		assert.Assert(t, fileExists(dest))
+		assert.Assert(t, fileExists(other))
+		assert.Assert(t, strings.Contains(buffer.String(), "Failed ["+dest+"] (onChange hook failed: exit status 1)"))
+	})
+
+	t.Run("StackedLayersRunInOrder", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		org, team := t.TempDir(), t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, org, hookManifest(dest, "    onChange: echo org\n"))
+		writeManifest(t, team, fmt.Sprintf("seeds:\n  %s:\n    op: append\n    content: \"v2\\n\"\n    onChange: echo team\n", dest))
+
+		output := apply(t, Options{Source: layered(org, team)})
+
+		assert.Assert(t, strings.Contains(output, "  org\nHook Ran onChange ["+dest+"]\n  team\n"))
+	})
+
+	t.Run("DryRunDoesNotRun", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+		marker := filepath.Join(target, "ran")
+
+		writeManifest(t, source, hookManifest(dest, fmt.Sprintf("    onChange: touch %s\n", marker)))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+
+		assert.Assert(t, !fileExists(marker))
+		assert.Assert(t, strings.Contains(output, "would run onChange: touch "+marker))
+	})
+
+	t.Run("RetractRunsOnChange", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "stale.txt")
+		write(t, dest, "old\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    state: absent\n    force: true\n    onChange: echo gone\n", dest))
+
+		output := apply(t, Options{Source: source})
+		assert.Assert(t, strings.Contains(output, "  gone\n"))
+
+		output = apply(t, Options{Source: source})
+		assert.Assert(t, !strings.Contains(output, "gone"))
+	})
+}
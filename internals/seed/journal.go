@@ -0,0 +1,163 @@
+package seed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const journalsName = "rotate"
+
+type RotateJournal struct {
+	Source    string         `json:"source"`
+	StartedAt time.Time      `json:"startedAt"`
+	Entries   []JournalEntry `json:"entries"`
+}
+
+type JournalEntry struct {
+	Describe string `json:"describe"`
+	Manifest string `json:"manifest"`
+	Section  string `json:"section"`
+	Key      string `json:"key"`
+	Path     string `json:"path,omitempty"`
+	Cipher   string `json:"cipher"`
+}
+
+func JournalPath(source string) string {
+	sum := sha256.Sum256([]byte(source))
+
+	return filepath.Join(StateDir(), journalsName, hex.EncodeToString(sum[:8])+".json")
+}
+
+func saveJournal(source string, targets []rotateTarget) error {
+	journal := RotateJournal{Source: source, StartedAt: time.Now().UTC()}
+	for _, target := range targets {
+		journal.Entries = append(journal.Entries, JournalEntry{
+			Describe: target.describe,
+			Manifest: target.manifestPath,
+			Section:  target.section,
+			Key:      target.key,
+			Path:     target.writePath,
+			Cipher:   target.rotated,
+		})
+	}
+
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode rotation journal: %w", err)
+	}
+
+	path := JournalPath(source)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create rotation journal: %w", err)
+	}
+
+	if err := atomicReplace(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write rotation journal: %w", err)
+	}
+
+	return nil
+}
+
+func loadJournal(source string) (*RotateJournal, error) {
+	data, err := os.ReadFile(JournalPath(source))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rotation journal: %w", err)
+	}
+
+	var journal RotateJournal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("failed to parse rotation journal %q: %w", JournalPath(source), err)
+	}
+
+	if journal.Source != source {
+		return nil, fmt.Errorf("rotation journal %q belongs to %s", JournalPath(source), journal.Source)
+	}
+
+	return &journal, nil
+}
+
+func removeJournal(source string) error {
+	if err := os.Remove(JournalPath(source)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove rotation journal: %w", err)
+	}
+
+	return nil
+}
+
+func resumeRotate(opts RotateOptions, rep rotateReporter) error {
+	unlock, err := acquireLock(reporter{out: opts.Out, styled: opts.Styled})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	journal, err := loadJournal(opts.Source)
+	if err != nil {
+		return err
+	}
+
+	if journal == nil {
+		return fmt.Errorf("no interrupted rotation of %s to resume", opts.Source)
+	}
+
+	docs := map[string]*yaml.Node{}
+	manifests := map[string]bool{}
+
+	var targets []rotateTarget
+	for _, entry := range journal.Entries {
+		target := rotateTarget{
+			describe:     entry.Describe,
+			section:      entry.Section,
+			key:          entry.Key,
+			rotated:      entry.Cipher,
+			writePath:    entry.Path,
+			manifestPath: entry.Manifest,
+		}
+
+		if entry.Path != "" {
+			target.writeBack = fileWriter(entry.Path)
+			targets = append(targets, target)
+			continue
+		}
+
+		doc, ok := docs[entry.Manifest]
+		if !ok {
+			raw, err := os.ReadFile(entry.Manifest)
+			if err != nil {
+				return fmt.Errorf("failed to read manifest %q: %w", entry.Manifest, err)
+			}
+
+			doc = &yaml.Node{}
+			if err := yaml.Unmarshal(raw, doc); err != nil {
+				return fmt.Errorf("failed to parse manifest: %w", err)
+			}
+
+			docs[entry.Manifest], manifests[entry.Manifest] = doc, true
+		}
+
+		switch entry.Section {
+		case "secrets":
+			target.writeBack = nodeSetter(documentRoot(doc), "secrets", entry.Key)
+		case "seeds":
+			target.writeBack = seedContentSetter(documentRoot(doc), entry.Key)
+		default:
+			return fmt.Errorf("rotation journal has an unknown section %q", entry.Section)
+		}
+
+		targets = append(targets, target)
+	}
+
+	return finishRotate(opts.Source, targets, docs, manifests, rep)
+}
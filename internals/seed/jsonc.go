@@ -0,0 +1,546 @@
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type jsonNode struct {
+	start   int
+	end     int
+	kind    byte
+	members []jsonMember
+	items   []*jsonNode
+}
+
+type jsonMember struct {
+	key      string
+	keyStart int
+	value    *jsonNode
+}
+
+type jsonScanner struct {
+	data []byte
+	pos  int
+}
+
+func blankComments(data []byte) []byte {
+	out := slices.Clone(data)
+
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+
+	return out
+}
+
+func standardizeJSONC(data []byte) []byte {
+	out := blankComments(data)
+
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case ',':
+			j := i + 1
+			for j < len(out) && isJSONSpace(out[j]) {
+				j++
+			}
+
+			if j < len(out) && (out[j] == '}' || out[j] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+
+	return out
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func parseJSONC(data []byte) (*jsonNode, error) {
+	s := &jsonScanner{data: blankComments(data)}
+
+	root, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if root.kind != '{' {
+		return nil, fmt.Errorf("%w: top level is not an object", errUnpreservable)
+	}
+
+	return root, nil
+}
+
+func (s *jsonScanner) skip() {
+	for s.pos < len(s.data) && isJSONSpace(s.data[s.pos]) {
+		s.pos++
+	}
+}
+
+func (s *jsonScanner) value() (*jsonNode, error) {
+	s.skip()
+	if s.pos >= len(s.data) {
+		return nil, fmt.Errorf("%w: unexpected end of document", errUnpreservable)
+	}
+
+	node := &jsonNode{start: s.pos, kind: s.data[s.pos]}
+
+	switch node.kind {
+	case '{':
+		s.pos++
+		for {
+			s.skip()
+			if s.pos < len(s.data) && s.data[s.pos] == '}' {
+				break
+			}
+
+			keyStart := s.pos
+			if err := s.str(); err != nil {
+				return nil, err
+			}
+
+			var key string
+			if err := json.Unmarshal(s.data[keyStart:s.pos], &key); err != nil {
+				return nil, fmt.Errorf("%w: %v", errUnpreservable, err)
+			}
+
+			s.skip()
+			if s.pos >= len(s.data) || s.data[s.pos] != ':' {
+				return nil, fmt.Errorf("%w: expected ':' at offset %d", errUnpreservable, s.pos)
+			}
+			s.pos++
+
+			value, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+
+			node.members = append(node.members, jsonMember{key: key, keyStart: keyStart, value: value})
+
+			if !s.separator() {
+				break
+			}
+		}
+
+		if err := s.close('}'); err != nil {
+			return nil, err
+		}
+	case '[':
+		s.pos++
+		for {
+			s.skip()
+			if s.pos < len(s.data) && s.data[s.pos] == ']' {
+				break
+			}
+
+			item, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+
+			node.items = append(node.items, item)
+
+			if !s.separator() {
+				break
+			}
+		}
+
+		if err := s.close(']'); err != nil {
+			return nil, err
+		}
+	case '"':
+		node.kind = 0
+		if err := s.str(); err != nil {
+			return nil, err
+		}
+	default:
+		node.kind = 0
+		for s.pos < len(s.data) && !isJSONSpace(s.data[s.pos]) && !strings.ContainsRune(",]}", rune(s.data[s.pos])) {
+			s.pos++
+		}
+
+		if s.pos == node.start {
+			return nil, fmt.Errorf("%w: unexpected %q at offset %d", errUnpreservable, s.data[s.pos], s.pos)
+		}
+	}
+
+	node.end = s.pos
+
+	return node, nil
+}
+
+func (s *jsonScanner) str() error {
+	if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+		return fmt.Errorf("%w: expected string at offset %d", errUnpreservable, s.pos)
+	}
+
+	for s.pos++; s.pos < len(s.data); s.pos++ {
+		switch s.data[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: unterminated string", errUnpreservable)
+}
+
+func (s *jsonScanner) separator() bool {
+	s.skip()
+	if s.pos < len(s.data) && s.data[s.pos] == ',' {
+		s.pos++
+		return true
+	}
+
+	return false
+}
+
+func (s *jsonScanner) close(closing byte) error {
+	s.skip()
+	if s.pos >= len(s.data) || s.data[s.pos] != closing {
+		return fmt.Errorf("%w: expected %q at offset %d", errUnpreservable, closing, s.pos)
+	}
+
+	s.pos++
+
+	return nil
+}
+
+type jsonPatcher struct {
+	data []byte
+}
+
+type jsonEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+func (j *jsonPatcher) apply(edits ...jsonEdit) {
+	slices.SortStableFunc(edits, func(a, b jsonEdit) int { return b.start - a.start })
+
+	for _, edit := range edits {
+		j.data = slices.Concat(j.data[:edit.start], []byte(edit.text), j.data[edit.end:])
+	}
+}
+
+func (j *jsonPatcher) lookup(path []string) (*jsonNode, int, error) {
+	node, err := parseJSONC(j.data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i, key := range path {
+		if node.kind != '{' {
+			return nil, 0, fmt.Errorf("%w: %s is not an object", errUnpreservable, describePath(path[:i]))
+		}
+
+		index := slices.IndexFunc(node.members, func(m jsonMember) bool { return m.key == key })
+		if i == len(path)-1 {
+			return node, index, nil
+		}
+
+		if index < 0 {
+			return nil, 0, fmt.Errorf("%w: %s not found", errUnpreservable, describePath(path[:i+1]))
+		}
+
+		node = node.members[index].value
+	}
+
+	return node, -1, nil
+}
+
+func (j *jsonPatcher) set(path []string, value any) error {
+	parent, index, err := j.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	if parent.kind != '{' {
+		return fmt.Errorf("%w: %s is not an object", errUnpreservable, describePath(path[:len(path)-1]))
+	}
+
+	if index >= 0 {
+		member := parent.members[index]
+		rendered, err := j.render(value, parent, lineIndent(j.data, member.keyStart))
+		if err != nil {
+			return err
+		}
+
+		j.apply(jsonEdit{member.value.start, member.value.end, rendered})
+		return nil
+	}
+
+	key, err := json.Marshal(path[len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	starts := make([]int, len(parent.members))
+	ends := make([]int, len(parent.members))
+	for i, member := range parent.members {
+		starts[i], ends[i] = member.keyStart, member.value.end
+	}
+
+	return j.insert(parent, starts, ends, func(indent string) (string, error) {
+		rendered, err := j.render(value, parent, indent)
+		return string(key) + ": " + rendered, err
+	})
+}
+
+func (j *jsonPatcher) extend(path []string, items []any) error {
+	parent, index, err := j.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || parent.members[index].value.kind != '[' {
+		return fmt.Errorf("%w: %s is not an array", errUnpreservable, describePath(path))
+	}
+
+	array := parent.members[index].value
+	if len(array.items) == 0 {
+		return fmt.Errorf("%w: %s is empty", errUnpreservable, describePath(path))
+	}
+
+	starts := make([]int, len(array.items))
+	ends := make([]int, len(array.items))
+	for i, item := range array.items {
+		starts[i], ends[i] = item.start, item.end
+	}
+
+	for _, item := range items {
+		err := j.insert(array, starts, ends, func(indent string) (string, error) {
+			return j.render(item, array, indent)
+		})
+		if err != nil {
+			return err
+		}
+
+		parent, index, err = j.lookup(path)
+		if err != nil {
+			return err
+		}
+
+		array = parent.members[index].value
+		starts = starts[:0]
+		ends = ends[:0]
+		for _, item := range array.items {
+			starts = append(starts, item.start)
+			ends = append(ends, item.end)
+		}
+	}
+
+	return nil
+}
+
+func (j *jsonPatcher) insert(container *jsonNode, starts, ends []int, entry func(indent string) (string, error)) error {
+	multiline := bytes.IndexByte(j.data[container.start:container.end], '\n') >= 0
+
+	if len(starts) == 0 {
+		indent := lineIndent(j.data, container.start)
+		text, err := entry(indent + indentUnit(j.data))
+		if err != nil {
+			return err
+		}
+
+		j.apply(jsonEdit{container.start + 1, container.end - 1, "\n" + indent + indentUnit(j.data) + text + "\n" + indent})
+		return nil
+	}
+
+	last := len(starts) - 1
+	after, trailing := j.afterComma(ends[last])
+
+	if !multiline {
+		text, err := entry("")
+		if err != nil {
+			return err
+		}
+
+		if trailing {
+			j.apply(jsonEdit{after, after, " " + text + ","})
+		} else {
+			j.apply(jsonEdit{ends[last], ends[last], ", " + text})
+		}
+
+		return nil
+	}
+
+	indent := lineIndent(j.data, starts[last])
+	text, err := entry(indent)
+	if err != nil {
+		return err
+	}
+
+	eol := after
+	for eol < container.end-1 && j.data[eol] != '\n' {
+		eol++
+	}
+
+	if trailing {
+		j.apply(jsonEdit{eol, eol, "\n" + indent + text + ","})
+		return nil
+	}
+
+	j.apply(jsonEdit{eol, eol, "\n" + indent + text}, jsonEdit{ends[last], ends[last], ","})
+
+	return nil
+}
+
+func (j *jsonPatcher) remove(path []string) error {
+	parent, index, err := j.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 {
+		return fmt.Errorf("%w: %s not found", errUnpreservable, describePath(path))
+	}
+
+	members := parent.members
+	member := members[index]
+	isLast := index == len(members)-1
+	after, trailing := j.afterComma(member.value.end)
+
+	if bytes.IndexByte(j.data[parent.start:parent.end], '\n') < 0 {
+		switch {
+		case !isLast:
+			j.apply(jsonEdit{member.keyStart, members[index+1].keyStart, ""})
+		case index > 0:
+			comma, _ := j.afterComma(members[index-1].value.end)
+			j.apply(jsonEdit{comma - 1, member.value.end, ""})
+		default:
+			j.apply(jsonEdit{member.keyStart, after, ""})
+		}
+
+		return nil
+	}
+
+	start := member.keyStart
+	if lineStart := start - len(lineIndent(j.data, start)); lineStart == 0 || j.data[lineStart-1] == '\n' {
+		start = lineStart
+	}
+
+	end := after
+	blank := blankComments(j.data)
+	for end < len(blank) && (blank[end] == ' ' || blank[end] == '\t' || blank[end] == '\r') {
+		end++
+	}
+
+	if end < len(blank) && blank[end] == '\n' && start != member.keyStart {
+		end++
+	} else {
+		end = after
+	}
+
+	edits := []jsonEdit{{start, end, ""}}
+	if isLast && !trailing && index > 0 {
+		comma, _ := j.afterComma(members[index-1].value.end)
+		edits = append(edits, jsonEdit{comma - 1, comma, ""})
+	}
+
+	j.apply(edits...)
+
+	return nil
+}
+
+func (j *jsonPatcher) afterComma(pos int) (int, bool) {
+	blank := blankComments(j.data)
+
+	i := pos
+	for i < len(blank) && isJSONSpace(blank[i]) {
+		i++
+	}
+
+	if i < len(blank) && blank[i] == ',' {
+		return i + 1, true
+	}
+
+	return pos, false
+}
+
+func (j *jsonPatcher) render(value any, container *jsonNode, indent string) (string, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+
+	if bytes.IndexByte(j.data[container.start:container.end], '\n') >= 0 {
+		encoder.SetIndent(indent, indentUnit(j.data))
+	}
+
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func (j *jsonPatcher) bytes() ([]byte, error) {
+	return j.data, nil
+}
+
+func lineIndent(data []byte, pos int) string {
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+
+	return string(data[start:end])
+}
+
+func indentUnit(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 || len(trimmed) == len(line) {
+			continue
+		}
+
+		if line[0] == '\t' {
+			return "\t"
+		}
+
+		return string(line[:len(line)-len(trimmed)])
+	}
+
+	return "  "
+}
@@ -0,0 +1,628 @@
+package seed
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type kvDialect struct {
+	sections     bool
+	fold         bool
+	multi        bool
+	continuation bool
+	comments     string
+	lead         string
+	sep          string
+	entry        func(line string) (lead, key, sep, value string, ok bool)
+	quote        func(value string) string
+}
+
+type kvLine struct {
+	section string
+	key     string
+	header  bool
+	lead    string
+	sep     string
+	start   int
+	end     int
+}
+
+var (
+	propertiesContinuation = regexp.MustCompile(`\\\r?\n[ \t]*`)
+	dotenvBare             = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+	iniDialect = kvDialect{
+		sections: true,
+		comments: "#;",
+		sep:      " = ",
+		entry:    splitINI,
+		quote:    func(value string) string { return value },
+	}
+
+	gitConfigDialect = kvDialect{
+		sections: true,
+		fold:     true,
+		multi:    true,
+		comments: "#;",
+		lead:     "\t",
+		sep:      " = ",
+		entry:    splitGitConfig,
+		quote:    quoteGitConfig,
+	}
+
+	propertiesDialect = kvDialect{
+		continuation: true,
+		comments:     "#!",
+		sep:          "=",
+		entry:        splitProperties,
+		quote:        quoteProperties,
+	}
+
+	dotenvDialect = kvDialect{
+		comments: "#",
+		sep:      "=",
+		entry:    splitDotenv,
+		quote:    quoteDotenv,
+	}
+)
+
+func (d kvDialect) unmarshal(data []byte, v any) error {
+	target, ok := v.(*map[string]any)
+	if !ok {
+		return fmt.Errorf("unsupported target %T", v)
+	}
+
+	values, _, err := d.parse(data)
+	if err != nil {
+		return err
+	}
+
+	*target = values
+
+	return nil
+}
+
+func (d kvDialect) marshal(v any) ([]byte, error) {
+	values, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value %T", v)
+	}
+
+	p := &kvPatcher{dialect: d}
+	if err := patchChanges(p, map[string]any{}, values, nil); err != nil {
+		return nil, err
+	}
+
+	return p.bytes()
+}
+
+func (d kvDialect) parse(data []byte) (map[string]any, []kvLine, error) {
+	values := map[string]any{}
+	target := values
+	section := ""
+
+	var lines []kvLine
+	for offset, number := 0, 1; offset < len(data); number++ {
+		end := lineEnd(data, offset)
+		for d.continuation && end < len(data) && continues(string(data[offset:end])) {
+			end = lineEnd(data, end)
+		}
+
+		line := strings.TrimRight(string(data[offset:end]), "\r\n")
+		text := strings.TrimSpace(line)
+
+		switch {
+		case text == "" || strings.ContainsRune(d.comments, rune(text[0])):
+			lines = append(lines, kvLine{section: section, start: offset, end: end})
+		case d.sections && text[0] == '[':
+			closing := strings.IndexByte(text, ']')
+			if closing < 0 {
+				return nil, nil, fmt.Errorf("line %d: unterminated section header", number)
+			}
+
+			section = d.sectionName(text[1:closing])
+
+			nested, ok := values[section].(map[string]any)
+			if !ok {
+				nested = map[string]any{}
+				values[section] = nested
+			}
+			target = nested
+
+			lines = append(lines, kvLine{section: section, header: true, start: offset, end: end})
+		default:
+			lead, key, sep, value, ok := d.entry(line)
+			if !ok {
+				return nil, nil, fmt.Errorf("line %d: expected key and value", number)
+			}
+
+			if d.fold {
+				key = strings.ToLower(key)
+			}
+
+			switch existing := target[key].(type) {
+			case nil:
+				target[key] = value
+				if d.multi {
+					target[key] = []any{value}
+				}
+			case []any:
+				target[key] = append(existing, value)
+			default:
+				if d.sections {
+					target[key] = []any{existing, value}
+				} else {
+					target[key] = value
+				}
+			}
+
+			lines = append(lines, kvLine{section: section, key: key, lead: lead, sep: sep, start: offset, end: end})
+		}
+
+		offset = end
+	}
+
+	return values, lines, nil
+}
+
+func (d kvDialect) sectionName(raw string) string {
+	name := strings.TrimSpace(raw)
+	if !d.fold {
+		return name
+	}
+
+	head, sub, found := strings.Cut(name, " ")
+	if !found {
+		return strings.ToLower(name)
+	}
+
+	return strings.ToLower(head) + " " + strings.TrimSpace(sub)
+}
+
+func continues(line string) bool {
+	trimmed := strings.TrimRight(line, "\r\n")
+	return (len(trimmed)-len(strings.TrimRight(trimmed, `\`)))%2 == 1
+}
+
+func splitINI(line string) (string, string, string, string, bool) {
+	index := strings.IndexAny(line, "=:")
+	if index < 0 {
+		key := strings.TrimSpace(line)
+		return leadOf(line), key, "", "", key != ""
+	}
+
+	key := strings.TrimSpace(line[:index])
+	value := strings.TrimSpace(line[index+1:])
+	lead := leadOf(line)
+
+	return lead, key, line[len(lead)+len(key) : len(line)-len(strings.TrimLeft(line[index+1:], " \t"))], value, key != ""
+}
+
+func splitGitConfig(line string) (string, string, string, string, bool) {
+	lead := leadOf(line)
+	rest := line[len(lead):]
+
+	index := strings.IndexByte(rest, '=')
+	if index < 0 {
+		key := strings.TrimSpace(stripComment(rest))
+		return lead, key, "", "true", key != ""
+	}
+
+	key := strings.TrimSpace(rest[:index])
+	raw := rest[index+1:]
+	sep := rest[len(key) : len(rest)-len(strings.TrimLeft(raw, " \t"))]
+
+	return lead, key, sep, unquoteGitConfig(strings.TrimLeft(raw, " \t")), key != ""
+}
+
+func splitProperties(line string) (string, string, string, string, bool) {
+	lead := leadOf(line)
+	rest := line[len(lead):]
+
+	end := 0
+	for end < len(rest) && !strings.ContainsRune("=: \t", rune(rest[end])) {
+		if rest[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	end = min(end, len(rest))
+
+	after := strings.TrimLeft(rest[end:], " \t")
+	if after != "" && (after[0] == '=' || after[0] == ':') {
+		after = strings.TrimLeft(after[1:], " \t")
+	}
+
+	sep := rest[end : len(rest)-len(after)]
+	value := propertiesContinuation.ReplaceAllString(after, "")
+
+	return lead, unescapeProperties(rest[:end]), sep, unescapeProperties(value), end > 0
+}
+
+func splitDotenv(line string) (string, string, string, string, bool) {
+	lead := leadOf(line)
+	if strings.HasPrefix(line[len(lead):], "export ") {
+		lead += "export "
+	}
+
+	rest := line[len(lead):]
+	index := strings.IndexByte(rest, '=')
+	if index <= 0 {
+		return "", "", "", "", false
+	}
+
+	key := strings.TrimSpace(rest[:index])
+	raw := strings.TrimLeft(rest[index+1:], " \t")
+	sep := rest[len(key) : len(rest)-len(raw)]
+
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		if closing := strings.IndexByte(raw[1:], '\''); closing >= 0 {
+			return lead, key, sep, raw[1 : closing+1], true
+		}
+	case strings.HasPrefix(raw, `"`):
+		value, err := strconv.QuotedPrefix(raw)
+		if err == nil {
+			unquoted, err := strconv.Unquote(value)
+			return lead, key, sep, unquoted, err == nil
+		}
+	}
+
+	if index := strings.Index(raw, " #"); index >= 0 {
+		raw = raw[:index]
+	}
+
+	return lead, key, sep, strings.TrimSpace(raw), true
+}
+
+func leadOf(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+func stripComment(value string) string {
+	if index := strings.IndexAny(value, "#;"); index >= 0 {
+		return value[:index]
+	}
+
+	return value
+}
+
+func unquoteGitConfig(raw string) string {
+	var builder strings.Builder
+	quoted := false
+
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				builder.WriteByte(raw[i])
+			}
+		case !quoted && (c == '#' || c == ';'):
+			return strings.TrimRight(builder.String(), " \t")
+		default:
+			builder.WriteByte(c)
+		}
+	}
+
+	return strings.TrimRight(builder.String(), " \t")
+}
+
+func quoteGitConfig(value string) string {
+	if value == strings.TrimSpace(value) && !strings.ContainsAny(value, "#;\"\\\n\t") {
+		return value
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func unescapeProperties(raw string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' || i+1 >= len(raw) {
+			builder.WriteByte(raw[i])
+			continue
+		}
+
+		i++
+		switch raw[i] {
+		case 'n':
+			builder.WriteByte('\n')
+		case 't':
+			builder.WriteByte('\t')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'u':
+			if i+4 < len(raw) {
+				if code, err := strconv.ParseUint(raw[i+1:i+5], 16, 32); err == nil {
+					builder.WriteRune(rune(code))
+					i += 4
+					continue
+				}
+			}
+			builder.WriteByte('u')
+		default:
+			builder.WriteByte(raw[i])
+		}
+	}
+
+	return builder.String()
+}
+
+func quoteProperties(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	escaped := replacer.Replace(value)
+
+	if strings.HasPrefix(escaped, " ") {
+		escaped = `\` + escaped
+	}
+
+	return escaped
+}
+
+func quoteDotenv(value string) string {
+	if dotenvBare.MatchString(value) {
+		return value
+	}
+
+	if !strings.ContainsAny(value, "'\n") {
+		return "'" + value + "'"
+	}
+
+	return strconv.Quote(value)
+}
+
+type kvPatcher struct {
+	dialect kvDialect
+	data    []byte
+}
+
+func (k *kvPatcher) scope(path []string) (string, string, error) {
+	switch {
+	case len(path) == 1:
+		return "", path[0], nil
+	case len(path) == 2 && k.dialect.sections:
+		return path[0], path[1], nil
+	}
+
+	return "", "", fmt.Errorf("%w: %s is nested too deeply", errUnpreservable, describePath(path))
+}
+
+func (k *kvPatcher) splice(start, end int, text string) {
+	k.data = slices.Concat(k.data[:start], []byte(text), k.data[end:])
+}
+
+func (k *kvPatcher) style(section string, lines []kvLine) (string, string) {
+	lead, sep := k.dialect.lead, k.dialect.sep
+	if section == "" {
+		lead = ""
+	}
+
+	for _, line := range lines {
+		if line.key != "" && line.section == section && line.sep != "" {
+			lead, sep = line.lead, line.sep
+		}
+	}
+
+	return lead, sep
+}
+
+func (k *kvPatcher) render(lead, key, sep string, values []any) (string, error) {
+	var builder strings.Builder
+	for _, value := range values {
+		text, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("%w: %s is not a string", errUnpreservable, key)
+		}
+
+		builder.WriteString(lead + key + sep + k.dialect.quote(text) + "\n")
+	}
+
+	return builder.String(), nil
+}
+
+func (k *kvPatcher) set(path []string, value any) error {
+	_, lines, err := k.dialect.parse(k.data)
+	if err != nil {
+		return err
+	}
+
+	if table, ok := value.(map[string]any); ok && len(path) == 1 && k.dialect.sections {
+		return k.appendSection(path[0], table, lines)
+	}
+
+	section, key, err := k.scope(path)
+	if err != nil {
+		return err
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+
+	var matches []kvLine
+	for _, line := range lines {
+		if line.key == key && line.section == section {
+			matches = append(matches, line)
+		}
+	}
+
+	lead, sep := k.style(section, lines)
+	if len(matches) > 0 && matches[0].sep != "" {
+		lead, sep = matches[0].lead, matches[0].sep
+	}
+
+	rendered, err := k.render(lead, key, sep, values)
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		return k.insert(section, rendered, lines)
+	}
+
+	for i := len(matches) - 1; i > 0; i-- {
+		k.splice(matches[i].start, matches[i].end, "")
+	}
+
+	k.splice(matches[0].start, matches[0].end, rendered)
+
+	return nil
+}
+
+func (k *kvPatcher) insert(section, rendered string, lines []kvLine) error {
+	at, found := -1, section == ""
+	if found {
+		at = 0
+	}
+
+	for _, line := range lines {
+		if line.section != section || (!line.header && line.key == "") {
+			continue
+		}
+
+		found = true
+		at = line.end
+	}
+
+	if !found {
+		return k.appendSection(section, map[string]any{}, lines, rendered)
+	}
+
+	if at > 0 && k.data[at-1] != '\n' {
+		rendered = "\n" + rendered
+	}
+
+	k.splice(at, at, rendered)
+
+	return nil
+}
+
+func (k *kvPatcher) appendSection(section string, table map[string]any, lines []kvLine, extra ...string) error {
+	var builder strings.Builder
+	builder.WriteString("[" + section + "]\n")
+
+	for _, key := range sortedKeys(table) {
+		values, ok := table[key].([]any)
+		if !ok {
+			values = []any{table[key]}
+		}
+
+		lead, sep := k.style(section, lines)
+
+		rendered, err := k.render(lead, key, sep, values)
+		if err != nil {
+			return err
+		}
+
+		builder.WriteString(rendered)
+	}
+
+	for _, text := range extra {
+		builder.WriteString(text)
+	}
+
+	switch {
+	case len(k.data) == 0:
+	case k.data[len(k.data)-1] != '\n':
+		k.data = append(k.data, "\n\n"...)
+	default:
+		k.data = append(k.data, '\n')
+	}
+
+	k.data = append(k.data, builder.String()...)
+
+	return nil
+}
+
+func (k *kvPatcher) extend(path []string, items []any) error {
+	_, lines, err := k.dialect.parse(k.data)
+	if err != nil {
+		return err
+	}
+
+	section, key, err := k.scope(path)
+	if err != nil {
+		return err
+	}
+
+	last := -1
+	for i, line := range lines {
+		if line.key == key && line.section == section {
+			last = i
+		}
+	}
+
+	if last < 0 {
+		return fmt.Errorf("%w: %s not found", errUnpreservable, describePath(path))
+	}
+
+	rendered, err := k.render(lines[last].lead, key, lines[last].sep, items)
+	if err != nil {
+		return err
+	}
+
+	at := lines[last].end
+	if at > 0 && k.data[at-1] != '\n' {
+		rendered = "\n" + rendered
+	}
+
+	k.splice(at, at, rendered)
+
+	return nil
+}
+
+func (k *kvPatcher) remove(path []string) error {
+	_, lines, err := k.dialect.parse(k.data)
+	if err != nil {
+		return err
+	}
+
+	section, key, err := k.scope(path)
+	if err != nil {
+		return err
+	}
+
+	wholeSection := len(path) == 1 && k.dialect.sections &&
+		slices.ContainsFunc(lines, func(line kvLine) bool { return line.header && line.section == key })
+
+	removed := false
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+
+		if wholeSection && line.section != key {
+			continue
+		}
+
+		if !wholeSection && (line.key != key || line.section != section) {
+			continue
+		}
+
+		k.splice(line.start, line.end, "")
+		removed = true
+	}
+
+	if !removed {
+		return fmt.Errorf("%w: %s not found", errUnpreservable, describePath(path))
+	}
+
+	return nil
+}
+
+func (k *kvPatcher) bytes() ([]byte, error) {
+	return k.data, nil
+}
@@ -0,0 +1,52 @@
+package seed
+
+import (
+	"path/filepath"
+	"slices"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+)
+
+func Layers(source string) []string {
+	var layers []string
+	for _, layer := range filepath.SplitList(source) {
+		if layer != "" {
+			layers = append(layers, layer)
+		}
+	}
+
+	return layers
+}
+
+func loadLayerManifest(layer string) (*Manifest, error) {
+	manifestPath := ManifestPath(layer)
+	if !internalIO.FileExists(manifestPath) {
+		return nil, nil
+	}
+
+	return LoadManifest(manifestPath)
+}
+
+func (op ResolvedOp) stacks() bool {
+	return op.Base != nil && op.Op.inPlace() && op.State != PresenceAbsent &&
+		op.Base.State != PresenceAbsent && !op.Base.Op.node()
+}
+
+func (op ResolvedOp) Layers() []string {
+	if !op.stacks() {
+		return []string{op.Layer}
+	}
+
+	return append(op.Base.Layers(), op.Layer)
+}
+
+func (op ResolvedOp) Overrides() []string {
+	switch {
+	case op.Base == nil:
+		return nil
+	case op.stacks():
+		return op.Base.Overrides()
+	}
+
+	return slices.Concat(op.Base.Overrides(), op.Base.Layers())
+}
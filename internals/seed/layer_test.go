@@ -0,0 +1,130 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func layered(sources ...string) string {
+	return strings.Join(sources, string(filepath.ListSeparator))
+}
+
+func TestLayers(t *testing.T) {
+	t.Run("LaterLayerReplaces", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		org, team := t.TempDir(), t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "file.txt")
+
+		write(t, rhyming(org, dest), "org\n")
+		write(t, rhyming(team, dest), "team\n")
+		write(t, rhyming(org, filepath.Join(target, "base.txt")), "base\n")
+
+		plan, err := BuildPlan(layered(org, team), false)
+		assert.NilError(t, err)
+		assert.Equal(t, len(plan.Ops), 2)
+
+		op := plan.Ops[1]
+		assert.Equal(t, op.Dest, dest)
+		assert.DeepEqual(t, op.Layers(), []string{team})
+		assert.DeepEqual(t, op.Overrides(), []string{org})
+		assert.DeepEqual(t, plan.Ops[0].Layers(), []string{org})
+
+		apply(t, Options{Source: plan.Source})
+
+		got, err := os.ReadFile(dest)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "team\n")
+	})
+
+	t.Run("MergesOntoLowerLayer", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		org, team, personal := t.TempDir(), t.TempDir(), t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "settings.json")
+
+		write(t, rhyming(org, dest), "{\"a\": 1, \"b\": 1}\n")
+		writeManifest(t, team, fmt.Sprintf("seeds:\n  %s:\n    op: merge\n    content: '{\"b\": 2}'\n", dest))
+		writeManifest(t, personal, fmt.Sprintf("seeds:\n  %s:\n    op: merge\n    content: '{\"c\": 3}'\n", dest))
+
+		plan, err := BuildPlan(layered(org, team, personal), false)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, plan.Ops[0].Layers(), []string{org, team, personal})
+		assert.Equal(t, len(plan.Ops[0].Overrides()), 0)
+
+		apply(t, Options{Source: plan.Source})
+
+		got, err := os.ReadFile(dest)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "{\"a\": 1, \"b\": 2, \"c\": 3}\n")
+	})
+
+	t.Run("KeepsExistingUnderLowerCopy", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		org, team := t.TempDir(), t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, ".bashrc")
+
+		write(t, dest, "mine\n")
+		write(t, rhyming(org, dest), "org\n")
+		writeManifest(t, team, fmt.Sprintf("seeds:\n  %s:\n    op: lineinfile\n    content: \"team\"\n", dest))
+
+		apply(t, Options{Source: layered(org, team)})
+
+		got, err := os.ReadFile(dest)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "mine\nteam\n")
+	})
+
+	t.Run("SecretsDeclaredInLowerLayer", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		org, team := t.TempDir(), t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "token.txt")
+
+		writeManifest(t, org, fmt.Sprintf("secrets:\n  TOKEN: %q\n", encrypt(t, "s3cr3t", testMaster)))
+		writeManifest(t, team, fmt.Sprintf("seeds:\n  %s:\n    template: true\n    content: \"${secrets.TOKEN}\"\n", dest))
+
+		apply(t, Options{Source: layered(org, team), MasterKey: testMaster})
+
+		got, err := os.ReadFile(dest)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "s3cr3t")
+		assert.Equal(t, mode(t, dest), os.FileMode(0o600))
+	})
+
+	t.Run("UnselectedLayerFallsThrough", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		org, team := t.TempDir(), t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "file.txt")
+
+		write(t, rhyming(org, dest), "org\n")
+		writeManifest(t, team, fmt.Sprintf("seeds:\n  %s:\n    profiles: [backend]\n    content: \"team\\n\"\n", dest))
+
+		plan, err := BuildPlan(layered(org, team), false)
+		assert.NilError(t, err)
+		assert.NilError(t, plan.Select(nil))
+		assert.Equal(t, len(plan.Ops), 1)
+		assert.Equal(t, plan.Ops[0].Layer, org)
+
+		plan, err = BuildPlan(layered(org, team), false)
+		assert.NilError(t, err)
+		assert.NilError(t, plan.Select([]string{"backend"}))
+		assert.Equal(t, plan.Ops[0].Layer, team)
+	})
+
+	t.Run("ResolveSourceExpandsEachLayer", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+
+		resolved, err := ResolveSource(layered("/mnt/org", "~/seed"))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, Layers(resolved), []string{"/mnt/org", filepath.Join(home, "seed")})
+	})
+}
@@ -0,0 +1,265 @@
+package seed
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kloudkit/ws-cli/internals/config"
+	"github.com/kloudkit/ws-cli/internals/env"
+)
+
+const (
+	ledgerName    = "ledger.json"
+	ledgerKeyName = "ledger.key"
+	ledgerVersion = 1
+	keyedPrefix   = "hmac-sha256:"
+)
+
+type LedgerEntry struct {
+	Source      string    `json:"source,omitempty"`
+	Op          Op        `json:"op"`
+	Mode        string    `json:"mode"`
+	Comment     string    `json:"comment,omitempty"`
+	State       Presence  `json:"state,omitempty"`
+	SourceHash  string    `json:"sourceHash"`
+	ContentHash string    `json:"contentHash"`
+	AppliedAt   time.Time `json:"appliedAt"`
+}
+
+type Ledger struct {
+	Version int                    `json:"version"`
+	Entries map[string]LedgerEntry `json:"entries"`
+	mu      sync.Mutex
+}
+
+func StateDir() string {
+	return env.String("WS__INTERNAL_SEED_STATE", filepath.Join(config.DefaultStatePath, "seed"))
+}
+
+func LedgerPath() string {
+	return filepath.Join(StateDir(), ledgerName)
+}
+
+func LoadLedger(path string) (*Ledger, error) {
+	ledger := &Ledger{Version: ledgerVersion, Entries: map[string]LedgerEntry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed ledger %q: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("failed to parse seed ledger %q: %w", path, err)
+	}
+
+	if ledger.Version != ledgerVersion {
+		return nil, fmt.Errorf("unsupported seed ledger version %d", ledger.Version)
+	}
+
+	if ledger.Entries == nil {
+		ledger.Entries = map[string]LedgerEntry{}
+	}
+
+	return ledger, nil
+}
+
+func (l *Ledger) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode seed ledger: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create seed state directory: %w", err)
+	}
+
+	return writeAtomic(dir, path, append(data, '\n'), 0o600)
+}
+
+func (l *Ledger) record(op ResolvedOp, result materialized) error {
+	if l == nil {
+		return nil
+	}
+
+	hash, err := contentHash(result.managed, result.secret || op.Template)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Entries[op.Dest] = LedgerEntry{
+		Source:      sourceKey(op.Layer),
+		Op:          op.Op,
+		Mode:        formatMode(result.mode),
+		Comment:     op.Comment,
+		SourceHash:  result.sourceHash,
+		ContentHash: hash,
+		AppliedAt:   time.Now().UTC(),
+	}
+
+	return nil
+}
+
+func (l *Ledger) recordAbsent(op ResolvedOp, managed []byte) error {
+	if l == nil {
+		return nil
+	}
+
+	entry := LedgerEntry{
+		Source:    sourceKey(op.Layer),
+		Op:        op.Op,
+		Comment:   op.Comment,
+		State:     PresenceAbsent,
+		AppliedAt: time.Now().UTC(),
+	}
+
+	if managed != nil {
+		hash, err := contentHash(managed, op.Secret || op.Template)
+		if err != nil {
+			return err
+		}
+
+		entry.ContentHash = hash
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Entries[op.Dest] = entry
+
+	return nil
+}
+
+func (l *Ledger) entry(dest string) (LedgerEntry, bool) {
+	if l == nil {
+		return LedgerEntry{}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.Entries[dest]
+
+	return entry, ok
+}
+
+func sourceKey(layer string) string {
+	if layer == "" {
+		return ""
+	}
+
+	key := filepath.Clean(layer)
+	if abs, err := filepath.Abs(layer); err == nil {
+		key = abs
+	}
+
+	if rel, err := filepath.Rel(CacheDir(), key); err == nil && filepath.IsLocal(rel) {
+		return filepath.Join(CacheDir(), strings.SplitN(rel, string(filepath.Separator), 2)[0])
+	}
+
+	return key
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func contentHash(content []byte, keyed bool) (string, error) {
+	if !keyed {
+		return hashBytes(content), nil
+	}
+
+	key, err := ledgerKey()
+	if err != nil {
+		return "", err
+	}
+
+	return keyedHash(key, content), nil
+}
+
+func keyedHash(key, content []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+
+	return keyedPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (e LedgerEntry) matcher() (func([]byte) bool, error) {
+	if !strings.HasPrefix(e.ContentHash, keyedPrefix) {
+		return func(content []byte) bool { return hashBytes(content) == e.ContentHash }, nil
+	}
+
+	key, err := ledgerKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(content []byte) bool { return keyedHash(key, content) == e.ContentHash }, nil
+}
+
+func ledgerKey() ([]byte, error) {
+	path := filepath.Join(StateDir(), ledgerKeyName)
+
+	key, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		key, err = createLedgerKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed ledger key: %w", err)
+	}
+
+	if len(key) != sha256.Size {
+		return nil, fmt.Errorf("invalid seed ledger key %q", path)
+	}
+
+	return key, nil
+}
+
+func createLedgerKey(path string) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Write(key); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return key, file.Close()
+}
+
+func formatMode(mode fs.FileMode) string {
+	return fmt.Sprintf("0o%o", mode.Perm())
+}
@@ -63,3 +63,16 @@ func appendLine(existing, line []byte) []byte {
 
 	return buffer.Bytes()
 }
+
+func removeLines(existing []byte, match func(string) bool) []byte {
+	var buffer bytes.Buffer
+	for _, line := range bytes.SplitAfter(existing, []byte("\n")) {
+		if len(line) > 0 && match(strings.TrimRight(string(line), "\n")) {
+			continue
+		}
+
+		buffer.Write(line)
+	}
+
+	return buffer.Bytes()
+}
@@ -0,0 +1,199 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+func (r reporter) relink(from, to string) {
+	if r.report != nil {
+		return
+	}
+
+	message := fmt.Sprintf("link %s -> %s", from, to)
+	if from == "" {
+		message = fmt.Sprintf("link -> %s", to)
+	}
+
+	if r.styled {
+		fmt.Fprintln(r.out, styles.Muted().Render(message))
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func (op ResolvedOp) linkTarget() (string, error) {
+	if op.Target != "" {
+		return op.Target, nil
+	}
+
+	if _, err := os.Lstat(op.Source); err != nil {
+		return "", fmt.Errorf("no source available")
+	}
+
+	return stableCachePath(op.Source), nil
+}
+
+func (p *Plan) linkOne(op ResolvedOp, rep reporter, ledger *Ledger, backups *backupRun) error {
+	ancestor := nearestExistingAncestor(op.Dest)
+	if !ownsPath(ancestor) {
+		rep.skip(op.Dest, "destination not owned")
+		return fmt.Errorf("destination not owned")
+	}
+
+	target, err := op.linkTarget()
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	current, err := os.Readlink(op.Dest)
+	linked := err == nil
+	changed := !linked || current != target
+
+	if changed && !op.Force && !errors.Is(err, fs.ErrNotExist) && !ledger.inSync(op.Dest) {
+		rep.skip(op.Dest, "exists")
+		return nil
+	}
+
+	if changed {
+		if err := op.runHooks(HookBefore, rep); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+
+		if err := backups.snapshot(op.Dest); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+
+		if err := writeSymlink(chooseAnchor(op.Dest, p.Vars, ancestor), op.Dest, target); err != nil {
+			rep.skip(op.Dest, err.Error())
+			return err
+		}
+	}
+
+	if err := ledger.record(op, materialized{managed: []byte(target), mode: fs.ModeSymlink | 0o777, sourceHash: hashBytes([]byte(target))}); err != nil {
+		rep.skip(op.Dest, err.Error())
+		return err
+	}
+
+	rep.seeded(op.Dest, changed)
+
+	if changed {
+		if err := op.runHooks(HookOnChange, rep); err != nil {
+			rep.hookFailed(op.Dest, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Plan) previewLink(op ResolvedOp, rep reporter, tally *dryRunTally) error {
+	if !ownsPath(nearestExistingAncestor(op.Dest)) {
+		rep.skip(op.Dest, "destination not owned")
+		tally.skipped++
+		return fmt.Errorf("destination not owned")
+	}
+
+	target, err := op.linkTarget()
+	if err != nil {
+		rep.skip(op.Dest, err.Error())
+		tally.skipped++
+		return err
+	}
+
+	current, err := os.Readlink(op.Dest)
+	switch {
+	case err == nil && current == target:
+		rep.planned(changeUnchanged, op.Dest)
+		tally.unchanged++
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		rep.planned(changeCreate, op.Dest)
+		tally.create++
+	case !op.Force && !inSyncDests([]string{op.Dest})[op.Dest]:
+		rep.skip(op.Dest, "exists")
+		tally.skipped++
+		return nil
+	default:
+		rep.planned(changeModify, op.Dest)
+		tally.modify++
+	}
+
+	rep.relink(current, target)
+	op.planHooks(rep)
+
+	return nil
+}
+
+func writeSymlink(anchor, dest, target string) error {
+	root, err := os.OpenRoot(anchor)
+	if err != nil {
+		return fmt.Errorf("failed to open root %q: %w", anchor, err)
+	}
+	defer root.Close()
+
+	rel, err := filepath.Rel(anchor, dest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve relative path: %w", err)
+	}
+
+	if relDir := filepath.Dir(rel); relDir != "." {
+		if err := root.MkdirAll(relDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+	}
+
+	tmp := rel + tempSuffix
+	root.Remove(tmp)
+
+	if err := root.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+
+	if err := root.Rename(tmp, rel); err != nil {
+		root.Remove(tmp)
+		return fmt.Errorf("failed to rename into place: %w", err)
+	}
+
+	return nil
+}
+
+func removeNode(anchor, dest string, kind fs.FileMode) error {
+	root, err := os.OpenRoot(anchor)
+	if err != nil {
+		return fmt.Errorf("failed to open root %q: %w", anchor, err)
+	}
+	defer root.Close()
+
+	rel, err := filepath.Rel(anchor, dest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve relative path: %w", err)
+	}
+
+	info, err := root.Lstat(rel)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %q: %w", dest, err)
+	}
+
+	if info.Mode().Type() != kind {
+		return fmt.Errorf("refusing to remove %q: unexpected file type", dest)
+	}
+
+	if err := root.Remove(rel); err != nil {
+		return fmt.Errorf("failed to remove: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func readlink(t *testing.T, path string) string {
+	t.Helper()
+	target, err := os.Readlink(path)
+	assert.NilError(t, err)
+	return target
+}
+
+func TestApplySymlink(t *testing.T) {
+	t.Run("IntoSource", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "bin", "tool")
+
+		write(t, rhyming(source, dest), "#!/bin/sh\n")
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n", dest))
+
+		apply(t, Options{Source: source})
+
+		assert.Equal(t, readlink(t, dest), rhyming(source, dest))
+	})
+
+	t.Run("ExplicitTarget", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".vimrc")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: ${ws_home}/.config/vim/vimrc\n", dest))
+
+		apply(t, Options{Source: source})
+
+		assert.Equal(t, readlink(t, dest), filepath.Join(home, ".config", "vim", "vimrc"))
+
+		plan, err := BuildPlan(source, false)
+		assert.NilError(t, err)
+		ledger, err := LoadLedger(LedgerPath())
+		assert.NilError(t, err)
+		statuses, err := plan.Status(ledger)
+		assert.NilError(t, err)
+		assert.Equal(t, statuses[0].State, StateInSync)
+	})
+
+	t.Run("ExistingKeptWithoutForce", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "link")
+		write(t, dest, "mine\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /etc/hosts\n", dest))
+
+		output := apply(t, Options{Source: source})
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dest+"] (exists)"))
+
+		apply(t, Options{Source: source, Force: true})
+		assert.Equal(t, readlink(t, dest), "/etc/hosts")
+	})
+
+	t.Run("RecordedLinkRepointedWithoutForce", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "link")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /missing/v1\n", dest))
+		apply(t, Options{Source: source})
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /etc/hosts\n", dest))
+		apply(t, Options{Source: source})
+
+		assert.Equal(t, readlink(t, dest), "/etc/hosts")
+	})
+
+	t.Run("RemoteSourceSurvivesNewRevision", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		target := t.TempDir()
+		dest := filepath.Join(target, "bin", "tool")
+
+		repo := t.TempDir()
+		gitRun(t, repo, "init", "-q", "-b", "main")
+		write(t, rhyming(repo, dest), "v1\n")
+		writeManifest(t, repo, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n", dest))
+		gitRun(t, repo, "add", "-A")
+		gitRun(t, repo, "commit", "-qm", "v1")
+
+		resolved, err := ResolveSource("git+file://" + repo + "#main")
+		assert.NilError(t, err)
+		apply(t, Options{Source: resolved})
+
+		write(t, rhyming(repo, dest), "v2\n")
+		gitRun(t, repo, "commit", "-qam", "v2")
+
+		next, err := ResolveSource("git+file://" + repo + "#main")
+		assert.NilError(t, err)
+		assert.Assert(t, next != resolved)
+		assert.NilError(t, os.RemoveAll(resolved))
+		apply(t, Options{Source: next})
+
+		assert.Assert(t, strings.HasPrefix(readlink(t, dest), CacheDir()))
+		assert.Equal(t, readFile(t, dest), "v2\n")
+	})
+
+	t.Run("UnchangedRunsNoHook", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "link")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /etc/hosts\n    onChange: echo relinked\n", dest))
+
+		assert.Assert(t, strings.Contains(apply(t, Options{Source: source}), "relinked"))
+		assert.Assert(t, !strings.Contains(apply(t, Options{Source: source}), "relinked"))
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "link")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /etc/hosts\n", dest))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+
+		_, err := os.Lstat(dest)
+		assert.Assert(t, os.IsNotExist(err))
+		assert.Assert(t, strings.Contains(output, "link -> /etc/hosts"))
+	})
+
+	t.Run("Absent", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "link")
+		assert.NilError(t, os.Symlink("/etc/hosts", dest))
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    state: absent\n", dest))
+
+		apply(t, Options{Source: source})
+
+		_, err := os.Lstat(dest)
+		assert.Assert(t, os.IsNotExist(err))
+		assert.Assert(t, fileExists("/etc/hosts"))
+	})
+
+	t.Run("Pruned", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "link")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /etc/hosts\n", dest))
+		apply(t, Options{Source: source})
+
+		writeManifest(t, source, "")
+		var buffer strings.Builder
+		assert.NilError(t, Prune(PruneOptions{Source: source, Out: &buffer}))
+
+		_, err := os.Lstat(dest)
+		assert.Assert(t, os.IsNotExist(err))
+	})
+}
+
+func TestMirrorPreservesSymlinks(t *testing.T) {
+	setEnv(t, t.TempDir())
+	source := t.TempDir()
+	target := t.TempDir()
+	dest := filepath.Join(target, ".bashrc")
+
+	write(t, rhyming(source, filepath.Join(target, ".config", "bashrc")), "rc\n")
+	assert.NilError(t, os.MkdirAll(filepath.Dir(rhyming(source, dest)), 0o755))
+	assert.NilError(t, os.Symlink(".config/bashrc", rhyming(source, dest)))
+
+	apply(t, Options{Source: source})
+
+	assert.Equal(t, readlink(t, dest), ".config/bashrc")
+
+	got, err := os.ReadFile(dest)
+	assert.NilError(t, err)
+	assert.Equal(t, string(got), "rc\n")
+}
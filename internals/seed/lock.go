@@ -0,0 +1,45 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const lockName = "apply.lock"
+
+func LockPath() string {
+	return filepath.Join(StateDir(), lockName)
+}
+
+func acquireLock(rep reporter) (func(), error) {
+	if err := os.MkdirAll(StateDir(), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create seed state directory: %w", err)
+	}
+
+	file, err := os.OpenFile(LockPath(), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open seed lock: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %q: %w", LockPath(), err)
+		}
+
+		rep.warn("waiting for another seed run to finish")
+
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %q: %w", LockPath(), err)
+		}
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
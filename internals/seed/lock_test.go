@@ -0,0 +1,117 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestApplyParallel(t *testing.T) {
+	t.Run("OutputInPlanOrder", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+
+		var manifest strings.Builder
+		manifest.WriteString("seeds:\n")
+
+		var expected []string
+		for i := range 12 {
+			dest := filepath.Join(target, fmt.Sprintf("file-%02d.txt", i))
+			write(t, rhyming(source, dest), "x\n")
+			fmt.Fprintf(&manifest, "  %s:\n    onChange: sleep 0.0%d\n", dest, 12-i)
+			expected = append(expected, "Seeded ["+dest+"]")
+		}
+		writeManifest(t, source, manifest.String())
+
+		output := apply(t, Options{Source: source, Jobs: 6})
+
+		var seeded []string
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "Seeded") {
+				seeded = append(seeded, line)
+			}
+		}
+
+		assert.DeepEqual(t, seeded, expected)
+	})
+
+	t.Run("ParentBeforeChild", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dir := filepath.Join(target, "private")
+		dest := filepath.Join(dir, "key")
+
+		write(t, rhyming(source, dest), "k\n")
+		writeManifest(t, source, fmt.Sprintf(
+			"seeds:\n  %s:\n    op: directory\n    mode: \"0o700\"\n    before: sleep 0.2\n  %s:\n    mode: \"0o600\"\n",
+			dir, dest,
+		))
+
+		apply(t, Options{Source: source, Jobs: 4})
+
+		assert.Equal(t, mode(t, dir), os.FileMode(0o700))
+		assert.Equal(t, mode(t, dest), os.FileMode(0o600))
+	})
+
+	t.Run("SharedSecret", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+
+		var manifest strings.Builder
+		fmt.Fprintf(&manifest, "secrets:\n  TOK: %s\nseeds:\n", encrypt(t, "shared", testMaster))
+		for i := range 3 {
+			fmt.Fprintf(&manifest, "  %s:\n    template: true\n    content: \"${secrets.TOK}\"\n", filepath.Join(target, fmt.Sprintf("%d.txt", i)))
+		}
+		writeManifest(t, source, manifest.String())
+
+		apply(t, Options{Source: source, MasterKey: testMaster})
+
+		for i := range 3 {
+			got, err := os.ReadFile(filepath.Join(target, fmt.Sprintf("%d.txt", i)))
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), "shared")
+		}
+	})
+}
+
+func TestApplyLock(t *testing.T) {
+	setEnv(t, t.TempDir())
+	source := t.TempDir()
+	target := t.TempDir()
+	dest := filepath.Join(target, "out.txt")
+
+	write(t, rhyming(source, dest), "x\n")
+
+	unlock, err := acquireLock(reporter{out: &bytes.Buffer{}})
+	assert.NilError(t, err)
+
+	finished := make(chan string)
+	go func() {
+		var buffer bytes.Buffer
+		Apply(Options{Source: source, Out: &buffer})
+		finished <- buffer.String()
+	}()
+
+	select {
+	case <-finished:
+		t.Fatal("apply ran while the lock was held")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assert.Assert(t, !fileExists(dest))
+
+	unlock()
+
+	output := <-finished
+	assert.Assert(t, strings.Contains(output, "waiting for another seed run to finish"))
+	assert.Assert(t, fileExists(dest))
+}
@@ -6,15 +6,18 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/kloudkit/ws-cli/internals/secrets"
 	"gopkg.in/yaml.v3"
 )
 
 const ManifestName = ".seed.yaml"
 
 type Manifest struct {
-	Version string            `yaml:"version"`
-	Secrets map[string]string `yaml:"secrets"`
-	Seeds   map[string]SeedOp `yaml:"seeds"`
+	Version    string              `yaml:"version"`
+	Recipients []string            `yaml:"recipients"`
+	Secrets    map[string]string   `yaml:"secrets"`
+	Seeds      map[string]SeedOp   `yaml:"seeds"`
+	Mirror     map[string]Selector `yaml:"mirror"`
 }
 
 func ManifestPath(source string) string {
@@ -40,6 +43,10 @@ func ParseManifest(data []byte) (*Manifest, error) {
 		return nil, fmt.Errorf("unsupported manifest version %q (expected \"v1\")", manifest.Version)
 	}
 
+	if _, err := secrets.ParseRecipients(manifest.Recipients); err != nil {
+		return nil, err
+	}
+
 	for name, value := range manifest.Secrets {
 		if err := validateSecretValue(name, value); err != nil {
 			return nil, err
@@ -61,6 +68,16 @@ func ParseManifest(data []byte) (*Manifest, error) {
 		}
 	}
 
+	for prefix, selector := range manifest.Mirror {
+		if selector.empty() {
+			return nil, fmt.Errorf("mirror %q: expected profiles or when", prefix)
+		}
+
+		if err := selector.validate(); err != nil {
+			return nil, fmt.Errorf("mirror %q: %w", prefix, err)
+		}
+	}
+
 	return &manifest, nil
 }
 
@@ -72,9 +89,21 @@ func validateSecretValue(name, value string) error {
 	return fmt.Errorf("secret %q: expected ciphertext or file: ref", name)
 }
 
+func secretContext(name string) string {
+	return "secrets." + name
+}
+
+func seedContext(rawDest string) string {
+	if rawDest == "" {
+		return ""
+	}
+
+	return "seeds." + rawDest
+}
+
 func validateOp(dest string, op SeedOp) error {
 	switch op.Op {
-	case OpCopy, OpMerge, OpAppend, OpPrepend, OpBlock, OpLineInfile:
+	case OpCopy, OpMerge, OpAppend, OpPrepend, OpBlock, OpLineInfile, OpSymlink, OpDirectory:
 	default:
 		return fmt.Errorf("seed %q: unknown op %q", dest, op.Op)
 	}
@@ -83,5 +112,59 @@ func validateOp(dest string, op SeedOp) error {
 		return fmt.Errorf("seed %q: comment is only valid with op: block", dest)
 	}
 
+	if op.Merge != nil {
+		if op.Op != OpMerge {
+			return fmt.Errorf("seed %q: merge is only valid with op: merge", dest)
+		}
+
+		if err := op.Merge.validate(); err != nil {
+			return fmt.Errorf("seed %q: %w", dest, err)
+		}
+	}
+
+	if op.Target != "" && op.Op != OpSymlink {
+		return fmt.Errorf("seed %q: target is only valid with op: symlink", dest)
+	}
+
+	if op.Empty && op.Op != OpDirectory {
+		return fmt.Errorf("seed %q: empty is only valid with op: directory", dest)
+	}
+
+	if op.Op.node() && (op.Content != nil || op.Secret || op.Template) {
+		return fmt.Errorf("seed %q: op: %s does not take content, secret or template", dest, op.Op)
+	}
+
+	if op.Op == OpSymlink && op.Mode != "" {
+		return fmt.Errorf("seed %q: op: symlink does not take a mode", dest)
+	}
+
+	if err := op.Selector.validate(); err != nil {
+		return fmt.Errorf("seed %q: %w", dest, err)
+	}
+
+	if err := op.Hooks.validate(); err != nil {
+		return fmt.Errorf("seed %q: %w", dest, err)
+	}
+
+	if op.Format != "" {
+		if op.Op != OpMerge {
+			return fmt.Errorf("seed %q: format is only valid with op: merge", dest)
+		}
+
+		if _, err := codecFor(dest, op.Format); err != nil {
+			return fmt.Errorf("seed %q: %w", dest, err)
+		}
+	}
+
+	switch op.State {
+	case "", PresencePresent:
+	case PresenceAbsent:
+		if !op.Op.retractable() {
+			return fmt.Errorf("seed %q: state: absent is only valid with op: copy, block, lineinfile, symlink or directory", dest)
+		}
+	default:
+		return fmt.Errorf("seed %q: unknown state %q", dest, op.State)
+	}
+
 	return nil
 }
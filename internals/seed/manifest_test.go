@@ -77,4 +77,74 @@ func TestParseManifest(t *testing.T) {
 		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    op: append\n    comment: \"//\"\n    content: \"x\\n\"\n"))
 		assert.ErrorContains(t, err, "comment is only valid with op: block")
 	})
+
+	t.Run("AbsentCopyAccepted", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    state: absent\n"))
+		assert.NilError(t, err)
+		assert.Equal(t, manifest.Seeds["/tmp/x"].Op, OpCopy)
+		assert.Equal(t, manifest.Seeds["/tmp/x"].State, PresenceAbsent)
+	})
+
+	t.Run("AbsentMergeRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x.json:\n    op: merge\n    state: absent\n"))
+		assert.ErrorContains(t, err, "state: absent is only valid with op: copy, block, lineinfile, symlink or directory")
+	})
+
+	t.Run("UnknownStateRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    op: block\n    state: gone\n"))
+		assert.ErrorContains(t, err, `unknown state "gone"`)
+	})
+
+	t.Run("FormatOverrideAccepted", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/gitconfig:\n    op: merge\n    format: gitconfig\n"))
+		assert.NilError(t, err)
+		assert.Equal(t, manifest.Seeds["/tmp/gitconfig"].Format, FormatGitConfig)
+	})
+
+	t.Run("FormatOnNonMergeRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    op: block\n    format: ini\n    content: \"x\\n\"\n"))
+		assert.ErrorContains(t, err, "format is only valid with op: merge")
+	})
+
+	t.Run("UnknownFormatRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    op: merge\n    format: xml\n"))
+		assert.ErrorContains(t, err, `unknown format "xml"`)
+	})
+
+	t.Run("ProfileScopedCopyAccepted", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    profiles: [backend]\n"))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, manifest.Seeds["/tmp/x"].Profiles, []string{"backend"})
+	})
+
+	t.Run("HookedCopyAccepted", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    onChange: tmux source-file /tmp/x\n"))
+		assert.NilError(t, err)
+		assert.Equal(t, manifest.Seeds["/tmp/x"].OnChange, "tmux source-file /tmp/x")
+	})
+
+	t.Run("TargetRequiresSymlink", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    target: /etc/hosts\n"))
+		assert.ErrorContains(t, err, "target is only valid with op: symlink")
+	})
+
+	t.Run("SymlinkRejectsContent", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    op: symlink\n    content: x\n"))
+		assert.ErrorContains(t, err, "op: symlink does not take content, secret or template")
+	})
+
+	t.Run("EmptyRequiresDirectory", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    content: x\n    empty: true\n"))
+		assert.ErrorContains(t, err, "empty is only valid with op: directory")
+	})
+
+	t.Run("InvalidWhenRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    when: \"ws_ssh and more\"\n"))
+		assert.ErrorContains(t, err, `when: invalid condition "ws_ssh and more"`)
+	})
+
+	t.Run("EmptyMirrorSelectorRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nmirror:\n  /tmp/m2: {}\n"))
+		assert.ErrorContains(t, err, `mirror "/tmp/m2": expected profiles or when`)
+	})
 }
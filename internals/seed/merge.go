@@ -5,32 +5,156 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 
 	toml "github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v3"
 )
 
+const deleteSentinel = "$delete"
+
+type ArrayStrategy string
+
+const (
+	ArrayReplace ArrayStrategy = "replace"
+	ArrayAppend  ArrayStrategy = "append"
+	ArrayPrepend ArrayStrategy = "prepend"
+	ArrayUnion   ArrayStrategy = "union"
+	ArrayKeyed   ArrayStrategy = "keyed"
+)
+
+type MergeRule struct {
+	Arrays ArrayStrategy `yaml:"arrays"`
+	Key    string        `yaml:"key"`
+}
+
+type MergeSpec struct {
+	MergeRule `yaml:",inline"`
+	Paths     map[string]MergeRule `yaml:"paths"`
+}
+
+func (s MergeSpec) ruleFor(path []string) MergeRule {
+	if rule, ok := s.Paths[strings.Join(path, ".")]; ok {
+		return rule
+	}
+
+	return s.MergeRule
+}
+
+func (s MergeSpec) validate() error {
+	rules := []MergeRule{s.MergeRule}
+	for _, rule := range s.Paths {
+		rules = append(rules, rule)
+	}
+
+	for _, rule := range rules {
+		switch rule.Arrays {
+		case "", ArrayReplace, ArrayAppend, ArrayPrepend, ArrayUnion:
+		case ArrayKeyed:
+			if rule.Key == "" {
+				return fmt.Errorf("arrays: keyed requires a key")
+			}
+		default:
+			return fmt.Errorf("unknown array strategy %q", rule.Arrays)
+		}
+	}
+
+	return nil
+}
+
+type Format string
+
+const (
+	FormatJSON       Format = "json"
+	FormatYAML       Format = "yaml"
+	FormatTOML       Format = "toml"
+	FormatINI        Format = "ini"
+	FormatProperties Format = "properties"
+	FormatDotenv     Format = "dotenv"
+	FormatGitConfig  Format = "gitconfig"
+)
+
 type codec struct {
 	unmarshal func([]byte, any) error
 	marshal   func(any) ([]byte, error)
+	patcher   func(existing []byte, merged map[string]any) (patcher, error)
 }
 
-func codecFor(dest string) (codec, error) {
-	switch strings.ToLower(filepath.Ext(dest)) {
-	case ".json":
-		return codec{unmarshalJSON, marshalJSON}, nil
+func inferFormat(dest string) (Format, error) {
+	base := strings.ToLower(filepath.Base(dest))
+	parent := filepath.Base(filepath.Dir(dest))
+
+	switch {
+	case base == ".gitconfig" || base == ".gitmodules" || (base == "config" && (parent == ".git" || parent == "git")):
+		return FormatGitConfig, nil
+	case base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env"):
+		return FormatDotenv, nil
+	case base == ".npmrc" || base == ".editorconfig":
+		return FormatINI, nil
+	}
+
+	switch filepath.Ext(base) {
+	case ".json", ".jsonc":
+		return FormatJSON, nil
 	case ".yaml", ".yml":
-		return codec{yaml.Unmarshal, yaml.Marshal}, nil
+		return FormatYAML, nil
 	case ".toml":
-		return codec{toml.Unmarshal, toml.Marshal}, nil
+		return FormatTOML, nil
+	case ".ini", ".cfg", ".conf":
+		return FormatINI, nil
+	case ".properties":
+		return FormatProperties, nil
 	}
 
-	return codec{}, fmt.Errorf("cannot infer merge format from %q", dest)
+	return "", fmt.Errorf("cannot infer merge format from %q", dest)
+}
+
+func codecFor(dest string, format Format) (codec, error) {
+	if format == "" {
+		inferred, err := inferFormat(dest)
+		if err != nil {
+			return codec{}, err
+		}
+
+		format = inferred
+	}
+
+	switch format {
+	case FormatJSON:
+		return codec{unmarshalJSON, marshalJSON, func(existing []byte, _ map[string]any) (patcher, error) {
+			return &jsonPatcher{data: slices.Clone(existing)}, nil
+		}}, nil
+	case FormatYAML:
+		return codec{yaml.Unmarshal, yaml.Marshal, func(existing []byte, _ map[string]any) (patcher, error) {
+			return newYAMLPatcher(existing)
+		}}, nil
+	case FormatTOML:
+		return codec{toml.Unmarshal, toml.Marshal, func(existing []byte, merged map[string]any) (patcher, error) {
+			return &tomlPatcher{data: slices.Clone(existing), merged: merged}, nil
+		}}, nil
+	case FormatINI:
+		return keyValueCodec(iniDialect), nil
+	case FormatGitConfig:
+		return keyValueCodec(gitConfigDialect), nil
+	case FormatProperties:
+		return keyValueCodec(propertiesDialect), nil
+	case FormatDotenv:
+		return keyValueCodec(dotenvDialect), nil
+	}
+
+	return codec{}, fmt.Errorf("unknown format %q", format)
+}
+
+func keyValueCodec(dialect kvDialect) codec {
+	return codec{dialect.unmarshal, dialect.marshal, func(existing []byte, _ map[string]any) (patcher, error) {
+		return &kvPatcher{dialect: dialect, data: slices.Clone(existing)}, nil
+	}}
 }
 
 func unmarshalJSON(data []byte, v any) error {
-	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder := json.NewDecoder(bytes.NewReader(standardizeJSONC(data)))
 	decoder.UseNumber()
 
 	return decoder.Decode(v)
@@ -49,41 +173,62 @@ func marshalJSON(v any) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func mergeContent(existing, fragment []byte, dest string) ([]byte, error) {
-	c, err := codecFor(dest)
+func mergeContent(existing, fragment []byte, dest string, format Format, spec MergeSpec) (merged []byte, layoutLost bool, err error) {
+	c, err := codecFor(dest, format)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	dst := map[string]any{}
-	if len(bytes.TrimSpace(existing)) > 0 {
-		if err := c.unmarshal(existing, &dst); err != nil {
-			return nil, fmt.Errorf("failed to decode existing %q", dest)
+	hasExisting := len(bytes.TrimSpace(existing)) > 0
+
+	old, dst := map[string]any{}, map[string]any{}
+	if hasExisting {
+		for _, target := range []*map[string]any{&old, &dst} {
+			if err := c.unmarshal(existing, target); err != nil {
+				return nil, false, fmt.Errorf("failed to decode existing %q", dest)
+			}
 		}
 	}
 
 	src := map[string]any{}
 	if len(bytes.TrimSpace(fragment)) > 0 {
 		if err := c.unmarshal(fragment, &src); err != nil {
-			return nil, fmt.Errorf("failed to decode fragment for %q", dest)
+			return nil, false, fmt.Errorf("failed to decode fragment for %q", dest)
 		}
 	}
 
-	if err := deepMerge(dst, src); err != nil {
-		return nil, err
+	if err := deepMerge(dst, src, spec, nil); err != nil {
+		return nil, false, err
+	}
+
+	if hasExisting {
+		if p, err := c.patcher(existing, dst); err == nil {
+			if out, err := preserveMerge(p, old, dst, c.unmarshal); err == nil {
+				return out, false, nil
+			}
+		}
 	}
 
-	return c.marshal(dst)
+	merged, err = c.marshal(dst)
+
+	return merged, hasExisting, err
 }
 
-func deepMerge(dst, src map[string]any) error {
+func deepMerge(dst, src map[string]any, spec MergeSpec, path []string) error {
 	for key, srcVal := range src {
+		if srcVal == deleteSentinel {
+			delete(dst, key)
+			continue
+		}
+
 		dstVal, exists := dst[key]
 		if !exists {
-			dst[key] = srcVal
+			dst[key] = withoutSentinels(srcVal)
 			continue
 		}
 
+		keyPath := append(slices.Clip(path), key)
+
 		srcMap, srcIsMap := srcVal.(map[string]any)
 		dstMap, dstIsMap := dstVal.(map[string]any)
 
@@ -92,9 +237,22 @@ func deepMerge(dst, src map[string]any) error {
 		}
 
 		if srcIsMap {
-			if err := deepMerge(dstMap, srcMap); err != nil {
+			if err := deepMerge(dstMap, srcMap, spec, keyPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		srcList, srcIsList := srcVal.([]any)
+		dstList, dstIsList := dstVal.([]any)
+
+		if srcIsList && dstIsList {
+			merged, err := mergeArrays(dstList, srcList, spec, keyPath)
+			if err != nil {
 				return err
 			}
+
+			dst[key] = merged
 			continue
 		}
 
@@ -103,3 +261,72 @@ func deepMerge(dst, src map[string]any) error {
 
 	return nil
 }
+
+func mergeArrays(dst, src []any, spec MergeSpec, path []string) ([]any, error) {
+	rule := spec.ruleFor(path)
+
+	switch rule.Arrays {
+	case ArrayAppend:
+		return slices.Concat(dst, src), nil
+	case ArrayPrepend:
+		return slices.Concat(src, dst), nil
+	case ArrayUnion:
+		var merged []any
+		for _, item := range slices.Concat(dst, src) {
+			if !slices.ContainsFunc(merged, func(existing any) bool { return reflect.DeepEqual(existing, item) }) {
+				merged = append(merged, item)
+			}
+		}
+
+		return merged, nil
+	case ArrayKeyed:
+		return mergeKeyed(dst, src, rule.Key, spec, path)
+	}
+
+	return src, nil
+}
+
+func mergeKeyed(dst, src []any, key string, spec MergeSpec, path []string) ([]any, error) {
+	merged := slices.Clone(dst)
+
+	for _, item := range src {
+		srcItem, ok := item.(map[string]any)
+		if !ok || srcItem[key] == nil {
+			return nil, fmt.Errorf("merge conflict at key %q: keyed item missing %q", strings.Join(path, "."), key)
+		}
+
+		index := slices.IndexFunc(merged, func(existing any) bool {
+			dstItem, ok := existing.(map[string]any)
+			return ok && reflect.DeepEqual(dstItem[key], srcItem[key])
+		})
+
+		if index < 0 {
+			merged = append(merged, withoutSentinels(srcItem))
+			continue
+		}
+
+		if err := deepMerge(merged[index].(map[string]any), srcItem, spec, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
+func withoutSentinels(value any) any {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+
+	for key, nested := range m {
+		if nested == deleteSentinel {
+			delete(m, key)
+			continue
+		}
+
+		m[key] = withoutSentinels(nested)
+	}
+
+	return m
+}
@@ -10,7 +10,7 @@ import (
 func decodeBack(t *testing.T, data []byte, dest string) map[string]any {
 	t.Helper()
 
-	c, err := codecFor(dest)
+	c, err := codecFor(dest, "")
 	assert.NilError(t, err)
 
 	out := map[string]any{}
@@ -33,7 +33,7 @@ func TestMergeContent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run("ListReplace/"+tt.name, func(t *testing.T) {
-			merged, err := mergeContent([]byte(tt.existing), []byte(tt.fragment), tt.dest)
+			merged, _, err := mergeContent([]byte(tt.existing), []byte(tt.fragment), tt.dest, "", MergeSpec{})
 			assert.NilError(t, err)
 
 			out := decodeBack(t, merged, tt.dest)
@@ -47,23 +47,23 @@ func TestMergeContent(t *testing.T) {
 	}
 
 	t.Run("ScalarVsMapConflict", func(t *testing.T) {
-		_, err := mergeContent([]byte(`{"k":"scalar"}`), []byte(`{"k":{"nested":1}}`), "config.json")
+		_, _, err := mergeContent([]byte(`{"k":"scalar"}`), []byte(`{"k":{"nested":1}}`), "config.json", "", MergeSpec{})
 		assert.ErrorContains(t, err, "merge conflict at key")
 	})
 
 	t.Run("MapVsScalarConflict", func(t *testing.T) {
-		_, err := mergeContent([]byte(`{"k":{"nested":1}}`), []byte(`{"k":"scalar"}`), "config.json")
+		_, _, err := mergeContent([]byte(`{"k":{"nested":1}}`), []byte(`{"k":"scalar"}`), "config.json", "", MergeSpec{})
 		assert.ErrorContains(t, err, "merge conflict at key")
 	})
 
 	t.Run("JSONNumberFidelity", func(t *testing.T) {
-		merged, err := mergeContent([]byte(`{"n":1}`), []byte(`{"n":2}`), "config.json")
+		merged, _, err := mergeContent([]byte(`{"n":1}`), []byte(`{"n":2}`), "config.json", "", MergeSpec{})
 		assert.NilError(t, err)
-		assert.Assert(t, strings.Contains(string(merged), `"n": 2`))
+		assert.Equal(t, string(merged), `{"n":2}`)
 	})
 
 	t.Run("LargeIntExistingSide", func(t *testing.T) {
-		merged, err := mergeContent([]byte(`{"big":9007199254740993}`), []byte(`{"x":1}`), "config.json")
+		merged, _, err := mergeContent([]byte(`{"big":9007199254740993}`), []byte(`{"x":1}`), "config.json", "", MergeSpec{})
 		assert.NilError(t, err)
 
 		out := string(merged)
@@ -73,7 +73,7 @@ func TestMergeContent(t *testing.T) {
 	})
 
 	t.Run("LargeIntFragmentSide", func(t *testing.T) {
-		merged, err := mergeContent([]byte(`{"x":1}`), []byte(`{"big":9223372036854775807}`), "config.json")
+		merged, _, err := mergeContent([]byte(`{"x":1}`), []byte(`{"big":9223372036854775807}`), "config.json", "", MergeSpec{})
 		assert.NilError(t, err)
 
 		out := string(merged)
@@ -92,14 +92,14 @@ func TestMergeContent(t *testing.T) {
 		}
 
 		for _, tc := range cases {
-			merged, err := mergeContent([]byte(tc.existing), []byte(tc.fragment), tc.dest)
+			merged, _, err := mergeContent([]byte(tc.existing), []byte(tc.fragment), tc.dest, "", MergeSpec{})
 			assert.NilError(t, err)
 			assert.Assert(t, strings.Contains(string(merged), "9223372036854775807"))
 		}
 	})
 
 	t.Run("NegativeZeroFloatStillMerge", func(t *testing.T) {
-		merged, err := mergeContent([]byte(`{"x":1}`), []byte(`{"neg":-5,"zero":0,"frac":1.5}`), "config.json")
+		merged, _, err := mergeContent([]byte(`{"x":1}`), []byte(`{"neg":-5,"zero":0,"frac":1.5}`), "config.json", "", MergeSpec{})
 		assert.NilError(t, err)
 
 		out := string(merged)
@@ -109,7 +109,288 @@ func TestMergeContent(t *testing.T) {
 	})
 
 	t.Run("UnknownExtensionRejected", func(t *testing.T) {
-		_, err := mergeContent([]byte("a"), []byte("b"), "config.ini")
+		_, _, err := mergeContent([]byte("a"), []byte("b"), "config.xyz", "", MergeSpec{})
 		assert.ErrorContains(t, err, "cannot infer merge format")
 	})
 }
+
+func TestMergeArrayStrategies(t *testing.T) {
+	existing := `{"list":[1,2],"servers":[{"name":"a","port":1},{"name":"b","port":2}],"keep":true}`
+
+	tests := []struct {
+		name     string
+		fragment string
+		spec     MergeSpec
+		want     string
+		absent   string
+	}{
+		{
+			name:     "ReplaceByDefault",
+			fragment: `{"list":[3]}`,
+			want:     `"list":[3]`,
+		},
+		{
+			name:     "Append",
+			fragment: `{"list":[2,3]}`,
+			spec:     MergeSpec{MergeRule: MergeRule{Arrays: ArrayAppend}},
+			want:     `"list":[1,2,2,3]`,
+		},
+		{
+			name:     "Prepend",
+			fragment: `{"list":[0]}`,
+			spec:     MergeSpec{MergeRule: MergeRule{Arrays: ArrayPrepend}},
+			want:     `"list":[0,1,2]`,
+		},
+		{
+			name:     "UnionDedupes",
+			fragment: `{"list":[2,3,3]}`,
+			spec:     MergeSpec{MergeRule: MergeRule{Arrays: ArrayUnion}},
+			want:     `"list":[1,2,3]`,
+		},
+		{
+			name:     "KeyedMergesMatchingItems",
+			fragment: `{"servers":[{"name":"b","port":20},{"name":"c","port":3}]}`,
+			spec:     MergeSpec{MergeRule: MergeRule{Arrays: ArrayKeyed, Key: "name"}},
+			want:     `"servers":[{"name":"a","port":1},{"name":"b","port":20},{"name":"c","port":3}]`,
+		},
+		{
+			name:     "PathOverride",
+			fragment: `{"list":[3],"servers":[{"name":"a","port":10}]}`,
+			spec: MergeSpec{
+				MergeRule: MergeRule{Arrays: ArrayAppend},
+				Paths:     map[string]MergeRule{"servers": {Arrays: ArrayKeyed, Key: "name"}},
+			},
+			want: `"list":[1,2,3],"servers":[{"name":"a","port":10},{"name":"b","port":2}]`,
+		},
+		{
+			name:     "DeleteSentinel",
+			fragment: `{"keep":"$delete","added":{"x":1,"gone":"$delete"}}`,
+			want:     `"added":{"x":1}`,
+			absent:   `"keep"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, _, err := mergeContent([]byte(existing), []byte(tt.fragment), "config.json", "", tt.spec)
+			assert.NilError(t, err)
+
+			compact := strings.Join(strings.Fields(string(merged)), "")
+			assert.Assert(t, strings.Contains(compact, tt.want), compact)
+
+			if tt.absent != "" {
+				assert.Assert(t, !strings.Contains(compact, tt.absent), compact)
+			}
+		})
+	}
+
+	t.Run("UnionDedupesDestination", func(t *testing.T) {
+		spec := MergeSpec{MergeRule: MergeRule{Arrays: ArrayUnion}}
+		merged, _, err := mergeContent([]byte(`{"list":[1,1,2,1]}`), []byte(`{"list":[2,3]}`), "config.json", "", spec)
+		assert.NilError(t, err)
+
+		compact := strings.Join(strings.Fields(string(merged)), "")
+		assert.Assert(t, strings.Contains(compact, `"list":[1,2,3]`), compact)
+	})
+
+	t.Run("KeyedItemMissingKeyRejected", func(t *testing.T) {
+		spec := MergeSpec{MergeRule: MergeRule{Arrays: ArrayKeyed, Key: "name"}}
+		_, _, err := mergeContent([]byte(existing), []byte(`{"servers":[{"port":9}]}`), "config.json", "", spec)
+		assert.ErrorContains(t, err, `keyed item missing "name"`)
+	})
+
+	t.Run("ManifestConfigures", func(t *testing.T) {
+		manifest, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x.json:\n    op: merge\n    content: '{}'\n" +
+			"    merge:\n      arrays: union\n      paths:\n        servers:\n          arrays: keyed\n          key: name\n"))
+		assert.NilError(t, err)
+
+		spec := manifest.Seeds["/tmp/x.json"].Merge
+		assert.Equal(t, spec.Arrays, ArrayUnion)
+		assert.Equal(t, spec.Paths["servers"].Key, "name")
+	})
+
+	t.Run("KeyedWithoutKeyRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x.json:\n    op: merge\n    content: '{}'\n    merge:\n      arrays: keyed\n"))
+		assert.ErrorContains(t, err, "arrays: keyed requires a key")
+	})
+
+	t.Run("MergeOnNonMergeOpRejected", func(t *testing.T) {
+		_, err := ParseManifest([]byte("version: v1\nseeds:\n  /tmp/x:\n    op: append\n    content: 'x'\n    merge:\n      arrays: union\n"))
+		assert.ErrorContains(t, err, "merge is only valid with op: merge")
+	})
+}
+
+func TestMergePreservesLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		dest     string
+		existing string
+		fragment string
+		spec     MergeSpec
+		expected string
+	}{
+		{
+			name:     "JSONC",
+			dest:     "settings.json",
+			existing: "{\n  // Editor\n  \"editor.fontSize\": 12, // small\n  \"files.exclude\": {\n    \"**/.git\": true,\n  },\n  \"drop\": 1,\n}\n",
+			fragment: `{"editor.fontSize": 14, "files.exclude": {"**/node_modules": true}, "drop": "$delete", "new": 1}`,
+			expected: "{\n  // Editor\n  \"editor.fontSize\": 14, // small\n  \"files.exclude\": {\n    \"**/.git\": true,\n    \"**/node_modules\": true,\n  },\n  \"new\": 1,\n}\n",
+		},
+		{
+			name:     "JSONWithoutTrailingCommas",
+			dest:     "config.json",
+			existing: "{\n    \"a\": 1,\n    \"b\": 2 /* tail */\n}\n",
+			fragment: `{"b": "$delete", "list": ["x"]}`,
+			expected: "{\n    \"a\": 1,\n    \"list\": [\n        \"x\"\n    ]\n}\n",
+		},
+		{
+			name:     "YAML",
+			dest:     "config.yaml",
+			existing: "# top\nname: demo # inline\nlist:\n  - a # first\nnested:\n  keep: 1\n  drop: 2\n",
+			fragment: "name: other\nlist: [b]\nnested:\n  drop: $delete\nnew: true\n",
+			spec:     MergeSpec{MergeRule: MergeRule{Arrays: ArrayAppend}},
+			expected: "# top\nname: other # inline\nlist:\n  - a # first\n  - b\nnested:\n  keep: 1\nnew: true\n",
+		},
+		{
+			name:     "YAMLMergeKey",
+			dest:     "config.yaml",
+			existing: "# shared\nbase: &base\n  a: 1\nprod:\n  <<: *base\n  b: 2\n",
+			fragment: "prod:\n  c: 3\n",
+			expected: "# shared\nbase: &base\n  a: 1\nprod:\n  <<: *base\n  b: 2\n  c: 3\n",
+		},
+		{
+			name:     "TOML",
+			dest:     "config.toml",
+			existing: "# top\ntitle = \"x\" # keep me\n\n[server]\n# port comment\nport = 80\nhost = \"a\"\n\n[other]\na.b = 1\n",
+			fragment: "title = \"y\"\n[server]\nport = 8080\nhost = \"$delete\"\nextra = true\n[other]\na.c = 2\n[fresh]\nk = \"v\"\n",
+			expected: "# top\ntitle = \"y\" # keep me\n\n[server]\n# port comment\nport = 8080\nextra = true\n\n[other]\na.b = 1\na.c = 2\n\n[fresh]\nk = \"v\"\n",
+		},
+		{
+			name:     "TOMLInlineTable",
+			dest:     "config.toml",
+			existing: "point = { x = 1 } # origin\n",
+			fragment: "point = { y = 2 }\n",
+			expected: "point = { x = 1, y = 2 } # origin\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, layoutLost, err := mergeContent([]byte(tt.existing), []byte(tt.fragment), tt.dest, "", tt.spec)
+
+			assert.NilError(t, err)
+			assert.Assert(t, !layoutLost)
+			assert.Equal(t, string(merged), tt.expected)
+		})
+	}
+
+	t.Run("FallsBackWhenLayoutCannotBeKept", func(t *testing.T) {
+		existing := "[[bins]]\nname = \"a\"\n"
+
+		merged, layoutLost, err := mergeContent([]byte(existing), []byte("bins = [{ name = \"b\" }]\n"), "config.toml", "", MergeSpec{})
+		assert.NilError(t, err)
+		assert.Assert(t, layoutLost)
+
+		bins, ok := decodeBack(t, merged, "config.toml")["bins"].([]any)
+		assert.Assert(t, ok)
+		assert.Equal(t, len(bins), 1)
+		assert.Equal(t, bins[0].(map[string]any)["name"], "b")
+	})
+
+	t.Run("JSONCFragment", func(t *testing.T) {
+		merged, _, err := mergeContent([]byte(`{"a":1}`), []byte("{\n  // comment\n  \"b\": 2,\n}"), "config.jsonc", "", MergeSpec{})
+
+		assert.NilError(t, err)
+		assert.Equal(t, string(merged), `{"a":1, "b": 2}`)
+	})
+}
+
+func TestMergeKeyValueFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		dest     string
+		format   Format
+		existing string
+		fragment string
+		spec     MergeSpec
+		expected string
+	}{
+		{
+			name:     "GitConfig",
+			dest:     "/home/dev/.gitconfig",
+			existing: "# identity\n[user]\n\tname = Old\n\temail = dev@example.com # work\n[remote \"origin\"]\n\tfetch = +refs/heads/*\n[Core]\n\teditor = vim\n",
+			fragment: "[user]\n\tname = New Name\n[remote \"origin\"]\n\tfetch = +refs/tags/*\n[core]\n\tpager = less -R\n[alias]\n\tst = status\n",
+			spec:     MergeSpec{Paths: map[string]MergeRule{`remote "origin".fetch`: {Arrays: ArrayUnion}}},
+			expected: "# identity\n[user]\n\tname = New Name\n\temail = dev@example.com # work\n[remote \"origin\"]\n\tfetch = +refs/heads/*\n\tfetch = +refs/tags/*\n[Core]\n\teditor = vim\n\tpager = less -R\n\n[alias]\n\tst = status\n",
+		},
+		{
+			name:     "Dotenv",
+			dest:     "/srv/app/.env",
+			existing: "# app\nexport A=1\nB='two words'\nC=3\n",
+			fragment: "B=changed value\nC=$delete\nD=x#y\n",
+			expected: "# app\nexport A=1\nB='changed value'\nD='x#y'\n",
+		},
+		{
+			name:     "Properties",
+			dest:     "/srv/app/application.properties",
+			existing: "# server\nserver.port = 80\nbanner = a \\\n   b\n",
+			fragment: "server.port=8080\nbanner=$delete\nspring.name=demo app\n",
+			expected: "# server\nserver.port = 8080\nspring.name = demo app\n",
+		},
+		{
+			name:     "INI",
+			dest:     "/etc/pip.conf",
+			existing: "[global]\ntimeout: 60\nindex-url = https://pypi.org/simple\n",
+			fragment: "[global]\ntimeout = 10\n[install]\nno-cache = true\n",
+			expected: "[global]\ntimeout: 10\nindex-url = https://pypi.org/simple\n\n[install]\nno-cache = true\n",
+		},
+		{
+			name:     "FormatOverride",
+			dest:     "/home/dev/.npmrc-work",
+			format:   FormatINI,
+			fragment: "registry=https://npm.example.com/\n",
+			expected: "registry = https://npm.example.com/\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, _, err := mergeContent([]byte(tt.existing), []byte(tt.fragment), tt.dest, tt.format, tt.spec)
+
+			assert.NilError(t, err)
+			assert.Equal(t, string(merged), tt.expected)
+		})
+	}
+
+	t.Run("UnknownFormatRejected", func(t *testing.T) {
+		_, _, err := mergeContent(nil, []byte("a=1\n"), "/tmp/x", "xml", MergeSpec{})
+		assert.ErrorContains(t, err, `unknown format "xml"`)
+	})
+}
+
+func TestInferFormat(t *testing.T) {
+	tests := []struct {
+		dest     string
+		expected Format
+	}{
+		{"/home/dev/.gitconfig", FormatGitConfig},
+		{"/home/dev/.config/git/config", FormatGitConfig},
+		{"/srv/repo/.git/config", FormatGitConfig},
+		{"/srv/app/.env", FormatDotenv},
+		{"/srv/app/.env.local", FormatDotenv},
+		{"/home/dev/.npmrc", FormatINI},
+		{"/home/dev/.config/pip/pip.conf", FormatINI},
+		{"/srv/app/setup.cfg", FormatINI},
+		{"/srv/app/application.properties", FormatProperties},
+		{"/home/dev/settings.JSONC", FormatJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dest, func(t *testing.T) {
+			format, err := inferFormat(tt.dest)
+
+			assert.NilError(t, err)
+			assert.Equal(t, format, tt.expected)
+		})
+	}
+}
@@ -9,22 +9,47 @@ const (
 	OpPrepend    Op = "prepend"
 	OpBlock      Op = "block"
 	OpLineInfile Op = "lineinfile"
+	OpSymlink    Op = "symlink"
+	OpDirectory  Op = "directory"
+)
+
+type Presence string
+
+const (
+	PresencePresent Presence = "present"
+	PresenceAbsent  Presence = "absent"
 )
 
 type SeedOp struct {
-	Mode     string  `yaml:"mode"`
-	Content  *string `yaml:"content"`
-	Secret   bool    `yaml:"secret"`
-	Op       Op      `yaml:"op"`
-	Template bool    `yaml:"template"`
-	Force    bool    `yaml:"force"`
-	Comment  string  `yaml:"comment"`
+	Mode     string     `yaml:"mode"`
+	Content  *string    `yaml:"content"`
+	Secret   bool       `yaml:"secret"`
+	Op       Op         `yaml:"op"`
+	Template bool       `yaml:"template"`
+	Force    bool       `yaml:"force"`
+	Comment  string     `yaml:"comment"`
+	State    Presence   `yaml:"state"`
+	Merge    *MergeSpec `yaml:"merge"`
+	Format   Format     `yaml:"format"`
+	Target   string     `yaml:"target"`
+	Empty    bool       `yaml:"empty"`
+	Selector `yaml:",inline"`
+	Hooks    `yaml:",inline"`
 }
 
 func (o SeedOp) hasBehavior() bool {
-	return o.Secret || o.Mode != "" || (o.Op != "" && o.Op != OpCopy) || o.Template || o.Content != nil
+	return o.Secret || o.Mode != "" || (o.Op != "" && o.Op != OpCopy) || o.Template || o.Content != nil ||
+		o.State == PresenceAbsent || o.Target != "" || o.Empty || !o.Selector.empty() || !o.Hooks.empty()
 }
 
 func (o Op) inPlace() bool {
 	return o == OpMerge || o == OpAppend || o == OpPrepend || o == OpBlock || o == OpLineInfile
 }
+
+func (o Op) retractable() bool {
+	return o == OpCopy || o == OpBlock || o == OpLineInfile || o == OpSymlink || o == OpDirectory
+}
+
+func (o Op) node() bool {
+	return o == OpSymlink || o == OpDirectory
+}
@@ -0,0 +1,104 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var errUnpreservable = errors.New("document layout cannot be preserved")
+
+type patcher interface {
+	set(path []string, value any) error
+	extend(path []string, items []any) error
+	remove(path []string) error
+	bytes() ([]byte, error)
+}
+
+func preserveMerge(p patcher, old, merged map[string]any, decode func([]byte, any) error) ([]byte, error) {
+	if err := patchChanges(p, old, merged, nil); err != nil {
+		return nil, err
+	}
+
+	out, err := p.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	check := map[string]any{}
+	if err := decode(out, &check); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnpreservable, err)
+	}
+
+	if !reflect.DeepEqual(check, merged) {
+		return nil, errUnpreservable
+	}
+
+	return out, nil
+}
+
+func patchChanges(p patcher, old, merged map[string]any, path []string) error {
+	for _, key := range sortedKeys(old) {
+		if _, kept := merged[key]; !kept {
+			if err := p.remove(append(slices.Clip(path), key)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(merged) {
+		keyPath := append(slices.Clip(path), key)
+		newVal := merged[key]
+
+		oldVal, had := old[key]
+		if had && reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+
+		if had {
+			oldMap, oldIsMap := oldVal.(map[string]any)
+			newMap, newIsMap := newVal.(map[string]any)
+
+			if oldIsMap && newIsMap {
+				if err := patchChanges(p, oldMap, newMap, keyPath); err != nil {
+					return err
+				}
+				continue
+			}
+
+			oldList, oldIsList := oldVal.([]any)
+			newList, newIsList := newVal.([]any)
+
+			if oldIsList && newIsList && len(oldList) > 0 && len(newList) > len(oldList) &&
+				reflect.DeepEqual(oldList, newList[:len(oldList)]) {
+				if err := p.extend(keyPath, newList[len(oldList):]); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if err := p.set(keyPath, newVal); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func describePath(path []string) string {
+	return strings.Join(path, ".")
+}
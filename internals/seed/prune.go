@@ -0,0 +1,205 @@
+package seed
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+type PruneOptions struct {
+	Source string
+	Force  bool
+	DryRun bool
+	Out    io.Writer
+	Styled bool
+}
+
+func (r reporter) pruned(dest string, dryRun bool) {
+	message := fmt.Sprintf("Pruned [%s]", dest)
+	if dryRun {
+		message = fmt.Sprintf("Would prune [%s]", dest)
+	}
+
+	if r.styled {
+		styles.PrintSuccess(r.out, message)
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func Prune(opts PruneOptions) error {
+	plan, err := BuildPlan(opts.Source, false)
+	if err != nil {
+		return err
+	}
+
+	rep := reporter{out: opts.Out, styled: opts.Styled}
+
+	if !opts.DryRun {
+		unlock, err := acquireLock(rep)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ledger, err := LoadLedger(LedgerPath())
+	if err != nil {
+		return err
+	}
+
+	planned := map[string]bool{}
+	for _, op := range plan.Ops {
+		planned[op.Dest] = true
+	}
+
+	sources := map[string]bool{}
+	for _, layer := range plan.Layers {
+		sources[sourceKey(layer)] = true
+	}
+
+	stale := make([]string, 0, len(ledger.Entries))
+	for dest, entry := range ledger.Entries {
+		if !planned[dest] && sources[entry.Source] {
+			stale = append(stale, dest)
+		}
+	}
+	sort.Strings(stale)
+
+	failures := 0
+	for _, dest := range stale {
+		forget, err := pruneOne(dest, ledger.Entries[dest], plan.Vars, opts, rep)
+		if err != nil {
+			failures++
+		}
+
+		if forget && !opts.DryRun {
+			delete(ledger.Entries, dest)
+		}
+	}
+
+	if !opts.DryRun {
+		if err := ledger.Save(LedgerPath()); err != nil {
+			return err
+		}
+	}
+
+	if failures > 0 {
+		noun := "destinations"
+		if failures == 1 {
+			noun = "destination"
+		}
+
+		return fmt.Errorf("%d stale %s failed to prune", failures, noun)
+	}
+
+	return nil
+}
+
+func pruneOne(dest string, entry LedgerEntry, vars Vars, opts PruneOptions, rep reporter) (bool, error) {
+	if entry.State == PresenceAbsent {
+		return true, nil
+	}
+
+	ancestor := nearestExistingAncestor(dest)
+	if !ownsPath(ancestor) {
+		rep.skip(dest, "destination not owned")
+		return false, fmt.Errorf("destination not owned")
+	}
+
+	state, err := entry.check(dest)
+	if err != nil {
+		rep.skip(dest, err.Error())
+		return false, err
+	}
+
+	if state == StateMissing {
+		rep.skip(dest, "missing")
+		return true, nil
+	}
+
+	switch entry.Op {
+	case OpCopy, OpBlock:
+		if state == StateDrifted && !opts.Force {
+			rep.skip(dest, "drifted")
+			return false, nil
+		}
+	case OpLineInfile:
+		if state == StateDrifted {
+			rep.skip(dest, "line no longer present")
+			return true, nil
+		}
+	case OpSymlink:
+		if state == StateDrifted && !opts.Force {
+			rep.skip(dest, "drifted")
+			return false, nil
+		}
+	case OpDirectory:
+		if entries, err := os.ReadDir(dest); err != nil || len(entries) > 0 {
+			rep.skip(dest, "directory not empty")
+			return true, nil
+		}
+	default:
+		rep.skip(dest, fmt.Sprintf("op: %s cannot be un-applied", entry.Op))
+		return true, nil
+	}
+
+	if opts.DryRun {
+		rep.pruned(dest, true)
+		return true, nil
+	}
+
+	anchor := chooseAnchor(dest, vars, ancestor)
+	if err := unapply(anchor, dest, entry); err != nil {
+		rep.skip(dest, err.Error())
+		return false, err
+	}
+
+	rep.pruned(dest, false)
+	return true, nil
+}
+
+func unapply(anchor, dest string, entry LedgerEntry) error {
+	switch entry.Op {
+	case OpCopy:
+		return removeAtomic(anchor, dest)
+	case OpSymlink:
+		return removeNode(anchor, dest, fs.ModeSymlink)
+	case OpDirectory:
+		return removeNode(anchor, dest, fs.ModeDir)
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		return err
+	}
+
+	existing, err := os.ReadFile(dest)
+	if err != nil {
+		return err
+	}
+
+	content := existing
+	switch entry.Op {
+	case OpBlock:
+		if content, err = removeBlock(existing, entry.Comment); err != nil {
+			return err
+		}
+	case OpLineInfile:
+		matches, err := entry.matcher()
+		if err != nil {
+			return err
+		}
+
+		content = removeLines(existing, func(line string) bool {
+			return matches([]byte(line))
+		})
+	}
+
+	return writeAtomic(anchor, dest, content, info.Mode().Perm())
+}
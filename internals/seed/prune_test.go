@@ -0,0 +1,191 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func prune(t *testing.T, opts PruneOptions) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	opts.Out = &buffer
+	assert.NilError(t, Prune(opts))
+	return buffer.String()
+}
+
+func ledgerHas(t *testing.T, dest string) bool {
+	t.Helper()
+	ledger, err := LoadLedger(LedgerPath())
+	assert.NilError(t, err)
+	_, ok := ledger.Entries[dest]
+	return ok
+}
+
+func TestPrune(t *testing.T) {
+	t.Run("RemovesDroppedMirrorFile", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		kept := filepath.Join(target, "kept.txt")
+		dropped := filepath.Join(target, "dropped.txt")
+
+		write(t, rhyming(source, kept), "kept\n")
+		write(t, rhyming(source, dropped), "dropped\n")
+		apply(t, Options{Source: source})
+
+		assert.NilError(t, os.Remove(rhyming(source, dropped)))
+		output := prune(t, PruneOptions{Source: source})
+
+		assert.Assert(t, fileExists(kept))
+		assert.Assert(t, !fileExists(dropped))
+		assert.Assert(t, strings.Contains(output, "Pruned ["+dropped+"]"))
+		assert.Assert(t, ledgerHas(t, kept))
+		assert.Assert(t, !ledgerHas(t, dropped))
+	})
+
+	t.Run("LeavesOtherSourcesAlone", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		sourceA, sourceB := t.TempDir(), t.TempDir()
+		target := t.TempDir()
+		keep := filepath.Join(target, "keep.txt")
+		dropped := filepath.Join(target, "dropped.txt")
+
+		write(t, rhyming(sourceA, keep), "keep\n")
+		write(t, rhyming(sourceB, dropped), "dropped\n")
+		apply(t, Options{Source: sourceA})
+		apply(t, Options{Source: sourceB})
+		assert.NilError(t, os.Remove(rhyming(sourceB, dropped)))
+
+		output := prune(t, PruneOptions{Source: sourceB})
+
+		assert.Assert(t, fileExists(keep))
+		assert.Assert(t, ledgerHas(t, keep))
+		assert.Assert(t, !strings.Contains(output, keep))
+		assert.Assert(t, !fileExists(dropped))
+		assert.Assert(t, strings.Contains(output, "Pruned ["+dropped+"]"))
+	})
+
+	t.Run("ArchiveVersionBump", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		target := t.TempDir()
+		kept := filepath.Join(target, "kept.txt")
+		dropped := filepath.Join(target, "dropped.txt")
+		archive := filepath.Join(t.TempDir(), "seed.tgz")
+
+		v1 := tarGz(t, map[string]string{strings.TrimPrefix(kept, "/"): "kept\n", strings.TrimPrefix(dropped, "/"): "dropped\n"})
+		assert.NilError(t, os.WriteFile(archive, v1, 0o644))
+		resolved, err := ResolveSource(archive + "#sha256=" + digest(v1))
+		assert.NilError(t, err)
+		apply(t, Options{Source: resolved})
+
+		v2 := tarGz(t, map[string]string{strings.TrimPrefix(kept, "/"): "kept\n"})
+		assert.NilError(t, os.WriteFile(archive, v2, 0o644))
+		resolved, err = ResolveSource(archive + "#sha256=" + digest(v2))
+		assert.NilError(t, err)
+
+		output := prune(t, PruneOptions{Source: resolved})
+
+		assert.Assert(t, fileExists(kept))
+		assert.Assert(t, !fileExists(dropped))
+		assert.Assert(t, strings.Contains(output, "Pruned ["+dropped+"]"))
+	})
+
+	t.Run("DryRunLeavesEverything", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dropped := filepath.Join(target, "dropped.txt")
+
+		write(t, rhyming(source, dropped), "dropped\n")
+		apply(t, Options{Source: source})
+		assert.NilError(t, os.Remove(rhyming(source, dropped)))
+
+		output := prune(t, PruneOptions{Source: source, DryRun: true})
+
+		assert.Assert(t, fileExists(dropped))
+		assert.Assert(t, ledgerHas(t, dropped))
+		assert.Assert(t, strings.Contains(output, "Would prune ["+dropped+"]"))
+	})
+
+	t.Run("DriftedKeptUnlessForced", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dropped := filepath.Join(target, "dropped.txt")
+
+		write(t, rhyming(source, dropped), "dropped\n")
+		apply(t, Options{Source: source})
+		assert.NilError(t, os.Remove(rhyming(source, dropped)))
+		write(t, dropped, "hand edited\n")
+
+		output := prune(t, PruneOptions{Source: source})
+		assert.Assert(t, fileExists(dropped))
+		assert.Assert(t, strings.Contains(output, "Skipping ["+dropped+"] (drifted)"))
+
+		prune(t, PruneOptions{Source: source, Force: true})
+		assert.Assert(t, !fileExists(dropped))
+	})
+
+	t.Run("StripsBlockAndLine", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		rc := filepath.Join(target, "rc")
+		env := filepath.Join(target, "env")
+		write(t, rc, "user\n")
+		write(t, env, "A=1\n")
+
+		writeManifest(t, source, fmt.Sprintf(
+			"seeds:\n  %s:\n    op: block\n    comment: \"//\"\n    content: \"x=1\\n\"\n  %s:\n    op: lineinfile\n    content: \"B=2\"\n",
+			rc, env,
+		))
+		apply(t, Options{Source: source})
+		write(t, rc, readFile(t, rc)+"tail\n")
+
+		writeManifest(t, source, "")
+		prune(t, PruneOptions{Source: source})
+
+		assert.Equal(t, readFile(t, rc), "user\ntail\n")
+		assert.Equal(t, readFile(t, env), "A=1\n")
+	})
+
+	t.Run("MergeForgottenNotRemoved", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "config.json")
+		write(t, dest, `{"a":1}`)
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: merge\n    force: true\n    content: '{\"b\":2}'\n", dest))
+		apply(t, Options{Source: source})
+
+		writeManifest(t, source, "")
+		output := prune(t, PruneOptions{Source: source})
+
+		assert.Assert(t, fileExists(dest))
+		assert.Assert(t, strings.Contains(output, "op: merge cannot be un-applied"))
+		assert.Assert(t, !ledgerHas(t, dest))
+	})
+
+	t.Run("MissingForgotten", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dropped := filepath.Join(target, "dropped.txt")
+
+		write(t, rhyming(source, dropped), "dropped\n")
+		apply(t, Options{Source: source})
+		assert.NilError(t, os.Remove(rhyming(source, dropped)))
+		assert.NilError(t, os.Remove(dropped))
+
+		prune(t, PruneOptions{Source: source})
+
+		assert.Assert(t, !ledgerHas(t, dropped))
+	})
+}
@@ -0,0 +1,207 @@
+package seed
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/kloudkit/ws-cli/internals/secrets"
+	"github.com/kloudkit/ws-cli/internals/styles"
+	"gopkg.in/yaml.v3"
+)
+
+type RecipientsOptions struct {
+	Source   string
+	Identity string
+	Add      []string
+	Remove   []string
+	Out      io.Writer
+	Styled   bool
+}
+
+func UpdateRecipients(opts RecipientsOptions) error {
+	layers := Layers(opts.Source)
+	if len(layers) == 0 {
+		return fmt.Errorf("no seed source configured (use --source)")
+	}
+
+	layer := layers[len(layers)-1]
+	manifestPath := ManifestPath(layer)
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	next, err := secrets.ParseRecipients(manifest.Recipients)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range opts.Add {
+		recipient, err := secrets.ParseRecipient(value)
+		if err != nil {
+			return err
+		}
+
+		if slices.ContainsFunc(next, func(r secrets.Recipient) bool { return r.ID() == recipient.ID() }) {
+			return fmt.Errorf("recipient %s is already listed", recipient)
+		}
+
+		next = append(next, recipient)
+	}
+
+	for _, value := range opts.Remove {
+		index := slices.IndexFunc(next, func(r secrets.Recipient) bool { return r.String() == value || r.ID() == value })
+		if index < 0 {
+			return fmt.Errorf("recipient %s is not listed in %s", value, manifestPath)
+		}
+
+		next = slices.Delete(next, index, index+1)
+	}
+
+	unlock, err := acquireLock(reporter{out: opts.Out, styled: opts.Styled})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	targets, docs, err := collectLayers([]string{layer})
+	if err != nil {
+		return err
+	}
+
+	targets = slices.DeleteFunc(targets, func(target rotateTarget) bool {
+		return !secrets.IsRecipientEncrypted(target.cipher)
+	})
+
+	if len(targets) > 0 {
+		if len(next) == 0 {
+			return fmt.Errorf("cannot remove the last recipient while %d secret(s) are encrypted for recipients", len(targets))
+		}
+
+		identity, err := secrets.ResolveIdentity(opts.Identity)
+		if err != nil {
+			return err
+		}
+
+		for i := range targets {
+			if len(opts.Remove) == 0 {
+				targets[i].rotated, err = secrets.Rewrap(targets[i].cipher, identity, next)
+			} else {
+				targets[i].rotated, err = reencryptFor(targets[i], identity, next)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", targets[i].describe, err)
+			}
+		}
+	}
+
+	manifests := map[string]bool{manifestPath: true}
+	if err := preflightWritable(targets, manifests); err != nil {
+		return err
+	}
+
+	setRecipients(documentRoot(docs[manifestPath]), next)
+
+	for i := range targets {
+		if err := targets[i].writeBack(targets[i].rotated); err != nil {
+			return fmt.Errorf("%s: %w", targets[i].describe, err)
+		}
+	}
+
+	if err := writeManifestFile(manifestPath, docs[manifestPath]); err != nil {
+		return err
+	}
+
+	messages := make([]string, 0, len(opts.Add)+len(opts.Remove)+1)
+	for _, value := range opts.Add {
+		messages = append(messages, fmt.Sprintf("Added recipient %s", value))
+	}
+
+	for _, value := range opts.Remove {
+		messages = append(messages, fmt.Sprintf("Removed recipient %s", value))
+	}
+
+	if len(targets) > 0 {
+		noun := "secrets"
+		if len(targets) == 1 {
+			noun = "secret"
+		}
+
+		verb := "Re-wrapped"
+		if len(opts.Remove) > 0 {
+			verb = "Re-encrypted"
+		}
+
+		messages = append(messages, fmt.Sprintf("%s %d %s for %d recipient(s)", verb, len(targets), noun, len(next)))
+	}
+
+	for _, message := range messages {
+		if opts.Styled {
+			styles.PrintSuccess(opts.Out, message)
+		} else {
+			fmt.Fprintln(opts.Out, message)
+		}
+	}
+
+	return nil
+}
+
+func reencryptFor(target rotateTarget, identity secrets.Identity, recipients []secrets.Recipient) (string, error) {
+	context := ""
+	if secrets.IsBound(target.cipher) {
+		context = target.context()
+	}
+
+	plain, err := secrets.DecryptWithContext(target.cipher, identity, context)
+	if err != nil {
+		return "", err
+	}
+	defer zeroBytes(plain)
+
+	return secrets.EncryptForContext(plain, recipients, context)
+}
+
+func setRecipients(root *yaml.Node, recipients []secrets.Recipient) {
+	sequence := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, recipient := range recipients {
+		sequence.Content = append(sequence.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: recipient.String()})
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "recipients" {
+			continue
+		}
+
+		if len(recipients) == 0 {
+			root.Content = slices.Delete(root.Content, i, i+2)
+			return
+		}
+
+		root.Content[i+1] = sequence
+		return
+	}
+
+	if len(recipients) == 0 {
+		return
+	}
+
+	at := 0
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "version" {
+			at = i + 2
+		}
+	}
+
+	root.Content = slices.Insert(root.Content, at, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "recipients"}, sequence)
+}
+
+func manifestRecipients(manifestPath string) ([]secrets.Recipient, error) {
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets.ParseRecipients(manifest.Recipients)
+}
@@ -0,0 +1,243 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kloudkit/ws-cli/internals/secrets"
+	"gotest.tools/v3/assert"
+)
+
+func newIdentity(t *testing.T) secrets.Identity {
+	t.Helper()
+
+	identity, err := secrets.GenerateIdentity()
+	assert.NilError(t, err)
+
+	return identity
+}
+
+func encryptFor(t *testing.T, plaintext string, identities ...secrets.Identity) string {
+	t.Helper()
+
+	var recipients []secrets.Recipient
+	for _, identity := range identities {
+		recipients = append(recipients, identity.Recipient())
+	}
+
+	encrypted, err := secrets.EncryptFor([]byte(plaintext), recipients)
+	assert.NilError(t, err)
+
+	return encrypted
+}
+
+func decryptsFor(t *testing.T, ciphertext string, identity secrets.Identity, want string) {
+	t.Helper()
+
+	plain, err := secrets.DecryptWith(secrets.NormalizeEncrypted(ciphertext), identity)
+	assert.NilError(t, err)
+	assert.Equal(t, string(plain), want)
+}
+
+func updateRecipients(t *testing.T, opts RecipientsOptions) string {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	opts.Out = &buffer
+	assert.NilError(t, UpdateRecipients(opts))
+
+	return buffer.String()
+}
+
+func payloadOf(ciphertext string) string {
+	return ciphertext[strings.LastIndex(ciphertext, "$"):]
+}
+
+func TestRecipients(t *testing.T) {
+	t.Run("AddRewrapsWithoutReencrypting", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice, bob := newIdentity(t), newIdentity(t)
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "id_key")
+
+		token := encryptFor(t, "TOKEN", alice)
+		shared := encrypt(t, "SHARED", testMaster)
+		write(t, rhyming(source, dest), encryptFor(t, "KEY\n", alice))
+		writeManifest(t, source, fmt.Sprintf(
+			"recipients:\n  - %s\nsecrets:\n  TOK: %s\n  SHARED: %s\nseeds:\n  %s:\n    secret: true\n",
+			alice.Recipient(), token, shared, dest,
+		))
+
+		output := updateRecipients(t, RecipientsOptions{
+			Source:   source,
+			Identity: alice.String(),
+			Add:      []string{bob.Recipient().String()},
+		})
+
+		assert.Assert(t, strings.Contains(output, "Re-wrapped 2 secrets for 2 recipient(s)"))
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, manifest.Recipients, []string{alice.Recipient().String(), bob.Recipient().String()})
+		assert.Equal(t, payloadOf(manifest.Secrets["TOK"]), payloadOf(token))
+		assert.Equal(t, manifest.Secrets["SHARED"], shared)
+		decryptsFor(t, manifest.Secrets["TOK"], bob, "TOKEN")
+		decryptsFor(t, readFile(t, rhyming(source, dest)), bob, "KEY\n")
+
+		apply(t, Options{Source: source, Identity: bob.String(), MasterKey: testMaster})
+		assert.Equal(t, readFile(t, dest), "KEY\n")
+	})
+
+	t.Run("RemoveRevokesAccess", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice, bob := newIdentity(t), newIdentity(t)
+		source := t.TempDir()
+
+		token := encryptFor(t, "TOKEN", alice, bob)
+		writeManifest(t, source, fmt.Sprintf(
+			"recipients:\n  - %s\n  - %s\nsecrets:\n  TOK: %s\n",
+			alice.Recipient(), bob.Recipient(), token,
+		))
+
+		output := updateRecipients(t, RecipientsOptions{
+			Source:   source,
+			Identity: bob.String(),
+			Remove:   []string{alice.Recipient().ID()},
+		})
+
+		assert.Assert(t, strings.Contains(output, "Re-encrypted 1 secret for 1 recipient(s)"))
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, manifest.Recipients, []string{bob.Recipient().String()})
+		decryptsFor(t, manifest.Secrets["TOK"], bob, "TOKEN")
+
+		_, err = secrets.DecryptWith(manifest.Secrets["TOK"], alice)
+		assert.ErrorContains(t, err, "is not a recipient")
+
+		stale := token[:strings.LastIndex(token, "$")] + payloadOf(manifest.Secrets["TOK"])
+		_, err = secrets.DecryptWith(stale, alice)
+		assert.Assert(t, err != nil)
+	})
+
+	t.Run("RefusesLastRecipient", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice := newIdentity(t)
+		source := t.TempDir()
+
+		writeManifest(t, source, fmt.Sprintf(
+			"recipients:\n  - %s\nsecrets:\n  TOK: %s\n",
+			alice.Recipient(), encryptFor(t, "TOKEN", alice),
+		))
+		before := readFile(t, ManifestPath(source))
+
+		err := UpdateRecipients(RecipientsOptions{
+			Source:   source,
+			Identity: alice.String(),
+			Remove:   []string{alice.Recipient().String()},
+			Out:      &bytes.Buffer{},
+		})
+
+		assert.ErrorContains(t, err, "cannot remove the last recipient")
+		assert.Equal(t, readFile(t, ManifestPath(source)), before)
+	})
+
+	t.Run("NonRecipientIdentityFailsClosed", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice, mallory := newIdentity(t), newIdentity(t)
+		source := t.TempDir()
+
+		writeManifest(t, source, fmt.Sprintf(
+			"recipients:\n  - %s\nsecrets:\n  TOK: %s\n",
+			alice.Recipient(), encryptFor(t, "TOKEN", alice),
+		))
+		before := readFile(t, ManifestPath(source))
+
+		err := UpdateRecipients(RecipientsOptions{
+			Source:   source,
+			Identity: mallory.String(),
+			Add:      []string{mallory.Recipient().String()},
+			Out:      &bytes.Buffer{},
+		})
+
+		assert.ErrorContains(t, err, "is not a recipient")
+		assert.Equal(t, readFile(t, ManifestPath(source)), before)
+	})
+
+	t.Run("FirstRecipientAddsList", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice := newIdentity(t)
+		source := t.TempDir()
+		writeManifest(t, source, "seeds:\n  /tmp/keep:\n    content: \"plain\\n\"\n")
+
+		updateRecipients(t, RecipientsOptions{Source: source, Add: []string{alice.Recipient().String()}})
+
+		after := readFile(t, ManifestPath(source))
+		assert.Assert(t, strings.Index(after, "version:") < strings.Index(after, "recipients:"))
+		assert.Assert(t, strings.Index(after, "recipients:") < strings.Index(after, "seeds:"))
+	})
+
+	t.Run("AddCapturesForRecipients", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		t.Setenv("WS_SECRETS_MASTER_KEY", "")
+		alice := newIdentity(t)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".netrc")
+		write(t, dest, "machine example.com\n")
+		writeManifest(t, source, fmt.Sprintf("recipients:\n  - %s\n", alice.Recipient()))
+
+		add(t, AddOptions{Source: source, Path: dest, Secret: true})
+
+		captured := readFile(t, rhyming(source, dest))
+		assert.Assert(t, secrets.IsRecipientEncrypted(secrets.NormalizeEncrypted(captured)))
+		assert.Assert(t, secrets.IsBound(secrets.NormalizeEncrypted(captured)))
+
+		assert.NilError(t, os.Remove(dest))
+		apply(t, Options{Source: source, Identity: alice.String()})
+		assert.Equal(t, readFile(t, dest), "machine example.com\n")
+	})
+
+	t.Run("ApplyWithoutIdentityFails", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		t.Setenv("WS_SECRETS_IDENTITY", "")
+		alice := newIdentity(t)
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "id_key")
+
+		write(t, rhyming(source, dest), encryptFor(t, "KEY\n", alice))
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    secret: true\n", dest))
+
+		output := applyErr(t, Options{Source: source})
+
+		assert.Assert(t, strings.Contains(output, "identity unavailable"))
+		assert.Assert(t, !fileExists(dest))
+	})
+
+	t.Run("RotateSkipsRecipientSecrets", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice := newIdentity(t)
+		source := t.TempDir()
+		token := encryptFor(t, "TOKEN", alice)
+
+		writeManifest(t, source, fmt.Sprintf(
+			"recipients:\n  - %s\nsecrets:\n  TOK: %s\n  SHARED: %s\n",
+			alice.Recipient(), token, encrypt(t, "SHARED", testMaster),
+		))
+
+		output := rotate(t, RotateOptions{Source: source, MasterKey: testMaster, NewMasterKey: testNewMaster})
+
+		assert.Assert(t, strings.Contains(output, `Rotated secret "SHARED"`))
+		assert.Assert(t, !strings.Contains(output, `"TOK"`))
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		assert.Equal(t, manifest.Secrets["TOK"], token)
+	})
+}
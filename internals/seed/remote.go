@@ -0,0 +1,695 @@
+package seed
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"github.com/kloudkit/ws-cli/internals/path"
+)
+
+const (
+	checksumPrefix = "sha256="
+	remoteTimeout  = 60 * time.Second
+	fetchLockName  = "fetch.lock"
+	stagingPrefix  = ".staging-"
+	currentName    = "current"
+)
+
+var (
+	maxArchiveSize    int64 = 256 << 20
+	maxUnpackedSize   int64 = 1 << 30
+	maxArchiveEntries       = 50000
+)
+
+var (
+	heldTrees   = map[string]*os.File{}
+	heldTreesMu sync.Mutex
+)
+
+var remoteWarningWriter io.Writer = os.Stderr
+
+var (
+	scpLikeRe = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+$`)
+	commitRe  = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	portRe    = regexp.MustCompile(`^[0-9]+(?:[/#]|$)`)
+)
+
+type remoteKind string
+
+const (
+	remoteGit     remoteKind = "git"
+	remoteArchive remoteKind = "archive"
+)
+
+type remote struct {
+	kind     remoteKind
+	location string
+	ref      string
+	checksum string
+}
+
+func CacheDir() string {
+	return filepath.Join(StateDir(), "cache")
+}
+
+func splitSources(flag string) []string {
+	var (
+		layers  []string
+		current string
+		joining bool
+	)
+
+	for _, part := range strings.Split(flag, string(filepath.ListSeparator)) {
+		if joining || strings.HasPrefix(part, "//") || (awaitsPort(current) && portRe.MatchString(part)) {
+			current += string(filepath.ListSeparator) + part
+			joining = false
+			continue
+		}
+
+		if current != "" {
+			layers = append(layers, current)
+		}
+
+		current = part
+		joining = scpLikeRe.MatchString(part)
+	}
+
+	if current != "" {
+		layers = append(layers, current)
+	}
+
+	return layers
+}
+
+func awaitsPort(layer string) bool {
+	_, host, ok := strings.Cut(layer, "://")
+	return ok && host != "" && !strings.ContainsAny(host, "/:")
+}
+
+func parseRemote(layer string) (remote, bool, error) {
+	location, fragment, _ := strings.Cut(layer, "#")
+	lower := strings.ToLower(location)
+
+	switch {
+	case strings.HasPrefix(lower, "git+"):
+		return gitRemote(location[len("git+"):], fragment)
+	case strings.HasSuffix(lower, ".git") || strings.HasPrefix(lower, "git://") || scpLikeRe.MatchString(strings.SplitN(location, ":", 2)[0]):
+		return gitRemote(location, fragment)
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") || strings.HasSuffix(lower, ".zip"):
+		r := remote{kind: remoteArchive, location: location}
+
+		if fragment != "" {
+			checksum, ok := strings.CutPrefix(fragment, checksumPrefix)
+			if !ok || len(checksum) != sha256.Size*2 {
+				return remote{}, false, fmt.Errorf("seed source %q: expected #%s<hex digest>", location, checksumPrefix)
+			}
+
+			r.checksum = strings.ToLower(checksum)
+		}
+
+		if r.checksum == "" && isHTTP(location) {
+			return remote{}, false, fmt.Errorf("seed source %q: archives fetched over HTTP need #%s<hex digest>", location, checksumPrefix)
+		}
+
+		return r, true, nil
+	}
+
+	return remote{}, false, nil
+}
+
+func gitRemote(location, ref string) (remote, bool, error) {
+	if strings.HasPrefix(location, "-") || strings.HasPrefix(ref, "-") {
+		return remote{}, false, fmt.Errorf("seed source %q: must not start with '-'", location)
+	}
+
+	return remote{kind: remoteGit, location: location, ref: ref}, true, nil
+}
+
+func isHTTP(location string) bool {
+	lower := strings.ToLower(location)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func (r remote) fetch(cacheDir string) (string, error) {
+	if r.kind == remoteGit {
+		return r.fetchGit(cacheDir)
+	}
+
+	return r.fetchArchive(cacheDir)
+}
+
+func (r remote) key() string {
+	sum := sha256.Sum256([]byte(r.location))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (r remote) fetchGit(cacheDir string) (string, error) {
+	root := filepath.Join(cacheDir, "git-"+r.key())
+	gitDir := filepath.Join(root, "repo.git")
+
+	unlock, err := lockFetch(root)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	git := func(workTree string, args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"--git-dir", gitDir, "--work-tree", workTree}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	if !internalIO.FileExists(gitDir) {
+		if err := exec.Command("git", "init", "-q", "--bare", gitDir).Run(); err != nil {
+			return "", fmt.Errorf("git init: %w", err)
+		}
+	}
+
+	pinned := commitRe.MatchString(r.ref)
+
+	revision := "FETCH_HEAD"
+	if pinned {
+		revision = r.ref
+	}
+
+	if !pinned || !r.hasCommit(git) {
+		ref := r.ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+
+		if _, err := git(root, "fetch", "-q", "--depth", "1", "--", r.location, ref); err != nil {
+			err = fmt.Errorf("seed source %q: %w", r.location, err)
+
+			cached, lookupErr := git(root, "rev-parse", "--verify", "-q", r.cachedRef()+"^{commit}")
+			if pinned || lookupErr != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(remoteWarningWriter, "Warning %s; using cached revision %s\n", err, cached[:12])
+			revision = r.cachedRef()
+		}
+	}
+
+	commit, err := git(root, "rev-parse", "--verify", revision+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("seed source %q: %w", r.location, err)
+	}
+
+	if pinned && commit != r.ref {
+		return "", fmt.Errorf("seed source %q: resolved %s, expected %s", r.location, commit, r.ref)
+	}
+
+	if !pinned {
+		if _, err := git(root, "update-ref", r.cachedRef(), commit); err != nil {
+			return "", fmt.Errorf("seed source %q: %w", r.location, err)
+		}
+	}
+
+	tree, err := populateTree(root, commit, func(staging string) error {
+		_, err := git(staging, "checkout", "-q", "--force", "--detach", commit)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("seed source %q: %w", r.location, err)
+	}
+
+	return tree, nil
+}
+
+// cachedRef names the local ref remembering the last commit an unpinned ref
+// resolved to, so an unreachable remote can fall back to it.
+func (r remote) cachedRef() string {
+	sum := sha256.Sum256([]byte(r.ref))
+	return "refs/seed/" + hex.EncodeToString(sum[:8])
+}
+
+func (r remote) hasCommit(git func(string, ...string) (string, error)) bool {
+	_, err := git(".", "cat-file", "-e", r.ref+"^{commit}")
+	return err == nil
+}
+
+func (r remote) fetchArchive(cacheDir string) (string, error) {
+	root := filepath.Join(cacheDir, "archive-"+r.key())
+
+	unlock, err := lockFetch(root)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if r.checksum != "" && internalIO.FileExists(filepath.Join(root, r.checksum)) {
+		return populateTree(root, r.checksum, nil)
+	}
+
+	data, err := r.download()
+	if err != nil {
+		return "", fmt.Errorf("seed source %q: %w", r.location, err)
+	}
+
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+	if r.checksum != "" && actual != r.checksum {
+		return "", fmt.Errorf("seed source %q: checksum mismatch (expected %s, got %s)", r.location, r.checksum, actual)
+	}
+
+	tree, err := populateTree(root, actual, func(staging string) error {
+		if strings.HasSuffix(strings.ToLower(r.location), ".zip") {
+			return extractZip(data, staging)
+		}
+
+		return extractTarGz(data, staging)
+	})
+	if err != nil {
+		return "", fmt.Errorf("seed source %q: %w", r.location, err)
+	}
+
+	return tree, nil
+}
+
+func (r remote) download() ([]byte, error) {
+	if !isHTTP(r.location) {
+		local, err := path.Expand(strings.TrimPrefix(r.location, "file://"))
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := os.Open(local)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return readArchive(file)
+	}
+
+	client := &http.Client{Timeout: remoteTimeout}
+
+	resp, err := client.Get(r.location)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	data, err := readArchive(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+
+	return data, nil
+}
+
+func readArchive(reader io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, maxArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxArchiveSize {
+		return nil, fmt.Errorf("archive exceeds %d MiB", maxArchiveSize>>20)
+	}
+
+	return data, nil
+}
+
+func lockFetch(root string) (func(), error) {
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create seed cache: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(root, fetchLockName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open seed cache lock: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock seed cache: %w", err)
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// populateTree unpacks a revision into its own directory under root, keyed by
+// id, and never rewrites or deletes a tree another run may still be reading:
+// each run holds a shared lock on the trees it resolved until it exits, and
+// only unlocked superseded trees are removed. Callers hold the fetch lock.
+func populateTree(root, id string, fill func(staging string) error) (string, error) {
+	tree := filepath.Join(root, id)
+
+	if !internalIO.FileExists(tree) {
+		staging, err := os.MkdirTemp(root, stagingPrefix)
+		if err != nil {
+			return "", fmt.Errorf("failed to create seed cache: %w", err)
+		}
+
+		if err := fill(staging); err != nil {
+			os.RemoveAll(staging)
+			return "", err
+		}
+
+		if err := os.Rename(staging, tree); err != nil {
+			os.RemoveAll(staging)
+			return "", fmt.Errorf("failed to populate seed cache: %w", err)
+		}
+	}
+
+	if err := holdTree(tree); err != nil {
+		return "", err
+	}
+
+	if err := pointCurrent(root, id); err != nil {
+		return "", err
+	}
+
+	pruneTrees(root, id)
+
+	return tree, nil
+}
+
+// pointCurrent swaps root/current to the latest revision, giving links into
+// a remote layer a path that survives the revision trees being pruned.
+func pointCurrent(root, id string) error {
+	link := filepath.Join(root, currentName)
+	if target, err := os.Readlink(link); err == nil && target == id {
+		return nil
+	}
+
+	tmp := link + tempSuffix
+	os.Remove(tmp)
+
+	if err := os.Symlink(id, tmp); err != nil {
+		return fmt.Errorf("failed to populate seed cache: %w", err)
+	}
+
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to populate seed cache: %w", err)
+	}
+
+	return nil
+}
+
+// stableCachePath rewrites a path inside a cached revision tree to go through
+// the remote's current link; other paths are returned unchanged.
+func stableCachePath(p string) string {
+	rel, err := filepath.Rel(CacheDir(), p)
+	if err != nil || !filepath.IsLocal(rel) {
+		return p
+	}
+
+	parts := strings.SplitN(rel, string(filepath.Separator), 3)
+	if len(parts) < 2 {
+		return p
+	}
+
+	parts[1] = currentName
+
+	return filepath.Join(append([]string{CacheDir()}, parts...)...)
+}
+
+func holdTree(tree string) error {
+	heldTreesMu.Lock()
+	defer heldTreesMu.Unlock()
+
+	if _, ok := heldTrees[tree]; ok {
+		return nil
+	}
+
+	file, err := os.OpenFile(tree+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open seed cache lock: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to lock seed cache: %w", err)
+	}
+
+	heldTrees[tree] = file
+
+	return nil
+}
+
+func pruneTrees(root, keep string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		switch {
+		case strings.HasPrefix(name, stagingPrefix):
+			os.RemoveAll(filepath.Join(root, name))
+		case entry.IsDir() && name != keep && name != "repo.git":
+			removeUnheldTree(filepath.Join(root, name))
+		}
+	}
+}
+
+func removeUnheldTree(tree string) {
+	heldTreesMu.Lock()
+	_, held := heldTrees[tree]
+	heldTreesMu.Unlock()
+
+	if held {
+		return
+	}
+
+	file, err := os.OpenFile(tree+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) != nil {
+		return
+	}
+
+	if os.RemoveAll(tree) == nil {
+		os.Remove(tree + ".lock")
+	}
+}
+
+type unpackBudget struct {
+	bytes   int64
+	entries int
+}
+
+func (b *unpackBudget) entry() error {
+	b.entries++
+	if b.entries > maxArchiveEntries {
+		return fmt.Errorf("archive has more than %d entries", maxArchiveEntries)
+	}
+
+	return nil
+}
+
+func (b *unpackBudget) copy(dst io.Writer, src io.Reader) error {
+	written, err := io.Copy(dst, io.LimitReader(src, maxUnpackedSize-b.bytes+1))
+	b.bytes += written
+	if err != nil {
+		return err
+	}
+
+	if b.bytes > maxUnpackedSize {
+		return fmt.Errorf("archive unpacks to more than %d MiB", maxUnpackedSize>>20)
+	}
+
+	return nil
+}
+
+func extractTarGz(data []byte, dest string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+	defer gz.Close()
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	budget := &unpackBudget{}
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+
+		if err := budget.entry(); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := extractDir(root, header.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(root, header.Name, header.FileInfo().Mode(), reader, budget); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := extractSymlink(root, header.Name, header.Linkname); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(data []byte, dest string) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	budget := &unpackBudget{}
+	for _, entry := range archive.File {
+		if err := budget.entry(); err != nil {
+			return err
+		}
+
+		if entry.FileInfo().IsDir() {
+			if err := extractDir(root, entry.Name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !entry.Mode().IsRegular() && entry.Mode().Type() != fs.ModeSymlink {
+			continue
+		}
+
+		content, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+
+		if entry.Mode().Type() == fs.ModeSymlink {
+			var target bytes.Buffer
+			if err = budget.copy(&target, content); err == nil {
+				err = extractSymlink(root, entry.Name, target.String())
+			}
+		} else {
+			err = extractFile(root, entry.Name, entry.Mode(), content, budget)
+		}
+
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func archivePath(name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if rel == "." || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid archive entry %q", name)
+	}
+
+	return rel, nil
+}
+
+func extractDir(root *os.Root, name string) error {
+	if strings.Trim(name, "./") == "" {
+		return nil
+	}
+
+	rel, err := archivePath(name)
+	if err != nil {
+		return err
+	}
+
+	return root.MkdirAll(rel, 0o755)
+}
+
+func extractSymlink(root *os.Root, name, target string) error {
+	rel, err := archivePath(name)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(rel); dir != "." {
+		if err := root.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	return root.Symlink(target, rel)
+}
+
+func extractFile(root *os.Root, name string, mode fs.FileMode, content io.Reader, budget *unpackBudget) error {
+	rel, err := archivePath(name)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(rel); dir != "." {
+		if err := root.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	file, err := root.OpenFile(rel, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if err := budget.copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,361 @@
+package seed
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gz)
+
+	for name, content := range files {
+		assert.NilError(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := writer.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+
+	assert.NilError(t, writer.Close())
+	assert.NilError(t, gz.Close())
+
+	return buffer.Bytes()
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		assert.NilError(t, err)
+		_, err = entry.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+
+	assert.NilError(t, writer.Close())
+
+	return buffer.Bytes()
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=seed", "GIT_AUTHOR_EMAIL=seed@example.com",
+		"GIT_COMMITTER_NAME=seed", "GIT_COMMITTER_EMAIL=seed@example.com",
+	)
+
+	out, err := cmd.CombinedOutput()
+	assert.NilError(t, err, string(out))
+
+	return strings.TrimSpace(string(out))
+}
+
+func TestSplitSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		flag     string
+		expected []string
+	}{
+		{"Local", "/mnt/org:/mnt/team", []string{"/mnt/org", "/mnt/team"}},
+		{"URL", "https://example.com/seed.tar.gz:/mnt/team", []string{"https://example.com/seed.tar.gz", "/mnt/team"}},
+		{"SCPLike", "/mnt/org:git@github.com:org/seed.git#v1", []string{"/mnt/org", "git@github.com:org/seed.git#v1"}},
+		{"FileURL", "file:///tmp/seed.zip", []string{"file:///tmp/seed.zip"}},
+		{"Port", "http://localhost:8080/seed.zip:/mnt/team", []string{"http://localhost:8080/seed.zip", "/mnt/team"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, splitSources(tt.flag), tt.expected)
+		})
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		layer    string
+		expected remote
+	}{
+		{"git+https://example.com/org/seed#v1.2.0", remote{kind: remoteGit, location: "https://example.com/org/seed", ref: "v1.2.0"}},
+		{"https://example.com/org/seed.git", remote{kind: remoteGit, location: "https://example.com/org/seed.git"}},
+		{"git@github.com:org/seed#main", remote{kind: remoteGit, location: "git@github.com:org/seed", ref: "main"}},
+		{"/mnt/seed.tgz", remote{kind: remoteArchive, location: "/mnt/seed.tgz"}},
+		{"https://example.com/seed.zip#sha256=" + strings.Repeat("AB", 32), remote{kind: remoteArchive, location: "https://example.com/seed.zip", checksum: strings.Repeat("ab", 32)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layer, func(t *testing.T) {
+			r, isRemote, err := parseRemote(tt.layer)
+
+			assert.NilError(t, err)
+			assert.Assert(t, isRemote)
+			assert.Equal(t, r, tt.expected)
+		})
+	}
+
+	t.Run("Local", func(t *testing.T) {
+		_, isRemote, err := parseRemote("/mnt/seed")
+
+		assert.NilError(t, err)
+		assert.Assert(t, !isRemote)
+	})
+
+	t.Run("MalformedChecksum", func(t *testing.T) {
+		_, _, err := parseRemote("/mnt/seed.zip#md5=abc")
+		assert.ErrorContains(t, err, "expected #sha256=<hex digest>")
+	})
+
+	t.Run("HTTPRequiresChecksum", func(t *testing.T) {
+		_, _, err := parseRemote("http://example.com/seed.tar.gz")
+		assert.ErrorContains(t, err, "need #sha256=<hex digest>")
+	})
+
+	t.Run("GitOptionRejected", func(t *testing.T) {
+		_, _, err := parseRemote("git+--upload-pack=touch /tmp/pwned")
+		assert.ErrorContains(t, err, "must not start with '-'")
+
+		_, _, err = parseRemote("git+https://example.com/org/seed#--upload-pack=x")
+		assert.ErrorContains(t, err, "must not start with '-'")
+	})
+}
+
+func TestResolveRemoteSource(t *testing.T) {
+	t.Run("TarballVerifiedAndCached", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		archive := filepath.Join(t.TempDir(), "seed.tar.gz")
+		data := tarGz(t, map[string]string{"etc/app.conf": "conf\n"})
+		assert.NilError(t, os.WriteFile(archive, data, 0o644))
+
+		layer := archive + "#sha256=" + digest(data)
+
+		resolved, err := ResolveSource(layer)
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(resolved, CacheDir()))
+
+		got, err := os.ReadFile(filepath.Join(resolved, "etc", "app.conf"))
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "conf\n")
+
+		assert.NilError(t, os.Remove(archive))
+
+		cached, err := ResolveSource(layer)
+		assert.NilError(t, err)
+		assert.Equal(t, cached, resolved)
+	})
+
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		archive := filepath.Join(t.TempDir(), "seed.tgz")
+		assert.NilError(t, os.WriteFile(archive, tarGz(t, map[string]string{"a": "a"}), 0o644))
+
+		_, err := ResolveSource("file://" + archive + "#sha256=" + strings.Repeat("0", 64))
+		assert.ErrorContains(t, err, "checksum mismatch")
+	})
+
+	t.Run("ZipOverHTTP", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		data := zipped(t, map[string]string{"home/dev/.bashrc": "rc\n"})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		}))
+		defer server.Close()
+
+		local := t.TempDir()
+		resolved, err := ResolveSource(server.URL + "/seed.zip#sha256=" + digest(data) + string(filepath.ListSeparator) + local)
+		assert.NilError(t, err)
+
+		layers := Layers(resolved)
+		assert.Equal(t, len(layers), 2)
+		assert.Equal(t, layers[1], local)
+
+		got, err := os.ReadFile(filepath.Join(layers[0], "home", "dev", ".bashrc"))
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "rc\n")
+	})
+
+	t.Run("OversizedDownload", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		previous := maxArchiveSize
+		maxArchiveSize = 1 << 20
+		t.Cleanup(func() { maxArchiveSize = previous })
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(make([]byte, 2<<20))
+		}))
+		defer server.Close()
+
+		_, err := ResolveSource(server.URL + "/seed.zip#sha256=" + strings.Repeat("0", 64))
+		assert.ErrorContains(t, err, "archive exceeds 1 MiB")
+	})
+
+	t.Run("OversizedLocalArchive", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		previous := maxArchiveSize
+		maxArchiveSize = 1 << 20
+		t.Cleanup(func() { maxArchiveSize = previous })
+
+		archive := filepath.Join(t.TempDir(), "seed.tgz")
+		assert.NilError(t, os.WriteFile(archive, make([]byte, 2<<20), 0o644))
+
+		_, err := ResolveSource("file://" + archive)
+		assert.ErrorContains(t, err, "archive exceeds 1 MiB")
+	})
+
+	t.Run("UnpackedSizeLimited", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		previous := maxUnpackedSize
+		maxUnpackedSize = 1 << 20
+		t.Cleanup(func() { maxUnpackedSize = previous })
+
+		zeros := strings.Repeat("\x00", 600<<10)
+		archive := filepath.Join(t.TempDir(), "seed.tgz")
+		assert.NilError(t, os.WriteFile(archive, tarGz(t, map[string]string{"a": zeros, "b": zeros}), 0o644))
+
+		_, err := ResolveSource(archive)
+		assert.ErrorContains(t, err, "archive unpacks to more than 1 MiB")
+		assert.Assert(t, len(cacheTrees(t)) == 0)
+	})
+
+	t.Run("EntryCountLimited", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		previous := maxArchiveEntries
+		maxArchiveEntries = 2
+		t.Cleanup(func() { maxArchiveEntries = previous })
+
+		archive := filepath.Join(t.TempDir(), "seed.zip")
+		assert.NilError(t, os.WriteFile(archive, zipped(t, map[string]string{"a": "a", "b": "b", "c": "c"}), 0o644))
+
+		_, err := ResolveSource(archive)
+		assert.ErrorContains(t, err, "archive has more than 2 entries")
+	})
+
+	t.Run("RefetchKeepsTreeInUse", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		archive := filepath.Join(t.TempDir(), "seed.tgz")
+		assert.NilError(t, os.WriteFile(archive, tarGz(t, map[string]string{"file.txt": "v1\n"}), 0o644))
+
+		first, err := ResolveSource(archive)
+		assert.NilError(t, err)
+
+		assert.NilError(t, os.WriteFile(archive, tarGz(t, map[string]string{"file.txt": "v2\n"}), 0o644))
+
+		second, err := ResolveSource(archive)
+		assert.NilError(t, err)
+		assert.Assert(t, first != second)
+		assert.Equal(t, sourceKey(first), sourceKey(second))
+
+		got, err := os.ReadFile(filepath.Join(first, "file.txt"))
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "v1\n")
+	})
+
+	t.Run("RejectsEscapingEntries", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		archive := filepath.Join(t.TempDir(), "seed.zip")
+		assert.NilError(t, os.WriteFile(archive, zipped(t, map[string]string{"../escape": "x"}), 0o644))
+
+		_, err := ResolveSource(archive)
+		assert.ErrorContains(t, err, "invalid archive entry")
+	})
+
+	t.Run("GitRefPinned", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		repo := t.TempDir()
+		gitRun(t, repo, "init", "-q", "-b", "main")
+		write(t, filepath.Join(repo, "file.txt"), "v1\n")
+		gitRun(t, repo, "add", "-A")
+		gitRun(t, repo, "commit", "-qm", "v1")
+		gitRun(t, repo, "tag", "v1")
+		pinned := gitRun(t, repo, "rev-parse", "HEAD")
+		write(t, filepath.Join(repo, "file.txt"), "v2\n")
+		gitRun(t, repo, "commit", "-qam", "v2")
+
+		for ref, expected := range map[string]string{"v1": "v1\n", "main": "v2\n", pinned: "v1\n"} {
+			resolved, err := ResolveSource("git+file://" + repo + "#" + ref)
+			assert.NilError(t, err)
+
+			got, err := os.ReadFile(filepath.Join(resolved, "file.txt"))
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), expected, ref)
+			assert.Assert(t, !fileExists(filepath.Join(resolved, ".git")))
+		}
+	})
+
+	t.Run("UnreachableGitFallsBackToCache", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		repo := filepath.Join(t.TempDir(), "repo")
+		gitRun(t, t.TempDir(), "init", "-q", "-b", "main", repo)
+		write(t, filepath.Join(repo, "file.txt"), "v1\n")
+		gitRun(t, repo, "add", "-A")
+		gitRun(t, repo, "commit", "-qm", "v1")
+		gitRun(t, repo, "tag", "v1")
+		write(t, filepath.Join(repo, "file.txt"), "v2\n")
+		gitRun(t, repo, "commit", "-qam", "v2")
+
+		main, err := ResolveSource("git+file://" + repo + "#main")
+		assert.NilError(t, err)
+		_, err = ResolveSource("git+file://" + repo + "#v1")
+		assert.NilError(t, err)
+
+		assert.NilError(t, os.Rename(repo, repo+".moved"))
+
+		var warnings strings.Builder
+		original := remoteWarningWriter
+		remoteWarningWriter = &warnings
+		t.Cleanup(func() { remoteWarningWriter = original })
+
+		resolved, err := ResolveSource("git+file://" + repo + "#main")
+		assert.NilError(t, err)
+		assert.Equal(t, resolved, main)
+		assert.Equal(t, readFile(t, filepath.Join(resolved, "file.txt")), "v2\n")
+		assert.Assert(t, strings.Contains(warnings.String(), "using cached revision"), warnings.String())
+
+		_, err = ResolveSource("git+file://" + repo + "#never-fetched")
+		assert.ErrorContains(t, err, "git fetch")
+	})
+
+	t.Run("RotateRejectsRemote", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+
+		_, err := ResolveLocalSource("https://example.com/seed.tar.gz#sha256=" + strings.Repeat("0", 64))
+		assert.ErrorContains(t, err, "is remote")
+	})
+}
+
+func cacheTrees(t *testing.T) []string {
+	t.Helper()
+
+	var trees []string
+	roots, _ := filepath.Glob(filepath.Join(CacheDir(), "*", "*"))
+	for _, root := range roots {
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			trees = append(trees, root)
+		}
+	}
+
+	return trees
+}
@@ -0,0 +1,241 @@
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+type Output string
+
+const (
+	OutputText   Output = "text"
+	OutputJSON   Output = "json"
+	OutputNDJSON Output = "ndjson"
+)
+
+type Result string
+
+const (
+	ResultSeeded    Result = "seeded"
+	ResultUnchanged Result = "unchanged"
+	ResultRemoved   Result = "removed"
+	ResultPlanned   Result = "planned"
+	ResultSkipped   Result = "skipped"
+	ResultFailed    Result = "failed"
+)
+
+type HookReport struct {
+	Kind    HookKind `json:"kind"`
+	Command string   `json:"command,omitempty"`
+	Output  string   `json:"output,omitempty"`
+}
+
+type EntryReport struct {
+	Type     string       `json:"type,omitempty"`
+	Dest     string       `json:"dest"`
+	Op       Op           `json:"op"`
+	Result   Result       `json:"result"`
+	Reason   string       `json:"reason,omitempty"`
+	Mode     string       `json:"mode,omitempty"`
+	Secret   bool         `json:"secret"`
+	Template bool         `json:"template"`
+	Change   string       `json:"change,omitempty"`
+	Diff     string       `json:"diff,omitempty"`
+	Redacted bool         `json:"redacted,omitempty"`
+	Hooks    []HookReport `json:"hooks,omitempty"`
+}
+
+type SummaryReport struct {
+	Type      string   `json:"type,omitempty"`
+	DryRun    bool     `json:"dryRun"`
+	Total     int      `json:"total"`
+	Seeded    int      `json:"seeded"`
+	Unchanged int      `json:"unchanged"`
+	Removed   int      `json:"removed"`
+	Planned   int      `json:"planned"`
+	Skipped   int      `json:"skipped"`
+	Failed    int      `json:"failed"`
+	Warnings  []string `json:"warnings,omitempty"`
+	Backup    string   `json:"backup,omitempty"`
+}
+
+type jsonReport struct {
+	out     io.Writer
+	stream  bool
+	current *EntryReport
+	entries []EntryReport
+	summary SummaryReport
+}
+
+func ParseOutput(value string) (Output, error) {
+	switch output := Output(value); output {
+	case "", OutputText:
+		return OutputText, nil
+	case OutputJSON, OutputNDJSON:
+		return output, nil
+	}
+
+	return "", fmt.Errorf("unknown output %q (expected text, json or ndjson)", value)
+}
+
+func newReporter(out io.Writer, styled bool, output Output, dryRun bool) reporter {
+	if output == "" || output == OutputText {
+		return reporter{out: out, styled: styled}
+	}
+
+	return reporter{out: out, report: &jsonReport{
+		out:     out,
+		stream:  output == OutputNDJSON,
+		summary: SummaryReport{DryRun: dryRun},
+	}}
+}
+
+func (r reporter) fork(out io.Writer) reporter {
+	child := reporter{out: out, styled: r.styled}
+	if r.report != nil {
+		child.report = &jsonReport{
+			out:     out,
+			stream:  r.report.stream,
+			summary: SummaryReport{DryRun: r.report.summary.DryRun},
+		}
+	}
+
+	return child
+}
+
+func (r reporter) join(child reporter, output []byte) {
+	r.out.Write(output)
+
+	if r.report == nil || child.report == nil {
+		return
+	}
+
+	r.report.entries = append(r.report.entries, child.report.entries...)
+	r.report.summary.add(child.report.summary)
+}
+
+func (s *SummaryReport) add(other SummaryReport) {
+	s.Total += other.Total
+	s.Seeded += other.Seeded
+	s.Unchanged += other.Unchanged
+	s.Removed += other.Removed
+	s.Planned += other.Planned
+	s.Skipped += other.Skipped
+	s.Failed += other.Failed
+	s.Warnings = append(s.Warnings, other.Warnings...)
+}
+
+func (r reporter) begin(op ResolvedOp) {
+	if r.report == nil {
+		return
+	}
+
+	r.report.current = &EntryReport{
+		Dest:     op.Dest,
+		Op:       op.Op,
+		Secret:   op.Secret,
+		Template: op.Template,
+	}
+}
+
+func (r reporter) secret(secret bool) {
+	if r.report != nil {
+		r.report.update(func(entry *EntryReport) { entry.Secret = secret })
+	}
+}
+
+func (r reporter) finish(err error) {
+	j := r.report
+	if j == nil || j.current == nil {
+		return
+	}
+
+	entry := *j.current
+	j.current = nil
+
+	if err != nil {
+		entry.Result = ResultFailed
+		if entry.Reason == "" {
+			entry.Reason = err.Error()
+		}
+	}
+
+	if info, statErr := os.Lstat(entry.Dest); statErr == nil && entry.Mode == "" {
+		entry.Mode = formatMode(info.Mode())
+	}
+
+	j.summary.Total++
+	switch entry.Result {
+	case ResultSeeded:
+		j.summary.Seeded++
+	case ResultUnchanged:
+		j.summary.Unchanged++
+	case ResultRemoved:
+		j.summary.Removed++
+	case ResultPlanned:
+		j.summary.Planned++
+	case ResultSkipped:
+		j.summary.Skipped++
+	case ResultFailed:
+		j.summary.Failed++
+	}
+
+	if j.stream {
+		entry.Type = "entry"
+		j.encode(entry)
+		return
+	}
+
+	j.entries = append(j.entries, entry)
+}
+
+func (r reporter) close() {
+	j := r.report
+	if j == nil {
+		return
+	}
+
+	if j.stream {
+		j.summary.Type = "summary"
+		j.encode(j.summary)
+		return
+	}
+
+	entries := j.entries
+	if entries == nil {
+		entries = []EntryReport{}
+	}
+
+	j.encode(struct {
+		Entries []EntryReport `json:"entries"`
+		Summary SummaryReport `json:"summary"`
+	}{entries, j.summary})
+}
+
+func (j *jsonReport) encode(value any) {
+	encoder := json.NewEncoder(j.out)
+	encoder.SetEscapeHTML(false)
+
+	if !j.stream {
+		encoder.SetIndent("", "  ")
+	}
+
+	encoder.Encode(value)
+}
+
+func (j *jsonReport) record(result Result, reason string) {
+	if j.current == nil {
+		return
+	}
+
+	j.current.Result = result
+	j.current.Reason = reason
+}
+
+func (j *jsonReport) update(apply func(*EntryReport)) {
+	if j.current != nil {
+		apply(j.current)
+	}
+}
@@ -0,0 +1,188 @@
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type jsonDocument struct {
+	Entries []EntryReport `json:"entries"`
+	Summary SummaryReport `json:"summary"`
+}
+
+func TestParseOutput(t *testing.T) {
+	for _, value := range []string{"", "text", "json", "ndjson"} {
+		_, err := ParseOutput(value)
+		assert.NilError(t, err)
+	}
+
+	_, err := ParseOutput("yaml")
+	assert.ErrorContains(t, err, `unknown output "yaml"`)
+}
+
+func TestApplyJSON(t *testing.T) {
+	t.Run("Results", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		seeded := filepath.Join(target, "seeded.txt")
+		kept := filepath.Join(target, "kept.txt")
+		missing := filepath.Join(target, "missing.txt")
+
+		write(t, rhyming(source, seeded), "new\n")
+		write(t, rhyming(source, kept), "new\n")
+		write(t, kept, "mine\n")
+		writeManifest(t, source, fmt.Sprintf(
+			"seeds:\n  %s:\n    template: true\n    mode: \"0o600\"\n  %s:\n    secret: true\n",
+			seeded, missing,
+		))
+
+		var buffer strings.Builder
+		err := Apply(Options{Source: source, Out: &buffer, Output: OutputJSON})
+		assert.ErrorContains(t, err, "1 seed entry failed to apply")
+
+		var document jsonDocument
+		assert.NilError(t, json.Unmarshal([]byte(buffer.String()), &document))
+
+		results := map[string]EntryReport{}
+		for _, entry := range document.Entries {
+			results[entry.Dest] = entry
+		}
+
+		assert.Equal(t, results[seeded].Result, ResultSeeded)
+		assert.Equal(t, results[seeded].Mode, "0o600")
+		assert.Assert(t, results[seeded].Template)
+		assert.Equal(t, results[kept].Result, ResultSkipped)
+		assert.Equal(t, results[kept].Reason, "exists")
+		assert.Equal(t, results[missing].Result, ResultFailed)
+		assert.Equal(t, results[missing].Reason, "no source available")
+		assert.Assert(t, results[missing].Secret)
+
+		assert.Equal(t, document.Summary.Total, 3)
+		assert.Equal(t, document.Summary.Seeded, 1)
+		assert.Equal(t, document.Summary.Skipped, 1)
+		assert.Equal(t, document.Summary.Failed, 1)
+	})
+
+	t.Run("TemplateReferencingSecret", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "app.env")
+
+		write(t, rhyming(source, dest), "TOKEN=${secrets.TOKEN}\n")
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  TOKEN: %s\nseeds:\n  %s:\n    template: true\n",
+			encrypt(t, "s3cr3t", testMaster), dest,
+		))
+
+		var buffer strings.Builder
+		assert.NilError(t, Apply(Options{Source: source, MasterKey: testMaster, DryRun: true, Out: &buffer, Output: OutputJSON}))
+
+		var document jsonDocument
+		assert.NilError(t, json.Unmarshal([]byte(buffer.String()), &document))
+
+		assert.Equal(t, len(document.Entries), 1)
+		assert.Assert(t, document.Entries[0].Secret)
+		assert.Assert(t, document.Entries[0].Redacted)
+		assert.Assert(t, !strings.Contains(buffer.String(), "s3cr3t"))
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "link")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: symlink\n    target: /etc/hosts\n", dest))
+		apply(t, Options{Source: source})
+
+		var buffer strings.Builder
+		assert.NilError(t, Apply(Options{Source: source, Out: &buffer, Output: OutputJSON}))
+
+		var document jsonDocument
+		assert.NilError(t, json.Unmarshal([]byte(buffer.String()), &document))
+
+		assert.Equal(t, document.Entries[0].Result, ResultUnchanged)
+		assert.Equal(t, document.Summary.Unchanged, 1)
+	})
+
+	t.Run("HookOutput", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		write(t, rhyming(source, dest), "x\n")
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    onChange: echo reloaded\n", dest))
+
+		var buffer strings.Builder
+		assert.NilError(t, Apply(Options{Source: source, Out: &buffer, Output: OutputJSON}))
+
+		var document jsonDocument
+		assert.NilError(t, json.Unmarshal([]byte(buffer.String()), &document))
+
+		hooks := document.Entries[0].Hooks
+		assert.Equal(t, len(hooks), 1)
+		assert.Equal(t, hooks[0].Kind, HookOnChange)
+		assert.Equal(t, hooks[0].Output, "reloaded\n")
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		write(t, rhyming(source, dest), "x\n")
+		writeManifest(t, source, "")
+
+		var buffer strings.Builder
+		assert.NilError(t, Apply(Options{Source: source, DryRun: true, Out: &buffer, Output: OutputJSON}))
+
+		var document jsonDocument
+		assert.NilError(t, json.Unmarshal([]byte(buffer.String()), &document))
+
+		assert.Equal(t, document.Entries[0].Result, ResultPlanned)
+		assert.Equal(t, document.Entries[0].Change, "create")
+		assert.Assert(t, strings.Contains(document.Entries[0].Diff, "+x"))
+		assert.Assert(t, document.Summary.DryRun)
+		assert.Equal(t, document.Summary.Planned, 1)
+		assert.Assert(t, !fileExists(dest))
+	})
+}
+
+func TestApplyNDJSON(t *testing.T) {
+	setEnv(t, t.TempDir())
+	source := t.TempDir()
+	target := t.TempDir()
+	first := filepath.Join(target, "a.txt")
+	second := filepath.Join(target, "b.txt")
+
+	write(t, rhyming(source, first), "a\n")
+	write(t, rhyming(source, second), "b\n")
+	writeManifest(t, source, "")
+
+	var buffer strings.Builder
+	assert.NilError(t, Apply(Options{Source: source, Out: &buffer, Output: OutputNDJSON}))
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Equal(t, len(lines), 3)
+
+	for _, line := range lines[:2] {
+		var entry EntryReport
+		assert.NilError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, entry.Type, "entry")
+		assert.Equal(t, entry.Result, ResultSeeded)
+	}
+
+	var summary SummaryReport
+	assert.NilError(t, json.Unmarshal([]byte(lines[2]), &summary))
+	assert.Equal(t, summary.Type, "summary")
+	assert.Equal(t, summary.Seeded, 2)
+}
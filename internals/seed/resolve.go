@@ -2,15 +2,17 @@ package seed
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/kloudkit/ws-cli/internals/config"
 	"github.com/kloudkit/ws-cli/internals/env"
-	internalIO "github.com/kloudkit/ws-cli/internals/io"
 	"github.com/kloudkit/ws-cli/internals/path"
 )
 
@@ -18,25 +20,39 @@ type Vars struct {
 	Home       string
 	User       string
 	ServerRoot string
+	SSH        bool
 }
 
 type ResolvedOp struct {
-	Dest     string
-	Source   string
-	Content  *string
-	Mode     string
-	Secret   bool
-	Op       Op
-	Template bool
-	Force    bool
-	Comment  string
+	Key         string
+	Dest        string
+	Source      string
+	Content     *string
+	Mode        string
+	Secret      bool
+	Op          Op
+	Template    bool
+	Force       bool
+	Comment     string
+	State       Presence
+	Merge       MergeSpec
+	Format      Format
+	Selector    Selector
+	Hooks       Hooks
+	Target      string
+	Empty       bool
+	Layer       string
+	Base        *ResolvedOp
+	HookTimeout time.Duration
 }
 
 type Plan struct {
 	Source   string
+	Layers   []string
 	Vars     Vars
-	Manifest *Manifest
+	Secrets  map[string]string
 	Ops      []ResolvedOp
+	Excluded []ResolvedOp
 }
 
 func resolveVars() Vars {
@@ -51,6 +67,7 @@ func resolveVars() Vars {
 		Home:       env.Home(),
 		User:       username,
 		ServerRoot: serverRoot,
+		SSH:        env.IsSSHSession(),
 	}
 }
 
@@ -65,6 +82,14 @@ func (v Vars) expand(value string) (string, error) {
 }
 
 func ResolveSource(flag string) (string, error) {
+	return resolveSource(flag, true)
+}
+
+func ResolveLocalSource(flag string) (string, error) {
+	return resolveSource(flag, false)
+}
+
+func resolveSource(flag string, fetch bool) (string, error) {
 	if flag == "" {
 		resolved, err := config.Resolve("seed", "source")
 		if err != nil {
@@ -74,28 +99,67 @@ func ResolveSource(flag string) (string, error) {
 		flag = resolved
 	}
 
-	return path.Expand(flag)
+	layers := splitSources(flag)
+	for i, layer := range layers {
+		r, isRemote, err := parseRemote(layer)
+		if err != nil {
+			return "", err
+		}
+
+		if isRemote {
+			if !fetch {
+				return "", fmt.Errorf("seed source %q is remote; use a local checkout", r.location)
+			}
+
+			if layers[i], err = r.fetch(CacheDir()); err != nil {
+				return "", err
+			}
+
+			continue
+		}
+
+		if layers[i], err = path.Expand(layer); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(layers, string(filepath.ListSeparator)), nil
 }
 
 func BuildPlan(source string, force bool) (*Plan, error) {
-	vars := resolveVars()
+	plan := &Plan{Source: source, Layers: Layers(source), Vars: resolveVars(), Secrets: map[string]string{}}
+	stacked := map[string]ResolvedOp{}
+
+	for _, layer := range plan.Layers {
+		manifest, err := loadLayerManifest(layer)
+		if err != nil {
+			return nil, err
+		}
 
-	var manifest *Manifest
-	if manifestPath := ManifestPath(source); internalIO.FileExists(manifestPath) {
-		loaded, err := LoadManifest(manifestPath)
+		ops, err := buildPlan(layer, manifest, plan.Vars, force)
 		if err != nil {
 			return nil, err
 		}
 
-		manifest = loaded
+		if manifest != nil {
+			maps.Copy(plan.Secrets, manifest.Secrets)
+		}
+
+		for _, op := range ops {
+			if below, ok := stacked[op.Dest]; ok {
+				op.Base = &below
+			}
+
+			stacked[op.Dest] = op
+		}
 	}
 
-	ops, err := buildPlan(source, manifest, vars, force)
-	if err != nil {
-		return nil, err
+	dests := slices.Sorted(maps.Keys(stacked))
+	for _, dest := range dests {
+		plan.Ops = append(plan.Ops, stacked[dest])
 	}
 
-	return &Plan{Source: source, Vars: vars, Manifest: manifest, Ops: ops}, nil
+	return plan, nil
 }
 
 func buildPlan(source string, manifest *Manifest, vars Vars, force bool) ([]ResolvedOp, error) {
@@ -106,8 +170,33 @@ func buildPlan(source string, manifest *Manifest, vars Vars, force bool) ([]Reso
 		return nil, err
 	}
 
+	subtrees := map[string]Selector{}
+	if manifest != nil {
+		for rawPrefix, selector := range manifest.Mirror {
+			prefix, err := vars.expand(rawPrefix)
+			if err != nil {
+				return nil, fmt.Errorf("mirror %q: %w", rawPrefix, err)
+			}
+
+			subtrees[filepath.Clean(prefix)] = selector
+		}
+	}
+
 	for dest, src := range mirror {
-		plan[dest] = ResolvedOp{Dest: dest, Source: src, Op: OpCopy, Force: force}
+		resolved := ResolvedOp{
+			Dest:     dest,
+			Source:   src,
+			Op:       OpCopy,
+			Force:    force,
+			Selector: subtreeSelector(subtrees, dest),
+			Layer:    source,
+		}
+
+		if target, err := os.Readlink(src); err == nil {
+			resolved.Op, resolved.Target = OpSymlink, target
+		}
+
+		plan[dest] = resolved
 	}
 
 	if manifest != nil {
@@ -118,6 +207,7 @@ func buildPlan(source string, manifest *Manifest, vars Vars, force bool) ([]Reso
 			}
 
 			resolved := ResolvedOp{
+				Key:      rawDest,
 				Dest:     dest,
 				Content:  op.Content,
 				Mode:     op.Mode,
@@ -126,6 +216,26 @@ func buildPlan(source string, manifest *Manifest, vars Vars, force bool) ([]Reso
 				Template: op.Template,
 				Force:    force || op.Force,
 				Comment:  op.Comment,
+				State:    op.State,
+				Format:   op.Format,
+				Selector: op.Selector,
+				Hooks:    op.Hooks,
+				Empty:    op.Empty,
+				Layer:    source,
+			}
+
+			if op.Target != "" {
+				if resolved.Target, err = vars.expand(op.Target); err != nil {
+					return nil, fmt.Errorf("seed %q: %w", rawDest, err)
+				}
+			}
+
+			if resolved.Selector.empty() {
+				resolved.Selector = subtreeSelector(subtrees, dest)
+			}
+
+			if op.Merge != nil {
+				resolved.Merge = *op.Merge
 			}
 
 			if op.Content == nil {
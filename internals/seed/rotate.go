@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
 	"github.com/kloudkit/ws-cli/internals/secrets"
 	"github.com/kloudkit/ws-cli/internals/styles"
 	"gopkg.in/yaml.v3"
@@ -23,16 +27,25 @@ type RotateOptions struct {
 	Source       string
 	MasterKey    string
 	NewMasterKey string
+	Only         []string
+	Upgrade      bool
+	DryRun       bool
+	Resume       bool
 	Out          io.Writer
 	Styled       bool
 }
 
 type rotateTarget struct {
-	describe  string
-	cipher    string
-	plain     []byte
-	writePath string
-	writeBack func(string) error
+	describe     string
+	section      string
+	key          string
+	dest         string
+	cipher       string
+	plain        []byte
+	rotated      string
+	writePath    string
+	manifestPath string
+	writeBack    func(string) error
 }
 
 type rotateReporter struct {
@@ -49,43 +62,70 @@ func (r rotateReporter) rotated(describe string) {
 	fmt.Fprintf(r.out, "Rotated %s\n", describe)
 }
 
+func (r rotateReporter) wouldRotate(describe string) {
+	if r.styled {
+		styles.PrintWarning(r.out, fmt.Sprintf("Would rotate %s", describe))
+		return
+	}
+
+	fmt.Fprintf(r.out, "Would rotate %s\n", describe)
+}
+
 func Rotate(opts RotateOptions) error {
-	if opts.NewMasterKey == "" {
-		return fmt.Errorf("a new master key is required (use --new-master)")
+	rep := rotateReporter{out: opts.Out, styled: opts.Styled}
+
+	if opts.Resume {
+		return resumeRotate(opts, rep)
 	}
 
-	manifestPath := ManifestPath(opts.Source)
-	raw, err := os.ReadFile(manifestPath)
-	if err != nil {
-		return fmt.Errorf("failed to read manifest %q: %w", manifestPath, err)
+	if opts.Upgrade {
+		if opts.NewMasterKey != "" {
+			return fmt.Errorf("--upgrade keeps the current master key and cannot be combined with --new-master")
+		}
+
+		opts.NewMasterKey = opts.MasterKey
+	} else if opts.NewMasterKey == "" && !opts.DryRun {
+		return fmt.Errorf("a new master key is required (use --new-master)")
 	}
 
-	manifest, err := ParseManifest(raw)
-	if err != nil {
-		return err
+	if !opts.DryRun {
+		unlock, err := acquireLock(reporter{out: opts.Out, styled: opts.Styled})
+		if err != nil {
+			return err
+		}
+		defer unlock()
 	}
 
-	var doc yaml.Node
-	if err := yaml.Unmarshal(raw, &doc); err != nil {
-		return fmt.Errorf("failed to parse manifest: %w", err)
+	if pending, err := loadJournal(opts.Source); err != nil {
+		return err
+	} else if pending != nil {
+		return fmt.Errorf("an interrupted rotation of %s was found (use --resume to complete it)", opts.Source)
 	}
 
-	oldKey, err := secrets.ResolveMasterKey(opts.MasterKey)
+	targets, docs, err := collectLayers(Layers(opts.Source))
 	if err != nil {
 		return err
 	}
-	defer zeroBytes(oldKey)
 
-	newKey, err := secrets.ResolveMasterKey(opts.NewMasterKey)
-	if err != nil {
+	if targets, err = selectTargets(targets, opts.Only); err != nil {
 		return err
 	}
-	defer zeroBytes(newKey)
 
-	targets, err := collectTargets(opts.Source, manifest, &doc)
+	targets = slices.DeleteFunc(targets, func(target rotateTarget) bool {
+		return secrets.IsRecipientEncrypted(target.cipher)
+	})
+
+	if opts.Upgrade {
+		targets = slices.DeleteFunc(targets, func(target rotateTarget) bool {
+			return !secrets.NeedsUpgrade(target.cipher)
+		})
+	}
+
+	oldKey, err := secrets.ResolveMasterKey(opts.MasterKey)
 	if err != nil {
 		return err
 	}
+	defer zeroBytes(oldKey)
 
 	defer func() {
 		for i := range targets {
@@ -94,7 +134,7 @@ func Rotate(opts RotateOptions) error {
 	}()
 
 	for i := range targets {
-		plain, err := secrets.Decrypt(secrets.NormalizeEncrypted(targets[i].cipher), oldKey)
+		plain, err := secrets.DecryptContext(secrets.NormalizeEncrypted(targets[i].cipher), oldKey, targets[i].context())
 		if err != nil {
 			return fmt.Errorf("%s: decrypt failed (wrong current key?)", targets[i].describe)
 		}
@@ -102,36 +142,77 @@ func Rotate(opts RotateOptions) error {
 		targets[i].plain = plain
 	}
 
-	writeManifest := false
+	if opts.DryRun {
+		for i := range targets {
+			rep.wouldRotate(targets[i].describe)
+		}
+
+		return nil
+	}
+
+	newKey, err := secrets.ResolveMasterKey(opts.NewMasterKey)
+	if err != nil {
+		return err
+	}
+	defer zeroBytes(newKey)
+
+	manifests := map[string]bool{}
 	for i := range targets {
 		if targets[i].writePath == "" {
-			writeManifest = true
-			break
+			manifests[targets[i].manifestPath] = true
 		}
 	}
 
-	if err := preflightWritable(targets, manifestPath, writeManifest); err != nil {
+	if err := preflightWritable(targets, manifests); err != nil {
 		return err
 	}
 
 	for i := range targets {
-		reencrypted, err := secrets.Encrypt(targets[i].plain, newKey)
-		if err != nil {
+		if targets[i].rotated, err = targets[i].encrypt(newKey); err != nil {
 			return fmt.Errorf("%s: re-encrypt failed", targets[i].describe)
 		}
+	}
 
-		if err := targets[i].writeBack(reencrypted); err != nil {
+	if err := saveJournal(opts.Source, targets); err != nil {
+		return err
+	}
+
+	return finishRotate(opts.Source, targets, docs, manifests, rep)
+}
+
+func (t rotateTarget) context() string {
+	if t.section == "secrets" {
+		return secretContext(t.key)
+	}
+
+	return seedContext(t.key)
+}
+
+func (t rotateTarget) encrypt(key []byte) (string, error) {
+	if secrets.IsBound(secrets.NormalizeEncrypted(t.cipher)) {
+		return secrets.EncryptContext(t.plain, key, t.context())
+	}
+
+	return secrets.Encrypt(t.plain, key)
+}
+
+func finishRotate(source string, targets []rotateTarget, docs map[string]*yaml.Node, manifests map[string]bool, rep rotateReporter) error {
+	for i := range targets {
+		if err := targets[i].writeBack(targets[i].rotated); err != nil {
 			return fmt.Errorf("%s: %w", targets[i].describe, err)
 		}
 	}
 
-	if writeManifest {
-		if err := writeManifestFile(manifestPath, &doc); err != nil {
+	for _, manifestPath := range slices.Sorted(maps.Keys(manifests)) {
+		if err := writeManifestFile(manifestPath, docs[manifestPath]); err != nil {
 			return err
 		}
 	}
 
-	rep := rotateReporter{out: opts.Out, styled: opts.Styled}
+	if err := removeJournal(source); err != nil {
+		return err
+	}
+
 	for i := range targets {
 		rep.rotated(targets[i].describe)
 	}
@@ -139,6 +220,90 @@ func Rotate(opts RotateOptions) error {
 	return nil
 }
 
+func selectTargets(targets []rotateTarget, only []string) ([]rotateTarget, error) {
+	if len(only) == 0 {
+		return targets, nil
+	}
+
+	vars := resolveVars()
+
+	var selected []rotateTarget
+	for _, arg := range only {
+		dest, err := vars.expand(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		for _, target := range targets {
+			if target.key != arg && (target.dest == "" || target.dest != dest) {
+				continue
+			}
+
+			matched = true
+			if !slices.ContainsFunc(selected, func(existing rotateTarget) bool {
+				return existing.manifestPath == target.manifestPath && existing.section == target.section && existing.key == target.key
+			}) {
+				selected = append(selected, target)
+			}
+		}
+
+		if !matched {
+			return nil, fmt.Errorf("no secret or secret seed matches %q", arg)
+		}
+	}
+
+	return selected, nil
+}
+
+func collectLayers(layers []string) ([]rotateTarget, map[string]*yaml.Node, error) {
+	var targets []rotateTarget
+	docs := map[string]*yaml.Node{}
+
+	for _, layer := range layers {
+		manifestPath := ManifestPath(layer)
+		if len(layers) > 1 && !internalIO.FileExists(manifestPath) {
+			continue
+		}
+
+		raw, err := os.ReadFile(manifestPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read manifest %q: %w", manifestPath, err)
+		}
+
+		manifest, err := ParseManifest(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+
+		layerTargets, err := collectTargets(layer, manifest, &doc)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range layerTargets {
+			layerTargets[i].manifestPath = manifestPath
+			if len(layers) > 1 {
+				layerTargets[i].describe = fmt.Sprintf("%s in %s", layerTargets[i].describe, layer)
+			}
+		}
+
+		targets = append(targets, layerTargets...)
+		docs[manifestPath] = &doc
+	}
+
+	if len(docs) == 0 {
+		return nil, nil, fmt.Errorf("no manifest found in %s", strings.Join(layers, ", "))
+	}
+
+	return targets, docs, nil
+}
+
 func collectTargets(source string, manifest *Manifest, doc *yaml.Node) ([]rotateTarget, error) {
 	root := documentRoot(doc)
 	vars := resolveVars()
@@ -155,6 +320,7 @@ func collectTargets(source string, manifest *Manifest, doc *yaml.Node) ([]rotate
 			return nil, err
 		}
 
+		target.section, target.key = "secrets", name
 		targets = append(targets, target)
 	}
 
@@ -164,30 +330,26 @@ func collectTargets(source string, manifest *Manifest, doc *yaml.Node) ([]rotate
 			continue
 		}
 
+		dest, err := vars.expand(rawDest)
+		if err != nil {
+			return nil, fmt.Errorf("seed %q: %w", rawDest, err)
+		}
+
+		var target rotateTarget
 		if op.Content != nil {
-			target, err := valueTarget(
+			target, err = valueTarget(
 				fmt.Sprintf("seed %q", rawDest),
 				*op.Content,
 				seedContentSetter(root, rawDest),
 			)
-			if err != nil {
-				return nil, err
-			}
-
-			targets = append(targets, target)
-			continue
+		} else {
+			target, err = fileTarget(fmt.Sprintf("seed %q", rawDest), rhymingSource(source, dest))
 		}
-
-		dest, err := vars.expand(rawDest)
-		if err != nil {
-			return nil, fmt.Errorf("seed %q: %w", rawDest, err)
-		}
-
-		target, err := fileTarget(fmt.Sprintf("seed %q", rawDest), rhymingSource(source, dest))
 		if err != nil {
 			return nil, err
 		}
 
+		target.section, target.key, target.dest = "seeds", rawDest, dest
 		targets = append(targets, target)
 	}
 
@@ -268,7 +430,7 @@ func seedContentSetter(root *yaml.Node, dest string) func(string) error {
 	}
 }
 
-func preflightWritable(targets []rotateTarget, manifestPath string, writeManifest bool) error {
+func preflightWritable(targets []rotateTarget, manifests map[string]bool) error {
 	dirs := map[string]bool{}
 	for i := range targets {
 		if targets[i].writePath != "" {
@@ -276,7 +438,7 @@ func preflightWritable(targets []rotateTarget, manifestPath string, writeManifes
 		}
 	}
 
-	if writeManifest {
+	for manifestPath := range manifests {
 		dirs[filepath.Dir(manifestPath)] = true
 	}
 
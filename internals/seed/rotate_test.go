@@ -117,6 +117,29 @@ func TestRotate(t *testing.T) {
 		failsToDecrypt(t, *manifest.Seeds[dest].Content, testMaster)
 	})
 
+	t.Run("PreservesContextBinding", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  BOUND: %s\n  LOOSE: %s\n",
+			encryptBound(t, "B", testMaster, "secrets.BOUND"), encrypt(t, "L", testMaster),
+		))
+
+		rotate(t, RotateOptions{Source: source, MasterKey: testMaster, NewMasterKey: testNewMaster})
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		assert.Assert(t, secrets.IsBound(manifest.Secrets["BOUND"]))
+		assert.Assert(t, !secrets.IsBound(manifest.Secrets["LOOSE"]))
+		decrypts(t, manifest.Secrets["LOOSE"], testNewMaster, "L")
+
+		master, err := secrets.ResolveMasterKey(testNewMaster)
+		assert.NilError(t, err)
+		plain, err := secrets.DecryptContext(manifest.Secrets["BOUND"], master, "secrets.BOUND")
+		assert.NilError(t, err)
+		assert.Equal(t, string(plain), "B")
+	})
+
 	t.Run("PreservesCommentsAndKeyOrder", func(t *testing.T) {
 		setEnv(t, t.TempDir())
 		source := t.TempDir()
@@ -225,4 +248,182 @@ func TestRotate(t *testing.T) {
 		assert.Assert(t, manifest.Secrets["TOK"] != original)
 		decrypts(t, manifest.Secrets["TOK"], testMaster, "V")
 	})
+
+	t.Run("DryRunReportsWithoutWriting", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "id_key")
+		write(t, rhyming(source, dest), encrypt(t, "MIRROR\n", testMaster))
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  TOK: %s\nseeds:\n  %s:\n    secret: true\n",
+			encrypt(t, "V", testMaster), dest,
+		))
+		manifestBefore := readFile(t, ManifestPath(source))
+		mirrorBefore := readFile(t, rhyming(source, dest))
+
+		output := rotate(t, RotateOptions{Source: source, MasterKey: testMaster, DryRun: true})
+
+		assert.Assert(t, strings.Contains(output, `Would rotate secret "TOK"`))
+		assert.Assert(t, strings.Contains(output, fmt.Sprintf("Would rotate seed %q", dest)))
+		assert.Equal(t, readFile(t, ManifestPath(source)), manifestBefore)
+		assert.Equal(t, readFile(t, rhyming(source, dest)), mirrorBefore)
+	})
+
+	t.Run("DryRunWrongKeyFails", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "V", testMaster)))
+
+		rotateErr(t, RotateOptions{Source: source, MasterKey: "a-totally-different-master-key-9999", DryRun: true})
+	})
+
+	t.Run("RotatesSubset", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		picked := filepath.Join(target, "picked")
+		skipped := filepath.Join(target, "skipped")
+		write(t, rhyming(source, picked), encrypt(t, "PICKED\n", testMaster))
+		write(t, rhyming(source, skipped), encrypt(t, "SKIPPED\n", testMaster))
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  TOK: %s\n  OTHER: %s\nseeds:\n  %s:\n    secret: true\n  %s:\n    secret: true\n",
+			encrypt(t, "T", testMaster), encrypt(t, "O", testMaster), picked, skipped,
+		))
+
+		output := rotate(t, RotateOptions{
+			Source:       source,
+			MasterKey:    testMaster,
+			NewMasterKey: testNewMaster,
+			Only:         []string{"TOK", picked},
+		})
+
+		assert.Equal(t, strings.Count(output, "Rotated"), 2)
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		decrypts(t, manifest.Secrets["TOK"], testNewMaster, "T")
+		decrypts(t, manifest.Secrets["OTHER"], testMaster, "O")
+		decrypts(t, readFile(t, rhyming(source, picked)), testNewMaster, "PICKED\n")
+		decrypts(t, readFile(t, rhyming(source, skipped)), testMaster, "SKIPPED\n")
+	})
+
+	t.Run("UnknownSubsetFails", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "V", testMaster)))
+
+		var buffer bytes.Buffer
+		err := Rotate(RotateOptions{
+			Source:       source,
+			MasterKey:    testMaster,
+			NewMasterKey: testNewMaster,
+			Only:         []string{"MISSING"},
+			Out:          &buffer,
+		})
+
+		assert.ErrorContains(t, err, `no secret or secret seed matches "MISSING"`)
+	})
+
+	t.Run("ResumesInterruptedRotation", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		first := filepath.Join(target, "first")
+		second := filepath.Join(target, "second")
+		write(t, rhyming(source, first), encrypt(t, "FIRST\n", testMaster))
+		write(t, rhyming(source, second), encrypt(t, "SECOND\n", testMaster))
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  TOK: %s\nseeds:\n  %s:\n    secret: true\n  %s:\n    secret: true\n",
+			encrypt(t, "V", testMaster), first, second,
+		))
+
+		targets, _, err := collectLayers(Layers(source))
+		assert.NilError(t, err)
+
+		newKey, err := secrets.ResolveMasterKey(testNewMaster)
+		assert.NilError(t, err)
+
+		oldKey, err := secrets.ResolveMasterKey(testMaster)
+		assert.NilError(t, err)
+
+		for i := range targets {
+			plain, err := secrets.Decrypt(targets[i].cipher, oldKey)
+			assert.NilError(t, err)
+			targets[i].rotated, err = secrets.Encrypt(plain, newKey)
+			assert.NilError(t, err)
+		}
+
+		assert.NilError(t, saveJournal(source, targets))
+		assert.NilError(t, targets[1].writeBack(targets[1].rotated))
+
+		var buffer bytes.Buffer
+		err = Rotate(RotateOptions{Source: source, MasterKey: testMaster, NewMasterKey: testNewMaster, Out: &buffer})
+		assert.ErrorContains(t, err, "interrupted rotation")
+
+		output := rotate(t, RotateOptions{Source: source, Resume: true})
+		assert.Equal(t, strings.Count(output, "Rotated"), 3)
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		decrypts(t, manifest.Secrets["TOK"], testNewMaster, "V")
+		decrypts(t, readFile(t, rhyming(source, first)), testNewMaster, "FIRST\n")
+		decrypts(t, readFile(t, rhyming(source, second)), testNewMaster, "SECOND\n")
+		assert.Assert(t, !fileExists(JournalPath(source)))
+
+		var again bytes.Buffer
+		err = Rotate(RotateOptions{Source: source, Resume: true, Out: &again})
+		assert.ErrorContains(t, err, "no interrupted rotation")
+	})
+
+	t.Run("CompletedRotationClearsJournal", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "V", testMaster)))
+
+		rotate(t, RotateOptions{Source: source, MasterKey: testMaster, NewMasterKey: testNewMaster})
+
+		assert.Assert(t, !fileExists(JournalPath(source)))
+	})
+
+	t.Run("UpgradesLegacyCiphertexts", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "id_key")
+
+		legacy := func(plain string) string {
+			parts := strings.Split(encrypt(t, plain, testMaster), "$")
+			return parts[4] + "$" + parts[5]
+		}
+
+		current := encrypt(t, "CURRENT", testMaster)
+		write(t, rhyming(source, dest), legacy("MIRROR\n"))
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  OLD: %s\n  NEW: %s\nseeds:\n  %s:\n    secret: true\n",
+			legacy("OLDVAL"), current, dest,
+		))
+
+		output := rotate(t, RotateOptions{Source: source, MasterKey: testMaster, Upgrade: true})
+
+		assert.Equal(t, strings.Count(output, "Rotated"), 2)
+
+		manifest, err := LoadManifest(ManifestPath(source))
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(manifest.Secrets["OLD"], "$ws1$"))
+		decrypts(t, manifest.Secrets["OLD"], testMaster, "OLDVAL")
+		assert.Equal(t, manifest.Secrets["NEW"], current)
+
+		mirror := readFile(t, rhyming(source, dest))
+		assert.Assert(t, strings.HasPrefix(mirror, "$ws1$"))
+		decrypts(t, mirror, testMaster, "MIRROR\n")
+	})
+
+	t.Run("UpgradeRejectsNewMaster", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		writeManifest(t, source, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "V", testMaster)))
+
+		rotateErr(t, RotateOptions{Source: source, MasterKey: testMaster, NewMasterKey: testNewMaster, Upgrade: true})
+	})
 }
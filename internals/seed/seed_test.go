@@ -19,6 +19,7 @@ const testMaster = "ws-seed-test-master-key-0123456789"
 func setEnv(t *testing.T, home string) {
 	t.Setenv("HOME", home)
 	t.Setenv("WS__INTERNAL_ENV_REFERENCE", filepath.Join(t.TempDir(), "absent.yaml"))
+	t.Setenv("WS__INTERNAL_SEED_STATE", t.TempDir())
 }
 
 func write(t *testing.T, path, content string) {
@@ -61,6 +62,15 @@ func encrypt(t *testing.T, plaintext, key string) string {
 	return encrypted
 }
 
+func encryptBound(t *testing.T, plaintext, key, context string) string {
+	t.Helper()
+	master, err := secrets.ResolveMasterKey(key)
+	assert.NilError(t, err)
+	encrypted, err := secrets.EncryptContext([]byte(plaintext), master, context)
+	assert.NilError(t, err)
+	return encrypted
+}
+
 func mode(t *testing.T, path string) os.FileMode {
 	t.Helper()
 	info, err := os.Stat(path)
@@ -208,6 +218,23 @@ func TestApplyOps(t *testing.T) {
 		assert.Equal(t, mode(t, dest), os.FileMode(0o600))
 	})
 
+	t.Run("MergeWarnsWhenLayoutIsLost", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "config.toml")
+		write(t, dest, "# tools\n[[bins]]\nname = \"a\"\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: merge\n    force: true\n    content: 'bins = [{ name = \"b\" }]'\n", dest))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+		assert.Assert(t, strings.Contains(output, "Warning "+dest+": comments and key order could not be preserved"))
+
+		output = apply(t, Options{Source: source})
+		assert.Assert(t, strings.Contains(output, "Warning "+dest+": comments and key order could not be preserved"))
+		assert.Assert(t, !strings.Contains(readFile(t, dest), "# tools"))
+	})
+
 	t.Run("MergeScalarVsMapConflictLeavesDestUnchanged", func(t *testing.T) {
 		setEnv(t, t.TempDir())
 		source := t.TempDir()
@@ -331,6 +358,42 @@ func TestApplySecrets(t *testing.T) {
 		assert.Assert(t, !strings.Contains(output, "PRIVATE"))
 	})
 
+	t.Run("BoundToManifestKey", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "id_key")
+		rendered := filepath.Join(target, "token.txt")
+
+		write(t, rhyming(source, dest), encryptBound(t, "PRIVATE-KEY-BODY\n", testMaster, "seeds."+dest))
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  API_TOKEN: %s\nseeds:\n  %s:\n    secret: true\n  %s:\n    template: true\n    content: \"${secrets.API_TOKEN}\\n\"\n",
+			encryptBound(t, "TOKEN", testMaster, "secrets.API_TOKEN"), dest, rendered,
+		))
+
+		apply(t, Options{Source: source, MasterKey: testMaster})
+
+		assert.Equal(t, readFile(t, dest), "PRIVATE-KEY-BODY\n")
+		assert.Equal(t, readFile(t, rendered), "TOKEN\n")
+	})
+
+	t.Run("SwappedBoundSecretFailsClosed", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		rendered := filepath.Join(target, "token.txt")
+
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  API_TOKEN: %s\nseeds:\n  %s:\n    template: true\n    content: \"${secrets.API_TOKEN}\\n\"\n",
+			encryptBound(t, "DB-PASSWORD", testMaster, "secrets.DB_PASSWORD"), rendered,
+		))
+
+		output := applyErr(t, Options{Source: source, MasterKey: testMaster})
+
+		assert.Assert(t, !fileExists(rendered))
+		assert.Assert(t, !strings.Contains(output, "DB-PASSWORD"))
+	})
+
 	t.Run("SecretFreeManifestNeedsNoKey", func(t *testing.T) {
 		setEnv(t, t.TempDir())
 		source := t.TempDir()
@@ -490,6 +553,96 @@ func TestApplyForceMatrix(t *testing.T) {
 	})
 }
 
+func TestApplyDryRun(t *testing.T) {
+	t.Run("CreateLeavesFilesystemUntouched", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "new.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    mode: \"0o644\"\n    content: \"hello\\n\"\n", dest))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+
+		assert.Assert(t, !fileExists(dest))
+		assert.Assert(t, strings.Contains(output, "Create ["+dest+"]"))
+		assert.Assert(t, strings.Contains(output, "--- /dev/null\n+++ "+dest+"\n@@ -0,0 +1 @@\n+hello\n"))
+		assert.Assert(t, strings.Contains(output, "Dry run: 1 to create, 0 to modify, 0 to remove, 0 unchanged, 0 skipped"))
+	})
+
+	t.Run("MergeShowsDiffAgainstCurrent", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "config.json")
+		write(t, dest, "{\n  \"a\": 1\n}\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: merge\n    force: true\n    content: '{\"b\":2}'\n", dest))
+
+		output := apply(t, Options{Source: source, DryRun: true})
+
+		assert.Equal(t, readFile(t, dest), "{\n  \"a\": 1\n}\n")
+		assert.Assert(t, strings.Contains(output, "Modify ["+dest+"]"))
+		assert.Assert(t, strings.Contains(output, "-  \"a\": 1\n+  \"a\": 1,\n+  \"b\": 2\n"))
+	})
+
+	t.Run("UnchangedAndSkipped", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		blockDest := filepath.Join(target, "rc")
+		copyDest := filepath.Join(target, "kept.txt")
+		write(t, copyDest, "mine\n")
+
+		writeManifest(t, source, fmt.Sprintf(
+			"seeds:\n  %s:\n    op: block\n    content: \"x=1\\n\"\n  %s:\n    mode: \"0o644\"\n    content: \"theirs\\n\"\n",
+			blockDest, copyDest,
+		))
+		apply(t, Options{Source: source, Dests: []string{blockDest}})
+
+		output := apply(t, Options{Source: source, DryRun: true})
+
+		assert.Assert(t, strings.Contains(output, "Unchanged ["+blockDest+"]"))
+		assert.Assert(t, strings.Contains(output, "Skipping ["+copyDest+"] (exists)"))
+		assert.Assert(t, strings.Contains(output, "Dry run: 0 to create, 0 to modify, 0 to remove, 1 unchanged, 1 skipped"))
+	})
+
+	t.Run("SecretContentRedacted", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "token.txt")
+		write(t, dest, "OLD-SECRET-VALUE\n")
+
+		ciphertext := encrypt(t, "TOP-SECRET-VALUE", testMaster)
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  TOK: %s\nseeds:\n  %s:\n    template: true\n    force: true\n    content: \"${secrets.TOK}\\n\"\n",
+			ciphertext, dest,
+		))
+
+		output := apply(t, Options{Source: source, MasterKey: testMaster, DryRun: true})
+
+		assert.Equal(t, readFile(t, dest), "OLD-SECRET-VALUE\n")
+		assert.Assert(t, strings.Contains(output, "Modify ["+dest+"]"))
+		assert.Assert(t, strings.Contains(output, "(secret content redacted)"))
+		assert.Assert(t, !strings.Contains(output, "SECRET-VALUE"))
+	})
+
+	t.Run("FailuresStillReported", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "rendered.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    template: true\n    content: \"${bogus}\\n\"\n", dest))
+
+		output := applyErr(t, Options{Source: source, DryRun: true})
+
+		assert.Assert(t, strings.Contains(output, "unknown template token ${bogus}"))
+		assert.Assert(t, strings.Contains(output, "Dry run: 0 to create, 0 to modify, 0 to remove, 0 unchanged, 1 skipped"))
+	})
+}
+
 func TestWriteAtomicSymlink(t *testing.T) {
 	t.Run("EscapingComponentRefused", func(t *testing.T) {
 		anchor := t.TempDir()
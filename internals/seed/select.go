@@ -0,0 +1,138 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type Selector struct {
+	Profiles []string `yaml:"profiles"`
+	When     string   `yaml:"when"`
+}
+
+func (s Selector) empty() bool {
+	return len(s.Profiles) == 0 && s.When == ""
+}
+
+func (s Selector) validate() error {
+	for _, profile := range s.Profiles {
+		if strings.TrimSpace(profile) == "" {
+			return fmt.Errorf("profiles must not contain an empty name")
+		}
+	}
+
+	if s.When != "" {
+		if err := validateExpression(s.When); err != nil {
+			return fmt.Errorf("when: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s Selector) matches(active []string, vars Vars) (bool, error) {
+	if len(s.Profiles) > 0 && !slices.ContainsFunc(s.Profiles, func(profile string) bool {
+		return slices.Contains(active, profile)
+	}) {
+		return false, nil
+	}
+
+	if s.When == "" {
+		return true, nil
+	}
+
+	return evaluateExpression(s.When, vars.lookup(func(string) ([]byte, error) {
+		return nil, errors.New("secrets cannot be used in when")
+	}))
+}
+
+func (p *Plan) Profiles() []string {
+	known := map[string]bool{}
+	for _, op := range slices.Concat(p.Ops, p.Excluded) {
+		for layer := &op; layer != nil; layer = layer.Base {
+			for _, profile := range layer.Selector.Profiles {
+				known[profile] = true
+			}
+		}
+	}
+
+	profiles := make([]string, 0, len(known))
+	for profile := range known {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+
+	return profiles
+}
+
+func (p *Plan) Select(profiles []string) error {
+	known := p.Profiles()
+	for _, profile := range profiles {
+		if !slices.Contains(known, profile) {
+			return fmt.Errorf("unknown profile %q", profile)
+		}
+	}
+
+	selected := make([]ResolvedOp, 0, len(p.Ops))
+	for _, op := range p.Ops {
+		resolved, err := p.selectLayer(&op, profiles)
+		if err != nil {
+			return fmt.Errorf("seed %q: %w", op.Dest, err)
+		}
+
+		if resolved == nil || resolved.Layer != op.Layer {
+			p.Excluded = append(p.Excluded, op)
+		}
+
+		if resolved != nil {
+			selected = append(selected, *resolved)
+		}
+	}
+
+	p.Ops = selected
+
+	return nil
+}
+
+func (p *Plan) selectLayer(op *ResolvedOp, profiles []string) (*ResolvedOp, error) {
+	if op == nil {
+		return nil, nil
+	}
+
+	matched, err := op.Selector.matches(profiles, p.Vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if !matched {
+		return p.selectLayer(op.Base, profiles)
+	}
+
+	selected := *op
+	if selected.stacks() {
+		if selected.Base, err = p.selectLayer(op.Base, profiles); err != nil {
+			return nil, err
+		}
+	}
+
+	return &selected, nil
+}
+
+func subtreeSelector(subtrees map[string]Selector, dest string) Selector {
+	var (
+		longest  string
+		selector Selector
+	)
+
+	for prefix, candidate := range subtrees {
+		if (dest == prefix || strings.HasPrefix(dest, prefix+string(filepath.Separator))) && len(prefix) > len(longest) {
+			longest, selector = prefix, candidate
+		}
+	}
+
+	return selector
+}
@@ -0,0 +1,106 @@
+package seed
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"gotest.tools/v3/assert"
+)
+
+func selected(t *testing.T, source string, profiles ...string) []string {
+	t.Helper()
+
+	plan, err := BuildPlan(source, false)
+	assert.NilError(t, err)
+	assert.NilError(t, plan.Select(profiles))
+
+	dests := make([]string, 0, len(plan.Ops))
+	for _, op := range plan.Ops {
+		dests = append(dests, op.Dest)
+	}
+
+	return dests
+}
+
+func TestSelect(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		setEnv(t, t.TempDir())
+		installSettingsFixture(t)
+		t.Setenv("SSH_CONNECTION", "")
+		t.Setenv("SSH_CLIENT", "")
+		t.Setenv("SSH_TTY", "")
+
+		source := t.TempDir()
+		target := t.TempDir()
+
+		write(t, filepath.Join(source, target, "m2", "settings.xml"), "<settings/>\n")
+		write(t, filepath.Join(source, target, "common.txt"), "common\n")
+
+		writeManifest(t, source, fmt.Sprintf(
+			"mirror:\n  %[1]s/m2:\n    profiles: [backend]\n"+
+				"seeds:\n"+
+				"  %[1]s/always.txt:\n    content: \"a\\n\"\n"+
+				"  %[1]s/data.txt:\n    profiles: [data]\n    content: \"d\\n\"\n"+
+				"  %[1]s/platform.txt:\n    when: env.team.profile == platform\n    content: \"p\\n\"\n"+
+				"  %[1]s/remote.txt:\n    when: ws_ssh\n    content: \"r\\n\"\n"+
+				"  %[1]s/either.txt:\n    profiles: [backend, data]\n    when: \"!ws_ssh && env.server.port == 8080\"\n    content: \"e\\n\"\n",
+			target,
+		))
+
+		return source, target
+	}
+
+	t.Run("NoProfile", func(t *testing.T) {
+		source, target := setup(t)
+
+		assert.DeepEqual(t, selected(t, source), []string{
+			filepath.Join(target, "always.txt"),
+			filepath.Join(target, "common.txt"),
+			filepath.Join(target, "platform.txt"),
+		})
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		source, target := setup(t)
+
+		assert.DeepEqual(t, selected(t, source, "backend"), []string{
+			filepath.Join(target, "always.txt"),
+			filepath.Join(target, "common.txt"),
+			filepath.Join(target, "either.txt"),
+			filepath.Join(target, "m2", "settings.xml"),
+			filepath.Join(target, "platform.txt"),
+		})
+	})
+
+	t.Run("SSHSession", func(t *testing.T) {
+		source, target := setup(t)
+		t.Setenv("SSH_CONNECTION", "10.0.0.1 50000 10.0.0.2 22")
+
+		assert.DeepEqual(t, selected(t, source, "data"), []string{
+			filepath.Join(target, "always.txt"),
+			filepath.Join(target, "common.txt"),
+			filepath.Join(target, "data.txt"),
+			filepath.Join(target, "platform.txt"),
+			filepath.Join(target, "remote.txt"),
+		})
+	})
+
+	t.Run("UnknownProfileRejected", func(t *testing.T) {
+		source, _ := setup(t)
+
+		plan, err := BuildPlan(source, false)
+		assert.NilError(t, err)
+		assert.ErrorContains(t, plan.Select([]string{"frontend"}), `unknown profile "frontend"`)
+	})
+
+	t.Run("ApplySkipsUnselected", func(t *testing.T) {
+		source, target := setup(t)
+
+		apply(t, Options{Source: source, Profiles: []string{"data"}})
+
+		assert.Assert(t, internalIO.FileExists(filepath.Join(target, "data.txt")))
+		assert.Assert(t, !internalIO.FileExists(filepath.Join(target, "m2", "settings.xml")))
+	})
+}
@@ -0,0 +1,151 @@
+package seed
+
+import (
+	"bytes"
+	"os"
+)
+
+type State string
+
+const (
+	StateInSync       State = "in-sync"
+	StateDrifted      State = "drifted"
+	StateMissing      State = "missing"
+	StateNeverApplied State = "never-applied"
+)
+
+type DestStatus struct {
+	Dest          string
+	Op            Op
+	State         State
+	SourceChanged bool
+}
+
+func (p *Plan) Status(ledger *Ledger) ([]DestStatus, error) {
+	statuses := make([]DestStatus, 0, len(p.Ops))
+
+	for _, op := range p.Ops {
+		status := DestStatus{Dest: op.Dest, Op: op.Op}
+
+		entry, recorded := ledger.Entries[op.Dest]
+		if !recorded || (entry.State == PresenceAbsent) != (op.State == PresenceAbsent) {
+			status.State = StateNeverApplied
+			statuses = append(statuses, status)
+			continue
+		}
+
+		state, err := entry.check(op.Dest)
+		if err != nil {
+			return nil, err
+		}
+
+		status.State = state
+
+		if hash, err := p.sourceHash(op); err == nil {
+			status.SourceChanged = hash != entry.SourceHash
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (l *Ledger) inSync(dest string) bool {
+	entry, ok := l.entry(dest)
+	if !ok {
+		return false
+	}
+
+	state, err := entry.check(dest)
+
+	return err == nil && state == StateInSync
+}
+
+func (e LedgerEntry) check(dest string) (State, error) {
+	switch {
+	case e.State == PresenceAbsent:
+		return e.checkAbsent(dest)
+	case e.Op == OpSymlink:
+		return e.checkLink(dest), nil
+	case e.Op == OpDirectory:
+		return e.checkDirectory(dest), nil
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		return StateMissing, nil
+	}
+
+	current, err := os.ReadFile(dest)
+	if err != nil {
+		return StateDrifted, nil
+	}
+
+	if formatMode(info.Mode()) != e.Mode {
+		return StateDrifted, nil
+	}
+
+	matches, err := e.matcher()
+	if err != nil {
+		return "", err
+	}
+
+	switch e.Op {
+	case OpBlock:
+		block, found, err := currentBlock(current, e.Comment)
+		if err != nil || !found {
+			return StateDrifted, nil
+		}
+
+		current = block
+	case OpLineInfile:
+		if !containsLine(current, matches) {
+			return StateDrifted, nil
+		}
+
+		return StateInSync, nil
+	}
+
+	if !matches(current) {
+		return StateDrifted, nil
+	}
+
+	return StateInSync, nil
+}
+
+func (e LedgerEntry) checkLink(dest string) State {
+	if _, err := os.Lstat(dest); err != nil {
+		return StateMissing
+	}
+
+	target, err := os.Readlink(dest)
+	if err != nil || hashBytes([]byte(target)) != e.ContentHash {
+		return StateDrifted
+	}
+
+	return StateInSync
+}
+
+func (e LedgerEntry) checkDirectory(dest string) State {
+	info, err := os.Lstat(dest)
+	if err != nil {
+		return StateMissing
+	}
+
+	if !info.IsDir() || formatMode(info.Mode()) != e.Mode {
+		return StateDrifted
+	}
+
+	return StateInSync
+}
+
+func containsLine(content []byte, matches func([]byte) bool) bool {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if matches(line) {
+			return true
+		}
+	}
+
+	return false
+}
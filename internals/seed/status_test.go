@@ -0,0 +1,218 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func statusOf(t *testing.T, source string) map[string]DestStatus {
+	t.Helper()
+
+	plan, err := BuildPlan(source, false)
+	assert.NilError(t, err)
+
+	ledger, err := LoadLedger(LedgerPath())
+	assert.NilError(t, err)
+
+	all, err := plan.Status(ledger)
+	assert.NilError(t, err)
+
+	statuses := map[string]DestStatus{}
+	for _, status := range all {
+		statuses[status.Dest] = status
+	}
+
+	return statuses
+}
+
+func TestLedger(t *testing.T) {
+	t.Run("RecordsAppliedDestinations", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    mode: \"0o640\"\n    content: \"v1\\n\"\n", dest))
+
+		apply(t, Options{Source: source})
+
+		ledger, err := LoadLedger(LedgerPath())
+		assert.NilError(t, err)
+
+		entry, ok := ledger.Entries[dest]
+		assert.Assert(t, ok)
+		assert.Equal(t, entry.Op, OpCopy)
+		assert.Equal(t, entry.Mode, "0o640")
+		assert.Equal(t, entry.ContentHash, hashBytes([]byte("v1\n")))
+		assert.Equal(t, entry.SourceHash, hashBytes([]byte("v1\n")))
+		assert.Equal(t, mode(t, LedgerPath()), os.FileMode(0o600))
+	})
+
+	t.Run("SecretContentHashIsKeyed", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "token")
+
+		write(t, rhyming(source, dest), encrypt(t, "1234", testMaster))
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    secret: true\n", dest))
+
+		apply(t, Options{Source: source, MasterKey: testMaster})
+
+		ledger, err := LoadLedger(LedgerPath())
+		assert.NilError(t, err)
+
+		entry := ledger.Entries[dest]
+		assert.Assert(t, strings.HasPrefix(entry.ContentHash, keyedPrefix))
+		assert.Assert(t, !strings.Contains(readFile(t, LedgerPath()), hashBytes([]byte("1234"))))
+		assert.Equal(t, mode(t, filepath.Join(StateDir(), ledgerKeyName)), os.FileMode(0o600))
+		assert.Equal(t, statusOf(t, source)[dest].State, StateInSync)
+
+		write(t, dest, "5678")
+		assert.Equal(t, statusOf(t, source)[dest].State, StateDrifted)
+	})
+
+	t.Run("UnreadableLedgerKeyFails", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "token")
+
+		write(t, rhyming(source, dest), encrypt(t, "1234", testMaster))
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    secret: true\n", dest))
+		write(t, filepath.Join(StateDir(), ledgerKeyName), "short")
+
+		output := applyErr(t, Options{Source: source, MasterKey: testMaster})
+
+		assert.Assert(t, strings.Contains(output, "invalid seed ledger key"))
+		assert.Assert(t, !ledgerHas(t, dest))
+	})
+
+	t.Run("DryRunDoesNotRecord", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    mode: \"0o644\"\n    content: \"v1\\n\"\n", dest))
+
+		apply(t, Options{Source: source, DryRun: true})
+
+		assert.Assert(t, !fileExists(LedgerPath()))
+	})
+
+	t.Run("SkippedNotRecorded", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+		write(t, dest, "mine\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    mode: \"0o644\"\n    content: \"v1\\n\"\n", dest))
+
+		apply(t, Options{Source: source})
+
+		ledger, err := LoadLedger(LedgerPath())
+		assert.NilError(t, err)
+		_, ok := ledger.Entries[dest]
+		assert.Assert(t, !ok)
+	})
+
+	t.Run("UnsupportedVersionRejected", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		write(t, LedgerPath(), `{"version": 99, "entries": {}}`)
+
+		_, err := LoadLedger(LedgerPath())
+		assert.ErrorContains(t, err, "unsupported seed ledger version")
+	})
+}
+
+func TestStatus(t *testing.T) {
+	t.Run("Copy", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    mode: \"0o644\"\n    content: \"v1\\n\"\n", dest))
+
+		assert.Equal(t, statusOf(t, source)[dest].State, StateNeverApplied)
+
+		apply(t, Options{Source: source})
+		assert.Equal(t, statusOf(t, source)[dest].State, StateInSync)
+
+		write(t, dest, "edited\n")
+		assert.Equal(t, statusOf(t, source)[dest].State, StateDrifted)
+
+		assert.NilError(t, os.Remove(dest))
+		assert.Equal(t, statusOf(t, source)[dest].State, StateMissing)
+	})
+
+	t.Run("ModeChangeIsDrift", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    mode: \"0o600\"\n    content: \"v1\\n\"\n", dest))
+		apply(t, Options{Source: source})
+
+		assert.NilError(t, os.Chmod(dest, 0o644))
+		assert.Equal(t, statusOf(t, source)[dest].State, StateDrifted)
+	})
+
+	t.Run("BlockIgnoresEditsOutsideMarkers", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "rc")
+		write(t, dest, "user\n")
+
+		writeManifest(t, source, blockManifest(dest, "\"x=1\\n\""))
+		apply(t, Options{Source: source})
+
+		write(t, dest, "user edited\n"+readFile(t, dest)+"more\n")
+		assert.Equal(t, statusOf(t, source)[dest].State, StateInSync)
+
+		write(t, dest, "# >>> ws-seed >>>\nx=2\n# <<< ws-seed <<<\n")
+		assert.Equal(t, statusOf(t, source)[dest].State, StateDrifted)
+	})
+
+	t.Run("LineInFile", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "env")
+		write(t, dest, "A=1\n")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    op: lineinfile\n    content: \"B=2\"\n", dest))
+		apply(t, Options{Source: source})
+
+		write(t, dest, "B=2\nA=9\n")
+		assert.Equal(t, statusOf(t, source)[dest].State, StateInSync)
+
+		write(t, dest, "B=3\n")
+		assert.Equal(t, statusOf(t, source)[dest].State, StateDrifted)
+	})
+
+	t.Run("SourceChanged", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "mirror.txt")
+
+		write(t, rhyming(source, dest), "v1\n")
+		apply(t, Options{Source: source})
+		assert.Assert(t, !statusOf(t, source)[dest].SourceChanged)
+
+		write(t, rhyming(source, dest), "v2\n")
+		status := statusOf(t, source)[dest]
+		assert.Equal(t, status.State, StateInSync)
+		assert.Assert(t, status.SourceChanged)
+	})
+}
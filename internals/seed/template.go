@@ -1,55 +1,256 @@
 package seed
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/kloudkit/ws-cli/internals/config"
 )
 
-const secretsPrefix = "secrets."
+const (
+	secretsPrefix  = "secrets."
+	settingsPrefix = "env."
+	defaultMarker  = ":-"
+)
 
-var tokenRe = regexp.MustCompile(`\$\{([^}]*)\}`)
+var (
+	tokenRe     = regexp.MustCompile(`\$\{([^}]*)\}`)
+	settingRe   = regexp.MustCompile(`\$\{[^}]*?\benv\.([A-Za-z0-9_]+)\.([A-Za-z0-9_]+)`)
+	conditionRe = regexp.MustCompile(`^(!)?\s*([A-Za-z0-9_.]+)\s*(?:(==|!=)\s*(?:"([^"]*)"|(\S+)))?$`)
+)
 
-func referencesSecrets(content []byte) bool {
-	return strings.Contains(string(content), "${"+secretsPrefix)
+type frame struct {
+	active bool
+	taken  bool
+	opened string
 }
 
-func renderTemplate(content []byte, vars Vars, secret func(string) ([]byte, error)) ([]byte, error) {
-	var failure error
+func referencesSecrets(content []byte) bool {
+	if strings.Contains(string(content), "${"+secretsPrefix) {
+		return true
+	}
 
-	rendered := tokenRe.ReplaceAllFunc(content, func(match []byte) []byte {
-		if failure != nil {
-			return nil
+	for _, match := range settingRe.FindAllStringSubmatch(string(content), -1) {
+		property, known, err := config.LookupProperty(config.RuntimeKey(match[1], match[2]))
+		if err == nil && known && property.Secret {
+			return true
 		}
+	}
 
-		token := string(match[2 : len(match)-1])
+	return false
+}
 
-		switch token {
+func (v Vars) lookup(secret func(string) ([]byte, error)) func(string) (string, error) {
+	return func(name string) (string, error) {
+		switch name {
 		case "ws_home":
-			return []byte(vars.Home)
+			return v.Home, nil
 		case "ws_user":
-			return []byte(vars.User)
+			return v.User, nil
 		case "ws_server_root":
-			return []byte(vars.ServerRoot)
+			return v.ServerRoot, nil
+		case "ws_ssh":
+			return strconv.FormatBool(v.SSH), nil
+		}
+
+		if key, ok := strings.CutPrefix(name, secretsPrefix); ok && secret != nil {
+			value, err := secret(key)
+			return string(value), err
+		}
+
+		if setting, ok := strings.CutPrefix(name, settingsPrefix); ok {
+			return resolveSetting(setting)
+		}
+
+		return "", fmt.Errorf("unknown template token ${%s}", name)
+	}
+}
+
+func renderTemplate(content []byte, vars Vars, secret func(string) ([]byte, error)) ([]byte, error) {
+	lookup := vars.lookup(secret)
+
+	var (
+		out   bytes.Buffer
+		stack []frame
+		last  int
+	)
+
+	active := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].active
+	}
+
+	for _, loc := range tokenRe.FindAllSubmatchIndex(content, -1) {
+		token := strings.TrimSpace(string(content[loc[2]:loc[3]]))
+		start, end := loc[0], loc[1]
+
+		directive := token == "else" || token == "end" || strings.HasPrefix(token, "if ")
+		if directive {
+			start, end = standaloneLine(content, start, end)
 		}
 
-		if name, ok := strings.CutPrefix(token, secretsPrefix); ok {
-			value, err := secret(name)
+		if active() {
+			out.Write(content[last:start])
+		}
+		last = end
+
+		switch {
+		case strings.HasPrefix(token, "if "):
+			outer := active()
+			matched := false
+
+			if outer {
+				var err error
+				if matched, err = evaluateExpression(strings.TrimSpace(token[3:]), lookup); err != nil {
+					return nil, err
+				}
+			}
+
+			stack = append(stack, frame{active: outer && matched, taken: matched, opened: token})
+		case token == "else":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected ${else} without ${if}")
+			}
+
+			top := &stack[len(stack)-1]
+			outer := len(stack) == 1 || stack[len(stack)-2].active
+			top.active = outer && !top.taken
+			top.taken = true
+		case token == "end":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected ${end} without ${if}")
+			}
+
+			stack = stack[:len(stack)-1]
+		default:
+			if !active() {
+				continue
+			}
+
+			value, err := expandToken(token, lookup)
 			if err != nil {
-				failure = err
-				return nil
+				return nil, err
 			}
 
-			return value
+			out.WriteString(value)
 		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("unterminated ${%s}", stack[len(stack)-1].opened)
+	}
+
+	out.Write(content[last:])
+
+	return out.Bytes(), nil
+}
+
+func expandToken(token string, lookup func(string) (string, error)) (string, error) {
+	name, fallback, hasDefault := strings.Cut(token, defaultMarker)
 
-		failure = fmt.Errorf("unknown template token ${%s}", token)
-		return nil
-	})
+	value, err := lookup(strings.TrimSpace(name))
+	if err != nil {
+		return "", err
+	}
+
+	if value == "" && hasDefault {
+		return fallback, nil
+	}
+
+	return value, nil
+}
+
+func evaluateExpression(expr string, lookup func(string) (string, error)) (bool, error) {
+	for _, alternative := range strings.Split(expr, "||") {
+		matched := true
+
+		for _, term := range strings.Split(alternative, "&&") {
+			ok, err := evaluateCondition(strings.TrimSpace(term), lookup)
+			if err != nil {
+				return false, err
+			}
+
+			if !ok {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func validateExpression(expr string) error {
+	for _, alternative := range strings.Split(expr, "||") {
+		for _, term := range strings.Split(alternative, "&&") {
+			if !conditionRe.MatchString(strings.TrimSpace(term)) {
+				return fmt.Errorf("invalid condition %q", strings.TrimSpace(term))
+			}
+		}
+	}
+
+	return nil
+}
+
+func evaluateCondition(expr string, lookup func(string) (string, error)) (bool, error) {
+	match := conditionRe.FindStringSubmatch(expr)
+	if match == nil {
+		return false, fmt.Errorf("invalid condition ${if %s}", expr)
+	}
+
+	value, err := lookup(match[2])
+	if err != nil {
+		return false, err
+	}
+
+	var result bool
+	switch match[3] {
+	case "==":
+		result = value == match[4]+match[5]
+	case "!=":
+		result = value != match[4]+match[5]
+	default:
+		truthy, parseErr := config.ParseBool(value)
+		result = value != "" && (parseErr != nil || truthy)
+	}
+
+	if match[1] == "!" {
+		result = !result
+	}
+
+	return result, nil
+}
+
+func resolveSetting(name string) (string, error) {
+	group, prop, ok := strings.Cut(name, ".")
+	if !ok || group == "" || prop == "" {
+		return "", fmt.Errorf("invalid setting reference ${%s%s}", settingsPrefix, name)
+	}
+
+	key := config.RuntimeKey(group, prop)
+	if _, known, err := config.LookupProperty(key); err == nil && !known {
+		return "", fmt.Errorf("unknown workspace setting %q", name)
+	}
+
+	return config.ResolveKey(key)
+}
+
+func standaloneLine(content []byte, start, end int) (int, int) {
+	lineStart := bytes.LastIndexByte(content[:start], '\n') + 1
+	if len(bytes.TrimSpace(content[lineStart:start])) > 0 {
+		return start, end
+	}
 
-	if failure != nil {
-		return nil, failure
+	lineStop := lineEnd(content, end)
+	if len(bytes.TrimSpace(content[end:lineStop])) > 0 {
+		return start, end
 	}
 
-	return rendered, nil
+	return lineStart, lineStop
 }
@@ -0,0 +1,130 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const settingsFixture = `
+envs:
+  server:
+    properties:
+      port:
+        type: integer
+        default: 8080
+  git:
+    properties:
+      user:
+        type: string
+        default: null
+  team:
+    properties:
+      profile:
+        type: string
+        default: platform
+  secrets:
+    properties:
+      master_key:
+        type: string
+        default: null
+        secret: true
+`
+
+func installSettingsFixture(t *testing.T) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "env.reference.yaml")
+	assert.NilError(t, os.WriteFile(path, []byte(settingsFixture), 0o644))
+	t.Setenv("WS__INTERNAL_ENV_REFERENCE", path)
+}
+
+func render(t *testing.T, content string) (string, error) {
+	t.Helper()
+
+	vars := Vars{Home: "/home/dev", User: "dev", ServerRoot: "/workspace"}
+	secret := func(name string) ([]byte, error) {
+		if name == "TOKEN" {
+			return []byte("s3cr3t"), nil
+		}
+
+		return nil, fmt.Errorf("secret %q is not declared", name)
+	}
+
+	out, err := renderTemplate([]byte(content), vars, secret)
+
+	return string(out), err
+}
+
+func TestRenderTemplate(t *testing.T) {
+	installSettingsFixture(t)
+	t.Setenv("WS_GIT_USER", "")
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"Builtins", "${ws_home}|${ws_user}|${ws_server_root}", "/home/dev|dev|/workspace"},
+		{"Setting", "port=${env.server.port}", "port=8080"},
+		{"DefaultWhenEmpty", "user=${env.git.user:-anonymous}", "user=anonymous"},
+		{"DefaultIgnoredWhenSet", "port=${env.server.port:-1}", "port=8080"},
+		{"Secret", "token=${secrets.TOKEN}", "token=s3cr3t"},
+		{"IfTrue", "a\n${if env.team.profile == platform}\nplatform\n${end}\nb\n", "a\nplatform\nb\n"},
+		{"IfElse", "${if env.team.profile == \"data\"}\ndata\n${else}\nother\n${end}\n", "other\n"},
+		{"Negation", "${if !env.git.user}\nno user\n${end}\n", "no user\n"},
+		{"Inline", "mode=${if env.git.user}named${else}anon${end};", "mode=anon;"},
+		{"Nested", "${if env.server.port}\n${if env.git.user}\nuser\n${else}\nnobody\n${end}\n${end}\n", "nobody\n"},
+		{"InactiveTokensNotResolved", "${if env.git.user}\n${secrets.MISSING}\n${end}\nok\n", "ok\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := render(t, tt.content)
+
+			assert.NilError(t, err)
+			assert.Equal(t, out, tt.expected)
+		})
+	}
+
+	t.Run("EnvironmentOverridesDefault", func(t *testing.T) {
+		t.Setenv("WS_SERVER_PORT", "9000")
+
+		out, err := render(t, "${env.server.port}")
+
+		assert.NilError(t, err)
+		assert.Equal(t, out, "9000")
+	})
+
+	errors := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{"UnknownToken", "${bogus}", "unknown template token ${bogus}"},
+		{"UnknownSetting", "${env.server.nope}", `unknown workspace setting "server.nope"`},
+		{"InvalidSetting", "${env.server}", "invalid setting reference ${env.server}"},
+		{"Unterminated", "${if env.server.port}\nx\n", "unterminated ${if env.server.port}"},
+		{"StrayEnd", "x\n${end}\n", "unexpected ${end} without ${if}"},
+		{"StrayElse", "${else}", "unexpected ${else} without ${if}"},
+		{"InvalidCondition", "${if a b c}\n${end}", "invalid condition ${if a b c}"},
+	}
+
+	for _, tt := range errors {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := render(t, tt.content)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
+
+func TestReferencesSecrets(t *testing.T) {
+	installSettingsFixture(t)
+
+	assert.Assert(t, referencesSecrets([]byte("${secrets.TOKEN}")))
+	assert.Assert(t, referencesSecrets([]byte("key=${env.secrets.master_key}")))
+	assert.Assert(t, !referencesSecrets([]byte("port=${env.server.port}")))
+}
@@ -0,0 +1,398 @@
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+type tomlKeyValue struct {
+	path    []string
+	start   int
+	keyEnd  int
+	end     int
+	section int
+}
+
+type tomlSection struct {
+	path  []string
+	array bool
+	start int
+	end   int
+}
+
+type tomlIndex struct {
+	entries  []tomlKeyValue
+	sections []tomlSection
+}
+
+type tomlPatcher struct {
+	data   []byte
+	merged map[string]any
+}
+
+func indexTOML(data []byte) (*tomlIndex, error) {
+	parser := unstable.Parser{KeepComments: true}
+	parser.Reset(data)
+
+	index := &tomlIndex{sections: []tomlSection{{}}}
+	current := 0
+
+	for parser.NextExpression() {
+		expr := parser.Expression()
+
+		switch expr.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			keys, first, _ := tomlKeyParts(expr.Key())
+
+			index.sections = append(index.sections, tomlSection{
+				path:  keys,
+				array: expr.Kind == unstable.ArrayTable,
+				start: bytes.LastIndexByte(data[:first], '\n') + 1,
+				end:   lineEnd(data, first),
+			})
+			current = len(index.sections) - 1
+		case unstable.KeyValue:
+			section := index.sections[current]
+			if section.array {
+				continue
+			}
+
+			keys, _, keyEnd := tomlKeyParts(expr.Key())
+			start := int(expr.Raw.Offset)
+			end := start + int(expr.Raw.Length)
+
+			index.entries = append(index.entries, tomlKeyValue{
+				path:    slices.Concat(section.path, keys),
+				start:   start,
+				keyEnd:  keyEnd,
+				end:     end,
+				section: current,
+			})
+			index.sections[current].end = lineEnd(data, end)
+		}
+	}
+
+	if err := parser.Error(); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func tomlKeyParts(it unstable.Iterator) ([]string, int, int) {
+	var keys []string
+	first, last := -1, 0
+
+	for it.Next() {
+		node := it.Node()
+		keys = append(keys, string(node.Data))
+
+		if first < 0 {
+			first = int(node.Raw.Offset)
+		}
+		last = int(node.Raw.Offset + node.Raw.Length)
+	}
+
+	return keys, first, last
+}
+
+func lineEnd(data []byte, pos int) int {
+	if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+
+	return len(data)
+}
+
+func (t *tomlPatcher) splice(start, end int, text string) {
+	t.data = slices.Concat(t.data[:start], []byte(text), t.data[end:])
+}
+
+func (t *tomlPatcher) entry(index *tomlIndex, path []string) (tomlKeyValue, bool) {
+	for _, entry := range index.entries {
+		if len(entry.path) <= len(path) && slices.Equal(entry.path, path[:len(entry.path)]) {
+			return entry, true
+		}
+	}
+
+	return tomlKeyValue{}, false
+}
+
+func (t *tomlPatcher) rewrite(entry tomlKeyValue) error {
+	value, ok := t.valueAt(entry.path)
+	if !ok {
+		return fmt.Errorf("%w: %s not found", errUnpreservable, describePath(entry.path))
+	}
+
+	rendered, err := tomlInline(value)
+	if err != nil {
+		return err
+	}
+
+	eq := entry.keyEnd + bytes.IndexByte(t.data[entry.keyEnd:entry.end], '=')
+	t.splice(entry.start, entry.end, string(t.data[entry.start:eq+1])+" "+rendered)
+
+	return nil
+}
+
+func (t *tomlPatcher) valueAt(path []string) (any, bool) {
+	var value any = t.merged
+
+	for _, key := range path {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func (t *tomlPatcher) set(path []string, value any) error {
+	index, err := indexTOML(t.data)
+	if err != nil {
+		return err
+	}
+
+	if entry, found := t.entry(index, path); found {
+		return t.rewrite(entry)
+	}
+
+	if m, ok := value.(map[string]any); ok {
+		section, err := tomlSectionText(path, m)
+		if err != nil {
+			return err
+		}
+
+		t.appendSection(section)
+		return nil
+	}
+
+	rendered, err := tomlInline(value)
+	if err != nil {
+		return err
+	}
+
+	parent := path[:len(path)-1]
+	for _, section := range index.sections {
+		if !section.array && slices.Equal(section.path, parent) {
+			t.insertLine(section.end, tomlKey(path[len(path)-1])+" = "+rendered)
+			return nil
+		}
+	}
+
+	for i := len(index.entries) - 1; i >= 0; i-- {
+		entry := index.entries[i]
+		scope := index.sections[entry.section].path
+
+		if len(entry.path) > len(parent) && slices.Equal(entry.path[:len(parent)], parent) &&
+			len(scope) <= len(parent) && slices.Equal(parent[:len(scope)], scope) {
+			t.insertLine(lineEnd(t.data, entry.end), tomlDottedKey(path[len(scope):])+" = "+rendered)
+			return nil
+		}
+	}
+
+	section, err := tomlSectionText(parent, map[string]any{path[len(path)-1]: value})
+	if err != nil {
+		return err
+	}
+
+	t.appendSection(section)
+
+	return nil
+}
+
+func (t *tomlPatcher) extend(path []string, _ []any) error {
+	index, err := indexTOML(t.data)
+	if err != nil {
+		return err
+	}
+
+	entry, found := t.entry(index, path)
+	if !found {
+		return fmt.Errorf("%w: %s is not an inline array", errUnpreservable, describePath(path))
+	}
+
+	return t.rewrite(entry)
+}
+
+func (t *tomlPatcher) remove(path []string) error {
+	index, err := indexTOML(t.data)
+	if err != nil {
+		return err
+	}
+
+	if entry, found := t.entry(index, path); found && len(entry.path) < len(path) {
+		return t.rewrite(entry)
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+
+	within := func(candidate []string) bool {
+		return len(candidate) >= len(path) && slices.Equal(candidate[:len(path)], path)
+	}
+
+	for i, section := range index.sections {
+		if i == 0 || !within(section.path) {
+			continue
+		}
+
+		end := len(t.data)
+		if i+1 < len(index.sections) {
+			end = index.sections[i+1].start
+		}
+
+		spans = append(spans, span{section.start, end})
+	}
+
+	for _, entry := range index.entries {
+		section := index.sections[entry.section]
+		if !within(entry.path) || (entry.section > 0 && within(section.path)) {
+			continue
+		}
+
+		spans = append(spans, span{bytes.LastIndexByte(t.data[:entry.start], '\n') + 1, lineEnd(t.data, entry.end)})
+	}
+
+	if len(spans) == 0 {
+		return fmt.Errorf("%w: %s not found", errUnpreservable, describePath(path))
+	}
+
+	slices.SortFunc(spans, func(a, b span) int { return b.start - a.start })
+	for _, s := range spans {
+		t.splice(s.start, s.end, "")
+	}
+
+	return nil
+}
+
+func (t *tomlPatcher) insertLine(pos int, line string) {
+	if pos > 0 && t.data[pos-1] != '\n' {
+		line = "\n" + line
+	}
+
+	t.splice(pos, pos, line+"\n")
+}
+
+func (t *tomlPatcher) appendSection(section string) {
+	prefix := "\n"
+	if len(t.data) > 0 && !bytes.HasSuffix(t.data, []byte("\n")) {
+		prefix = "\n\n"
+	}
+
+	t.data = append(t.data, prefix+section...)
+}
+
+func (t *tomlPatcher) bytes() ([]byte, error) {
+	return t.data, nil
+}
+
+func tomlSectionText(path []string, table map[string]any) (string, error) {
+	var builder strings.Builder
+	builder.WriteString("[" + tomlDottedKey(path) + "]\n")
+
+	var nested []string
+	for _, key := range sortedKeys(table) {
+		if _, ok := table[key].(map[string]any); ok {
+			nested = append(nested, key)
+			continue
+		}
+
+		rendered, err := tomlInline(table[key])
+		if err != nil {
+			return "", err
+		}
+
+		builder.WriteString(tomlKey(key) + " = " + rendered + "\n")
+	}
+
+	for _, key := range nested {
+		section, err := tomlSectionText(append(slices.Clip(path), key), table[key].(map[string]any))
+		if err != nil {
+			return "", err
+		}
+
+		builder.WriteString("\n" + section)
+	}
+
+	return builder.String(), nil
+}
+
+func tomlInline(value any) (string, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+
+		parts := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			rendered, err := tomlInline(v[key])
+			if err != nil {
+				return "", err
+			}
+
+			parts = append(parts, tomlKey(key)+" = "+rendered)
+		}
+
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			rendered, err := tomlInline(item)
+			if err != nil {
+				return "", err
+			}
+
+			parts = append(parts, rendered)
+		}
+
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case string:
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+
+		if err := encoder.Encode(v); err != nil {
+			return "", err
+		}
+
+		return strings.TrimSuffix(buffer.String(), "\n"), nil
+	}
+
+	out, err := toml.Marshal(map[string]any{"v": value})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(string(out), "v = "), "\n"), nil
+}
+
+func tomlKey(key string) string {
+	out, err := toml.Marshal(map[string]any{key: true})
+	if err != nil {
+		return fmt.Sprintf("%q", key)
+	}
+
+	return strings.TrimSuffix(string(out), " = true\n")
+}
+
+func tomlDottedKey(path []string) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = tomlKey(key)
+	}
+
+	return strings.Join(keys, ".")
+}
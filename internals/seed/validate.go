@@ -0,0 +1,545 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kloudkit/ws-cli/internals/config"
+	internalIO "github.com/kloudkit/ws-cli/internals/io"
+	"github.com/kloudkit/ws-cli/internals/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+var yamlLineRe = regexp.MustCompile(`line (\d+):`)
+
+type Issue struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (i Issue) String() string {
+	switch {
+	case i.Line > 0 && i.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+	case i.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", i.File, i.Message)
+}
+
+type ValidateOptions struct {
+	Source    string
+	MasterKey string
+	Identity  string
+}
+
+type linter struct {
+	vars     Vars
+	declared map[string]string
+	master   []byte
+	identity *secrets.Identity
+	issues   []Issue
+}
+
+type layerManifest struct {
+	layer string
+	path  string
+	root  *yaml.Node
+}
+
+func Validate(opts ValidateOptions) ([]Issue, error) {
+	l := &linter{vars: resolveVars(), declared: map[string]string{}}
+
+	if opts.MasterKey != "" {
+		master, err := secrets.ResolveMasterKey(opts.MasterKey)
+		if err != nil {
+			return nil, err
+		}
+
+		l.master = master
+		defer zeroBytes(master)
+	}
+
+	if opts.Identity != "" {
+		identity, err := secrets.ResolveIdentity(opts.Identity)
+		if err != nil {
+			return nil, err
+		}
+
+		l.identity = &identity
+	}
+
+	var manifests []layerManifest
+	for _, layer := range Layers(opts.Source) {
+		info, err := os.Stat(layer)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("seed source %q is not a directory", layer)
+		}
+
+		manifestPath := ManifestPath(layer)
+		if !internalIO.FileExists(manifestPath) {
+			continue
+		}
+
+		root, ok := l.parse(manifestPath)
+		if !ok {
+			continue
+		}
+
+		var manifest Manifest
+		if err := root.Decode(&manifest); err == nil {
+			maps.Copy(l.declared, manifest.Secrets)
+		}
+
+		manifests = append(manifests, layerManifest{layer: layer, path: manifestPath, root: root})
+	}
+
+	for _, m := range manifests {
+		l.manifest(m)
+	}
+
+	slices.SortStableFunc(l.issues, func(a, b Issue) int {
+		if a.File != b.File {
+			return strings.Compare(a.File, b.File)
+		}
+
+		return a.Line - b.Line
+	})
+
+	return l.issues, nil
+}
+
+func (l *linter) report(file string, node *yaml.Node, format string, args ...any) {
+	issue := Issue{File: file, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		issue.Line, issue.Column = node.Line, node.Column
+	}
+
+	l.issues = append(l.issues, issue)
+}
+
+func (l *linter) reportLine(file string, line int, format string, args ...any) {
+	l.issues = append(l.issues, Issue{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) parse(manifestPath string) (*yaml.Node, bool) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		l.report(manifestPath, nil, "failed to read manifest: %v", err)
+		return nil, false
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		line := 0
+		if match := yamlLineRe.FindStringSubmatch(err.Error()); match != nil {
+			line, _ = strconv.Atoi(match[1])
+		}
+
+		l.reportLine(manifestPath, line, "failed to parse manifest: %v", err)
+		return nil, false
+	}
+
+	root := documentRoot(&doc)
+	if root.Kind != yaml.MappingNode {
+		l.report(manifestPath, root, "manifest must be a mapping")
+		return nil, false
+	}
+
+	return root, true
+}
+
+func (l *linter) manifest(m layerManifest) {
+	for _, key := range unknownKeys(m.root, reflect.TypeFor[Manifest]()) {
+		l.report(m.path, key, "unknown key %q", key.Value)
+	}
+
+	version := mappingValue(m.root, "version")
+	if version == nil || version.Value != "v1" {
+		value := ""
+		if version != nil {
+			value = version.Value
+		}
+
+		l.report(m.path, orNode(version, m.root), "unsupported manifest version %q (expected \"v1\")", value)
+	}
+
+	l.recipients(m.path, mappingValue(m.root, "recipients"))
+	l.secrets(m.path, mappingValue(m.root, "secrets"))
+	l.mirror(m.path, mappingValue(m.root, "mirror"))
+
+	if seeds := mappingValue(m.root, "seeds"); seeds != nil && seeds.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(seeds.Content); i += 2 {
+			l.entry(m, seeds.Content[i], seeds.Content[i+1])
+		}
+	}
+}
+
+func (l *linter) recipients(file string, node *yaml.Node) {
+	if node == nil {
+		return
+	}
+
+	if node.Kind != yaml.SequenceNode {
+		l.report(file, node, "recipients must be a list of public keys")
+		return
+	}
+
+	for _, recipient := range node.Content {
+		if _, err := secrets.ParseRecipient(recipient.Value); err != nil {
+			l.report(file, recipient, "%v", err)
+		}
+	}
+}
+
+func (l *linter) secrets(file string, node *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i].Value, node.Content[i+1]
+
+		if err := validateSecretValue(name, value.Value); err != nil {
+			l.report(file, value, "%v", err)
+			continue
+		}
+
+		if l.checksSecrets() && !l.decrypts(value.Value, secretContext(name)) {
+			l.report(file, value, "secret %q does not decrypt with the supplied key", name)
+		}
+	}
+}
+
+func (l *linter) mirror(file string, node *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		prefix := node.Content[i].Value
+
+		var selector Selector
+		if err := node.Content[i+1].Decode(&selector); err != nil {
+			l.report(file, node.Content[i+1], "mirror %q: %v", prefix, err)
+			continue
+		}
+
+		if selector.empty() {
+			l.report(file, node.Content[i], "mirror %q: expected profiles or when", prefix)
+		} else if err := selector.validate(); err != nil {
+			l.report(file, node.Content[i], "mirror %q: %v", prefix, err)
+		}
+	}
+}
+
+func (l *linter) entry(m layerManifest, key, value *yaml.Node) {
+	rawDest := key.Value
+
+	var op SeedOp
+	if err := value.Decode(&op); err != nil {
+		l.report(m.path, value, "seed %q: %v", rawDest, err)
+		return
+	}
+
+	if !op.hasBehavior() {
+		l.report(m.path, key, "seed %q: a copy-only entry is not allowed (use the mirror tier)", rawDest)
+		return
+	}
+
+	if op.Op == "" {
+		op.Op = OpCopy
+	}
+
+	if err := validateOp(rawDest, op); err != nil {
+		l.report(m.path, key, "%v", err)
+		return
+	}
+
+	dest, err := l.vars.expand(rawDest)
+	if err != nil {
+		l.report(m.path, key, "seed %q: %v", rawDest, err)
+		return
+	}
+
+	if !isUnder(dest, l.vars.Home) && !isUnder(dest, l.vars.ServerRoot) {
+		l.report(m.path, key, "seed %q: destination is outside home and the server root", rawDest)
+	}
+
+	file, contentNode := m.path, mappingValue(value, "content")
+
+	var raw []byte
+	switch {
+	case op.Content != nil:
+		raw = []byte(*op.Content)
+	case !op.needsSource():
+		return
+	case op.Op == OpSymlink:
+		if _, err := os.Lstat(rhymingSource(m.layer, dest)); err != nil {
+			l.report(m.path, key, "seed %q: no source file at %s", rawDest, rhymingSource(m.layer, dest))
+		}
+
+		return
+	default:
+		file = rhymingSource(m.layer, dest)
+		if raw, err = os.ReadFile(file); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				l.report(m.path, key, "seed %q: no source file at %s", rawDest, file)
+			} else {
+				l.report(m.path, key, "seed %q: source unreadable: %v", rawDest, err)
+			}
+
+			return
+		}
+	}
+
+	position := func(line int) (string, int) {
+		if contentNode != nil && op.Content != nil {
+			return m.path, contentNode.Line
+		}
+
+		return file, line
+	}
+
+	switch {
+	case op.Secret:
+		if l.checksSecrets() && !l.decrypts(string(raw), seedContext(rawDest)) {
+			at, line := position(0)
+			l.reportLine(at, line, "seed %q: secret does not decrypt with the supplied key", rawDest)
+		}
+	case op.Template:
+		for _, problem := range lintTemplate(raw, l.declared) {
+			at, line := position(problem.line)
+			l.reportLine(at, line, "seed %q: %s", rawDest, problem.message)
+		}
+	case op.Op == OpMerge:
+		c, err := codecFor(dest, op.Format)
+		if err != nil {
+			return
+		}
+
+		if err := c.unmarshal(raw, &map[string]any{}); err != nil && len(strings.TrimSpace(string(raw))) > 0 {
+			at, line := position(0)
+			l.reportLine(at, line, "seed %q: merge fragment does not parse: %v", rawDest, err)
+		}
+	}
+}
+
+func (l *linter) decrypts(value, context string) bool {
+	resolved, err := secrets.ResolveEncryptedValue(secrets.NormalizeEncrypted(value))
+	if err != nil {
+		return false
+	}
+
+	resolved = secrets.NormalizeEncrypted(resolved)
+
+	var plain []byte
+	switch {
+	case secrets.IsRecipientEncrypted(resolved) && l.identity == nil:
+		return true
+	case secrets.IsRecipientEncrypted(resolved):
+		plain, err = secrets.DecryptWithContext(resolved, *l.identity, context)
+	case l.master == nil:
+		return true
+	default:
+		plain, err = secrets.DecryptContext(resolved, l.master, context)
+	}
+	zeroBytes(plain)
+
+	return err == nil
+}
+
+func (l *linter) checksSecrets() bool {
+	return l.master != nil || l.identity != nil
+}
+
+func (o SeedOp) needsSource() bool {
+	switch {
+	case o.Op == OpDirectory, o.Op == OpSymlink && o.Target != "":
+		return false
+	case o.State == PresenceAbsent && (o.Op == OpCopy || o.Op == OpSymlink):
+		return false
+	}
+
+	return true
+}
+
+type templateProblem struct {
+	line    int
+	message string
+}
+
+func lintTemplate(content []byte, declared map[string]string) []templateProblem {
+	var (
+		problems []templateProblem
+		opened   []int
+	)
+
+	add := func(offset int, format string, args ...any) {
+		line := strings.Count(string(content[:offset]), "\n") + 1
+		problems = append(problems, templateProblem{line: line, message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, loc := range tokenRe.FindAllSubmatchIndex(content, -1) {
+		token := strings.TrimSpace(string(content[loc[2]:loc[3]]))
+
+		switch {
+		case strings.HasPrefix(token, "if "):
+			opened = append(opened, loc[0])
+			expr := strings.TrimSpace(token[3:])
+
+			if err := validateExpression(expr); err != nil {
+				add(loc[0], "%v", err)
+				continue
+			}
+
+			for _, alternative := range strings.Split(expr, "||") {
+				for _, term := range strings.Split(alternative, "&&") {
+					name := conditionRe.FindStringSubmatch(strings.TrimSpace(term))[2]
+					if err := lintToken(name, declared); err != nil {
+						add(loc[0], "%v", err)
+					}
+				}
+			}
+		case token == "else":
+			if len(opened) == 0 {
+				add(loc[0], "unexpected ${else} without ${if}")
+			}
+		case token == "end":
+			if len(opened) == 0 {
+				add(loc[0], "unexpected ${end} without ${if}")
+				continue
+			}
+
+			opened = opened[:len(opened)-1]
+		default:
+			name, _, _ := strings.Cut(token, defaultMarker)
+			if err := lintToken(strings.TrimSpace(name), declared); err != nil {
+				add(loc[0], "%v", err)
+			}
+		}
+	}
+
+	for _, offset := range opened {
+		add(offset, "unterminated ${if}")
+	}
+
+	return problems
+}
+
+func lintToken(name string, declared map[string]string) error {
+	switch name {
+	case "ws_home", "ws_user", "ws_server_root", "ws_ssh":
+		return nil
+	}
+
+	if key, ok := strings.CutPrefix(name, secretsPrefix); ok {
+		if _, found := declared[key]; !found {
+			return fmt.Errorf("secret %q not declared", key)
+		}
+
+		return nil
+	}
+
+	if setting, ok := strings.CutPrefix(name, settingsPrefix); ok {
+		group, prop, ok := strings.Cut(setting, ".")
+		if !ok || group == "" || prop == "" {
+			return fmt.Errorf("invalid setting reference ${%s}", name)
+		}
+
+		if _, known, err := config.LookupProperty(config.RuntimeKey(group, prop)); err == nil && !known {
+			return fmt.Errorf("unknown workspace setting %q", setting)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown template token ${%s}", name)
+}
+
+func unknownKeys(node *yaml.Node, t reflect.Type) []*yaml.Node {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		var unknown []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			unknown = append(unknown, unknownKeys(node.Content[i+1], t.Elem())...)
+		}
+
+		return unknown
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	fields := yamlFields(t)
+
+	var unknown []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+
+		field, ok := fields[key.Value]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		unknown = append(unknown, unknownKeys(node.Content[i+1], field)...)
+	}
+
+	return unknown
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for _, field := range reflect.VisibleFields(t) {
+		if len(field.Index) > 1 || !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if options == "inline" {
+			maps.Copy(fields, yamlFields(field.Type))
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+func orNode(node, fallback *yaml.Node) *yaml.Node {
+	if node != nil {
+		return node
+	}
+
+	return fallback
+}
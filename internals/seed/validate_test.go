@@ -0,0 +1,207 @@
+package seed
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func validate(t *testing.T, opts ValidateOptions) []string {
+	t.Helper()
+	issues, err := Validate(opts)
+	assert.NilError(t, err)
+
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+
+	return messages
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("Clean", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, ".gitconfig")
+
+		write(t, rhyming(source, dest), "[user]\n  name = ${ws_user}\n")
+		writeManifest(t, source, "seeds:\n  ${ws_home}/.gitconfig:\n    template: true\n")
+
+		assert.Equal(t, len(validate(t, ValidateOptions{Source: source})), 0)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+
+		writeManifest(t, source, "seeds:\n  ${ws_home}/out.txt:\n    content: x\n    mdoe: \"0o600\"\nextra: true\n")
+
+		issues := validate(t, ValidateOptions{Source: source})
+
+		manifest := ManifestPath(source)
+		assert.DeepEqual(t, issues, []string{
+			manifest + `:5:5: unknown key "mdoe"`,
+			manifest + `:6:1: unknown key "extra"`,
+		})
+	})
+
+	t.Run("InvalidEntry", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+
+		writeManifest(t, source, "seeds:\n  ${ws_home}/out.txt:\n    op: explode\n")
+
+		issues := validate(t, ValidateOptions{Source: source})
+
+		assert.Equal(t, len(issues), 1)
+		assert.Assert(t, strings.Contains(issues[0], ":3:3: "))
+		assert.Assert(t, strings.Contains(issues[0], `unknown op "explode"`))
+	})
+
+	t.Run("TemplateTokens", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, "rendered.txt")
+
+		write(t, rhyming(source, dest), "ok ${ws_home}\n${secrets.MISSING}\n${nope}\n${if ws_ssh}\n")
+		writeManifest(t, source, "seeds:\n  ${ws_home}/rendered.txt:\n    template: true\n")
+
+		issues := validate(t, ValidateOptions{Source: source})
+
+		file := rhyming(source, dest)
+		assert.DeepEqual(t, issues, []string{
+			file + `:2: seed "${ws_home}/rendered.txt": secret "MISSING" not declared`,
+			file + `:3: seed "${ws_home}/rendered.txt": unknown template token ${nope}`,
+			file + `:4: seed "${ws_home}/rendered.txt": unterminated ${if}`,
+		})
+	})
+
+	t.Run("MergeFragment", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		dest := filepath.Join(home, "settings.json")
+
+		write(t, rhyming(source, dest), "{not json")
+		writeManifest(t, source, "seeds:\n  ${ws_home}/settings.json:\n    op: merge\n")
+
+		issues := validate(t, ValidateOptions{Source: source})
+
+		assert.Equal(t, len(issues), 1)
+		assert.Assert(t, strings.Contains(issues[0], "merge fragment does not parse"))
+	})
+
+	t.Run("OutsideHome", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		dest := filepath.Join(t.TempDir(), "out.txt")
+
+		writeManifest(t, source, fmt.Sprintf("seeds:\n  %s:\n    content: x\n", dest))
+
+		issues := validate(t, ValidateOptions{Source: source})
+
+		assert.Equal(t, len(issues), 1)
+		assert.Assert(t, strings.Contains(issues[0], "destination is outside home and the server root"))
+	})
+
+	t.Run("MissingSource", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+
+		writeManifest(t, source, "seeds:\n  ${ws_home}/.bashrc:\n    mode: \"0o600\"\n")
+
+		issues := validate(t, ValidateOptions{Source: source})
+
+		assert.Equal(t, len(issues), 1)
+		assert.Assert(t, strings.Contains(issues[0], ":3:3: "))
+		assert.Assert(t, strings.Contains(issues[0], "no source file at"))
+	})
+
+	t.Run("SecretsDecrypt", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		source := t.TempDir()
+		other := "base64:" + strings.Repeat("B", 43) + "="
+
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  GOOD: %s\n  BAD: %s\nseeds:\n  ${ws_home}/token:\n    secret: true\n    content: %s\n",
+			encrypt(t, "good", testMaster), encrypt(t, "bad", other), encrypt(t, "token", other),
+		))
+
+		assert.Equal(t, len(validate(t, ValidateOptions{Source: source})), 0)
+
+		issues := validate(t, ValidateOptions{Source: source, MasterKey: testMaster})
+
+		assert.Equal(t, len(issues), 2)
+		assert.Assert(t, strings.Contains(issues[0], `:4:8: secret "BAD" does not decrypt`))
+		assert.Assert(t, strings.Contains(issues[1], `seed "${ws_home}/token": secret does not decrypt`))
+	})
+
+	t.Run("BoundSecrets", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+
+		writeManifest(t, source, fmt.Sprintf(
+			"secrets:\n  API_TOKEN: %s\n  DB_PASSWORD: %s\nseeds:\n  ${ws_home}/token:\n    secret: true\n    content: %s\n",
+			encryptBound(t, "token", testMaster, "secrets.API_TOKEN"),
+			encryptBound(t, "password", testMaster, "secrets.API_TOKEN"),
+			encryptBound(t, "seed", testMaster, "seeds.${ws_home}/token"),
+		))
+
+		issues := validate(t, ValidateOptions{Source: source, MasterKey: testMaster})
+
+		assert.Equal(t, len(issues), 1)
+		assert.Assert(t, strings.Contains(issues[0], `secret "DB_PASSWORD" does not decrypt`))
+	})
+
+	t.Run("Recipients", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		alice, bob := newIdentity(t), newIdentity(t)
+		source := t.TempDir()
+
+		writeManifest(t, source, fmt.Sprintf(
+			"recipients:\n  - %s\n  - wspub1:short\nsecrets:\n  TOK: %s\n",
+			alice.Recipient(), encryptFor(t, "V", alice),
+		))
+
+		manifest := ManifestPath(source)
+		issues := validate(t, ValidateOptions{Source: source, Identity: bob.String()})
+
+		assert.Equal(t, len(issues), 2)
+		assert.Assert(t, strings.HasPrefix(issues[0], manifest+`:4:5: invalid recipient "wspub1:short"`))
+		assert.Equal(t, issues[1], manifest+`:6:8: secret "TOK" does not decrypt with the supplied key`)
+
+		assert.Equal(t, len(validate(t, ValidateOptions{Source: source, Identity: alice.String()})), 1)
+	})
+
+	t.Run("SecretsAcrossLayers", func(t *testing.T) {
+		home := t.TempDir()
+		setEnv(t, home)
+		org := t.TempDir()
+		team := t.TempDir()
+
+		writeManifest(t, org, fmt.Sprintf("secrets:\n  TOK: %s\n", encrypt(t, "x", testMaster)))
+		writeManifest(t, team, "seeds:\n  ${ws_home}/out.txt:\n    template: true\n    content: \"${secrets.TOK}\"\n")
+
+		assert.Equal(t, len(validate(t, ValidateOptions{Source: org + ":" + team})), 0)
+	})
+
+	t.Run("YamlSyntax", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+
+		write(t, ManifestPath(source), "version: v1\nseeds:\n  - [\n")
+
+		issues := validate(t, ValidateOptions{Source: source})
+
+		assert.Equal(t, len(issues), 1)
+		assert.Assert(t, strings.HasPrefix(issues[0], ManifestPath(source)+":"))
+	})
+}
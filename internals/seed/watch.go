@@ -0,0 +1,273 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/kloudkit/ws-cli/internals/styles"
+)
+
+const (
+	watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM |
+		syscall.IN_MOVED_TO | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF
+	watchSettle = 150 * time.Millisecond
+)
+
+type watcher struct {
+	file    *os.File
+	watches map[int]string
+	changes chan struct{}
+	failed  chan error
+}
+
+func (r reporter) watching(source string) {
+	if r.report != nil {
+		return
+	}
+
+	message := fmt.Sprintf("Watching %s for changes", source)
+
+	if r.styled {
+		fmt.Fprintln(r.out, styles.Muted().Render(message))
+		return
+	}
+
+	fmt.Fprintln(r.out, message)
+}
+
+func Watch(ctx context.Context, opts Options) error {
+	rep := newReporter(opts.Out, opts.Styled, opts.Output, opts.DryRun)
+
+	w, err := newWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.close()
+
+	for _, layer := range Layers(opts.Source) {
+		if err := w.add(layer); err != nil {
+			return err
+		}
+	}
+
+	go w.run()
+
+	fingerprints := map[string]string{}
+	backups := newBackupRun(nil)
+	cycle := func() {
+		changed, err := changedDests(opts, fingerprints)
+		if err != nil {
+			rep.warn(err.Error())
+			return
+		}
+
+		if len(changed) == 0 {
+			return
+		}
+
+		run := opts
+		run.Dests = changed
+		run.forceDests = inSyncDests(changed)
+		run.backups = backups
+		if err := Apply(run); err != nil {
+			rep.warn(err.Error())
+		}
+	}
+
+	cycle()
+	rep.watching(opts.Source)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-w.failed:
+			return err
+		case <-w.changes:
+		}
+
+		settle := time.NewTimer(watchSettle)
+		for settling := true; settling; {
+			select {
+			case <-ctx.Done():
+				settle.Stop()
+				return nil
+			case <-w.changes:
+				settle.Reset(watchSettle)
+			case <-settle.C:
+				settling = false
+			}
+		}
+
+		cycle()
+	}
+}
+
+func changedDests(opts Options, previous map[string]string) ([]string, error) {
+	plan, err := BuildPlan(opts.Source, opts.Force)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := plan.Select(opts.Profiles); err != nil {
+		return nil, err
+	}
+
+	ops := plan.Ops
+	if len(opts.Dests) > 0 {
+		if ops, err = plan.filterDests(opts.Dests); err != nil {
+			return nil, err
+		}
+	}
+
+	current := map[string]string{}
+	for _, op := range ops {
+		current[op.Dest] = plan.fingerprint(op)
+	}
+
+	var changed []string
+	for dest, print := range current {
+		if previous[dest] != print {
+			changed = append(changed, dest)
+		}
+	}
+
+	clear(previous)
+	maps.Copy(previous, current)
+	slices.Sort(changed)
+
+	return changed, nil
+}
+
+func inSyncDests(dests []string) map[string]bool {
+	ledger, err := LoadLedger(LedgerPath())
+	if err != nil {
+		return nil
+	}
+
+	synced := map[string]bool{}
+	for _, dest := range dests {
+		if ledger.inSync(dest) {
+			synced[dest] = true
+		}
+	}
+
+	return synced
+}
+
+func (p *Plan) fingerprint(op ResolvedOp) string {
+	definition, _ := json.Marshal(op)
+
+	sourceHash, err := p.sourceHash(op)
+	if err != nil {
+		sourceHash = err.Error()
+	}
+
+	secrets := []byte{}
+	if op.Secret || op.Template {
+		secrets, _ = json.Marshal(p.Secrets)
+	}
+
+	return hashBytes(slices.Concat(definition, []byte(sourceHash), secrets))
+}
+
+func newWatcher() (*watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start watching: %w", err)
+	}
+
+	file := os.NewFile(uintptr(fd), "inotify")
+	if err := file.SetReadDeadline(time.Time{}); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to start watching: %w", err)
+	}
+
+	return &watcher{
+		file:    file,
+		watches: map[int]string{},
+		changes: make(chan struct{}, 1),
+		failed:  make(chan error, 1),
+	}, nil
+}
+
+func (w *watcher) add(root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return fmt.Errorf("failed to watch %q: %w", root, err)
+			}
+
+			return nil
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(int(w.file.Fd()), p, watchMask)
+		if err != nil {
+			return fmt.Errorf("failed to watch %q: %w", p, err)
+		}
+
+		w.watches[wd] = p
+
+		return nil
+	})
+}
+
+func (w *watcher) run() {
+	buffer := make([]byte, 64*1024)
+
+	for {
+		n, err := w.file.Read(buffer)
+		if errors.Is(err, os.ErrClosed) {
+			return
+		}
+		if err != nil {
+			w.failed <- fmt.Errorf("failed to watch: %w", err)
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			name := buffer[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				if dir, ok := w.watches[int(event.Wd)]; ok {
+					w.add(filepath.Join(dir, string(bytesUntilNull(name))))
+				}
+			}
+		}
+
+		select {
+		case w.changes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *watcher) close() {
+	w.file.Close()
+}
+
+func bytesUntilNull(name []byte) []byte {
+	for i, b := range name {
+		if b == 0 {
+			return name[:i]
+		}
+	}
+
+	return name
+}
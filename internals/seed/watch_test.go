@@ -0,0 +1,204 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.String()
+}
+
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+
+	t.Fatal("condition not met before deadline")
+}
+
+func TestWatch(t *testing.T) {
+	t.Run("ReappliesChangedDestinations", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		edited := filepath.Join(target, "edited.txt")
+		steady := filepath.Join(target, "steady.txt")
+
+		write(t, rhyming(source, edited), "one\n")
+		write(t, rhyming(source, steady), "steady\n")
+
+		out := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() { done <- Watch(ctx, Options{Source: source, Force: true, Out: out}) }()
+
+		eventually(t, func() bool { return strings.Contains(out.String(), "Watching "+source) })
+		assert.Equal(t, readFile(t, edited), "one\n")
+		assert.Equal(t, strings.Count(out.String(), "Seeded ["+steady+"]"), 1)
+
+		write(t, rhyming(source, edited), "two\n")
+
+		eventually(t, func() bool {
+			got, _ := os.ReadFile(edited)
+			return string(got) == "two\n"
+		})
+
+		cancel()
+		assert.NilError(t, <-done)
+
+		assert.Equal(t, strings.Count(out.String(), "["+steady+"]"), 1)
+	})
+
+	t.Run("ReappliesWithoutForceWhileInSync", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		synced := filepath.Join(target, "synced.txt")
+		edited := filepath.Join(target, "edited.txt")
+
+		write(t, rhyming(source, synced), "one\n")
+		write(t, rhyming(source, edited), "one\n")
+
+		out := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() { done <- Watch(ctx, Options{Source: source, Out: out}) }()
+
+		eventually(t, func() bool { return strings.Contains(out.String(), "Watching") })
+		write(t, edited, "mine\n")
+
+		write(t, rhyming(source, synced), "two\n")
+		write(t, rhyming(source, edited), "two\n")
+
+		eventually(t, func() bool {
+			got, _ := os.ReadFile(synced)
+			return string(got) == "two\n"
+		})
+		eventually(t, func() bool { return strings.Contains(out.String(), "Skipping ["+edited+"] (exists)") })
+		assert.Equal(t, readFile(t, edited), "mine\n")
+
+		cancel()
+		assert.NilError(t, <-done)
+	})
+
+	t.Run("WatchesNewDirectories", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "nested", "deep", "file.txt")
+
+		out := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() { done <- Watch(ctx, Options{Source: source, Out: out}) }()
+
+		eventually(t, func() bool { return strings.Contains(out.String(), "Watching") })
+
+		write(t, rhyming(source, dest), "late\n")
+
+		eventually(t, func() bool { return fileExists(dest) })
+
+		cancel()
+		assert.NilError(t, <-done)
+	})
+
+	t.Run("ReportsErrorsAndKeepsWatching", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "out.txt")
+
+		writeManifest(t, source, "seeds:\n  "+dest+":\n    content: \"a\\n\"\n")
+
+		out := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() { done <- Watch(ctx, Options{Source: source, Out: out}) }()
+
+		eventually(t, func() bool { return strings.Contains(out.String(), "Watching") })
+
+		write(t, filepath.Join(source, ".seed.yaml"), "seeds: [\n")
+		eventually(t, func() bool { return strings.Contains(out.String(), "manifest") })
+
+		writeManifest(t, source, "seeds:\n  "+dest+":\n    content: \"b\\n\"\n    force: true\n")
+		eventually(t, func() bool {
+			got, _ := os.ReadFile(dest)
+			return string(got) == "b\n"
+		})
+
+		cancel()
+		assert.NilError(t, <-done)
+	})
+
+	t.Run("KeepsPreWatchBackup", func(t *testing.T) {
+		setEnv(t, t.TempDir())
+		source := t.TempDir()
+		target := t.TempDir()
+		dest := filepath.Join(target, "edited.txt")
+
+		write(t, dest, "original\n")
+		write(t, rhyming(source, dest), "v0\n")
+
+		out := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() { done <- Watch(ctx, Options{Source: source, Force: true, Out: out}) }()
+
+		eventually(t, func() bool { return strings.Contains(out.String(), "Watching "+source) })
+
+		for i := 1; i <= backupRetention+2; i++ {
+			content := fmt.Sprintf("v%d\n", i)
+			write(t, rhyming(source, dest), content)
+
+			eventually(t, func() bool {
+				got, _ := os.ReadFile(dest)
+				return string(got) == content
+			})
+		}
+
+		cancel()
+		assert.NilError(t, <-done)
+
+		backups, err := ListBackups()
+		assert.NilError(t, err)
+		assert.Equal(t, len(backups), 1)
+		assert.Equal(t, len(backups[0].Entries), 1)
+
+		assert.NilError(t, Rollback(RollbackOptions{Out: &bytes.Buffer{}}))
+		assert.Equal(t, readFile(t, dest), "original\n")
+	})
+}
@@ -75,3 +75,34 @@ func writeTemp(root *os.Root, tmp string, content []byte, mode fs.FileMode) (err
 
 	return nil
 }
+
+func removeAtomic(anchor, dest string) error {
+	root, err := os.OpenRoot(anchor)
+	if err != nil {
+		return fmt.Errorf("failed to open root %q: %w", anchor, err)
+	}
+	defer root.Close()
+
+	rel, err := filepath.Rel(anchor, dest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve relative path: %w", err)
+	}
+
+	info, err := root.Lstat(rel)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %q: %w", dest, err)
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("refusing to remove through symlink %q", dest)
+	}
+
+	if err := root.Remove(rel); err != nil {
+		return fmt.Errorf("failed to remove: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+type yamlPatcher struct {
+	doc    yaml.Node
+	indent int
+}
+
+func newYAMLPatcher(data []byte) (*yamlPatcher, error) {
+	y := &yamlPatcher{indent: yamlIndent(data)}
+	if err := yaml.Unmarshal(data, &y.doc); err != nil {
+		return nil, err
+	}
+
+	if root := documentRoot(&y.doc); root == nil || root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: top level is not a mapping", errUnpreservable)
+	}
+
+	return y, nil
+}
+
+func (y *yamlPatcher) lookup(path []string) (*yaml.Node, int, error) {
+	node := documentRoot(&y.doc)
+
+	for i, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil, 0, fmt.Errorf("%w: %s is not a mapping", errUnpreservable, describePath(path[:i]))
+		}
+
+		index := -1
+		for k := 0; k+1 < len(node.Content); k += 2 {
+			if node.Content[k].Value == key {
+				index = k
+			}
+		}
+
+		if i == len(path)-1 {
+			return node, index, nil
+		}
+
+		if index < 0 {
+			return nil, 0, fmt.Errorf("%w: %s not found", errUnpreservable, describePath(path[:i+1]))
+		}
+
+		node = node.Content[index+1]
+		if node.Kind == yaml.AliasNode {
+			return nil, 0, fmt.Errorf("%w: %s is an alias", errUnpreservable, describePath(path[:i+1]))
+		}
+	}
+
+	return node, -1, nil
+}
+
+func (y *yamlPatcher) set(path []string, value any) error {
+	parent, index, err := y.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return err
+	}
+
+	if index < 0 {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[len(path)-1]}
+		parent.Content = append(parent.Content, key, &node)
+		return nil
+	}
+
+	previous := parent.Content[index+1]
+	if node.Kind == yaml.ScalarNode {
+		node.LineComment = previous.LineComment
+	}
+	node.HeadComment = previous.HeadComment
+	node.FootComment = previous.FootComment
+
+	parent.Content[index+1] = &node
+
+	return nil
+}
+
+func (y *yamlPatcher) extend(path []string, items []any) error {
+	parent, index, err := y.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || parent.Content[index+1].Kind != yaml.SequenceNode {
+		return fmt.Errorf("%w: %s is not a sequence", errUnpreservable, describePath(path))
+	}
+
+	sequence := parent.Content[index+1]
+	for _, item := range items {
+		var node yaml.Node
+		if err := node.Encode(item); err != nil {
+			return err
+		}
+
+		sequence.Content = append(sequence.Content, &node)
+	}
+
+	return nil
+}
+
+func (y *yamlPatcher) remove(path []string) error {
+	parent, index, err := y.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 {
+		return fmt.Errorf("%w: %s not found", errUnpreservable, describePath(path))
+	}
+
+	parent.Content = append(parent.Content[:index], parent.Content[index+2:]...)
+
+	return nil
+}
+
+func (y *yamlPatcher) bytes() ([]byte, error) {
+	implicitMergeKeys(&y.doc)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(y.indent)
+
+	if err := encoder.Encode(&y.doc); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func implicitMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!merge" && node.Value == "<<" && node.Style&yaml.TaggedStyle == 0 {
+		node.Tag = ""
+	}
+
+	for _, child := range node.Content {
+		implicitMergeKeys(child)
+	}
+}
+
+func yamlIndent(data []byte) int {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		if len(trimmed) == 0 || trimmed[0] == '#' || trimmed[0] == '-' || len(trimmed) == len(line) {
+			continue
+		}
+
+		if width := len(line) - len(trimmed); width >= 2 && width <= 8 {
+			return width
+		}
+	}
+
+	return 2
+}